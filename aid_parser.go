@@ -195,19 +195,10 @@ func SearchAidList(query string, aidList []*AidItem) []*AidItem {
 // 支持eUICC卡和传统SIM卡（如移动、联通、电信的手机卡）
 // 使用轻量级测试方法提高效率：优先使用 profile list（eUICC），失败则使用 chip info（通用）
 func TestAid(aid string) bool {
-	// 保存当前AID
-	originalAid := ConfigInstance.LpacAID
-	
-	// 临时设置测试AID
-	ConfigInstance.LpacAID = aid
-	defer func() {
-		// 恢复原始AID
-		ConfigInstance.LpacAID = originalAid
-	}()
-	
+	// 使用指定的 AID 提交任务，不修改设置中的 AID
 	// 对于eUICC卡，优先使用更轻量的 profile list 命令来测试AID
 	// 这比 chip info 更快，因为不需要读取完整的芯片信息
-	_, err := LpacProfileList()
+	_, err := CardJobs.Do(&Job{Args: []string{"profile", "list"}, AID: aid})
 	if err == nil {
 		// profile list 成功，说明AID有效（eUICC卡）
 		return true
@@ -218,8 +209,8 @@ func TestAid(aid string) bool {
 	// 1. eUICC卡（如果profile list失败但chip info成功）
 	// 2. 传统SIM卡（如移动、联通、电信的手机卡）
 	//    传统SIM卡不支持profile list，但chip info可能能够读取基本信息
-	// 注意：runLpac 已经内置了5秒超时，所以这里不需要额外超时
-	_, err = LpacChipInfo()
+	// 注意：runLpac 对 chip info 和 profile list 使用较短的 Query 超时，所以这里不需要额外超时
+	_, err = CardJobs.Do(&Job{Args: []string{"chip", "info"}, AID: aid})
 	return err == nil
 }
 
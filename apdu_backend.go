@@ -0,0 +1,184 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strconv"
+)
+
+// ApduBackend 是 lpac 的 APDU 驱动，对应 LPAC_APDU
+type ApduBackend struct {
+	Name  string // LPAC_APDU 的值
+	Label string
+	// Device 为 true 时读卡器列表中是设备路径，而不是 PC/SC 读卡器
+	Device bool
+	// Slot 为 true 时需要设置 UIM 卡槽
+	Slot bool
+	// Globs 用于 lpac 无法列出设备时查找设备
+	Globs []string
+}
+
+var ApduBackends = []ApduBackend{
+	{Name: "pcsc", Label: "PC/SC"},
+	{Name: "at", Label: "AT", Device: true, Globs: []string{"/dev/ttyUSB*", "/dev/ttyACM*", "/dev/wwan*at*"}},
+	{Name: "at_csim", Label: "AT (CSIM)", Device: true, Globs: []string{"/dev/ttyUSB*", "/dev/ttyACM*", "/dev/wwan*at*"}},
+	{Name: "qmi", Label: "QMI", Device: true, Slot: true, Globs: []string{"/dev/cdc-wdm*", "/dev/wwan*qmi*"}},
+	{Name: "qmi_qrtr", Label: "QMI over QRTR", Slot: true},
+	{Name: "mbim", Label: "MBIM", Device: true, Slot: true, Globs: []string{"/dev/cdc-wdm*", "/dev/wwan*mbim*"}},
+	{Name: "stdio", Label: "stdio"},
+}
+
+var HttpBackends = []string{"curl", "stdio"}
+
+// AvailableApduBackends 返回当前平台 lpac 支持的 APDU 驱动
+// QMI 和 MBIM 依赖 libqmi/libmbim，只在 Linux 上可用
+func AvailableApduBackends() []ApduBackend {
+	if runtime.GOOS == "linux" {
+		return ApduBackends
+	}
+	return slices.DeleteFunc(slices.Clone(ApduBackends), func(b ApduBackend) bool {
+		return b.Name == "qmi" || b.Name == "qmi_qrtr" || b.Name == "mbim"
+	})
+}
+
+// CurrentApduBackend 返回设置中选择的 APDU 驱动，未知时回退到 PC/SC
+func CurrentApduBackend() ApduBackend {
+	for _, b := range ApduBackends {
+		if b.Name == ConfigInstance.ApduBackend {
+			return b
+		}
+	}
+	return ApduBackends[0]
+}
+
+// lpacEnv 生成运行 job 所需的环境变量
+// lpac 2.x 改用 LPAC_APDU_* 前缀的变量名，为兼容旧版本同时设置新旧两种
+func lpacEnv(job *Job) []string {
+	backend := CurrentApduBackend()
+	http := ConfigInstance.HttpBackend
+	if http == "" {
+		http = HttpBackends[0]
+	}
+	env := []string{
+		"LPAC_APDU=" + backend.Name,
+		"LPAC_HTTP=" + http,
+		"LPAC_CUSTOM_ISD_R_AID=" + job.AID,
+	}
+	both := func(newName, oldName, value string) {
+		env = append(env, newName+"="+value, oldName+"="+value)
+	}
+	slot := strconv.Itoa(ConfigInstance.UimSlot)
+	switch backend.Name {
+	case "pcsc":
+		both("LPAC_APDU_PCSC_DRV_IFID", "DRIVER_IFID", job.Reader)
+	case "at", "at_csim":
+		both("LPAC_APDU_AT_DEVICE", "AT_DEVICE", job.Reader)
+	case "qmi":
+		both("LPAC_APDU_QMI_DEVICE", "QMI_DEVICE", job.Reader)
+		both("LPAC_APDU_QMI_UIM_SLOT", "UIM_SLOT", slot)
+	case "qmi_qrtr":
+		both("LPAC_APDU_QMI_UIM_SLOT", "UIM_SLOT", slot)
+	case "mbim":
+		both("LPAC_APDU_MBIM_DEVICE", "MBIM_DEVICE", job.Reader)
+		both("LPAC_APDU_MBIM_UIM_SLOT", "MBIM_UIM_SLOT", slot)
+		if ConfigInstance.MbimProxy {
+			both("LPAC_APDU_MBIM_USE_PROXY", "MBIM_USE_PROXY", "1")
+		}
+	}
+	if ConfigInstance.DebugHTTP {
+		env = append(env, "LIBEUICC_DEBUG_HTTP=1")
+	}
+	if ConfigInstance.DebugAPDU {
+		env = append(env, "LIBEUICC_DEBUG_APDU=1")
+	}
+	return env
+}
+
+// findApduDevices 在 lpac 无法列出设备时按 Globs 查找设备路径
+// 设置中手动填写的设备总是排在最前面
+func findApduDevices(backend ApduBackend) []*ApduDriver {
+	var devices []*ApduDriver
+	add := func(path string) {
+		for _, d := range devices {
+			if d.Env == path {
+				return
+			}
+		}
+		devices = append(devices, &ApduDriver{Env: path, Name: path})
+	}
+	if ConfigInstance.ApduDevice != "" {
+		add(ConfigInstance.ApduDevice)
+	}
+	for _, pattern := range backend.Globs {
+		matches, _ := filepath.Glob(pattern)
+		for _, match := range matches {
+			add(match)
+		}
+	}
+	return devices
+}
+
+// ListApduDrivers 列出当前 APDU 驱动可用的读卡器或设备
+func ListApduDrivers() ([]*ApduDriver, error) {
+	backend := CurrentApduBackend()
+	drivers, err := LpacDriverApduList()
+	if !backend.Device {
+		if backend.Name != "pcsc" && len(drivers) == 0 {
+			// QRTR 和 stdio 不需要选择设备，提供一个占位项以便选择
+			name := backend.Label
+			if backend.Slot {
+				name = fmt.Sprintf("%s (slot %d)", backend.Label, ConfigInstance.UimSlot)
+			}
+			return []*ApduDriver{{Env: backend.Name, Name: name}}, nil
+		}
+		return drivers, err
+	}
+	// 部分驱动不支持 driver apdu list，此时不作为错误
+	for _, d := range findApduDevices(backend) {
+		if !slices.ContainsFunc(drivers, func(existing *ApduDriver) bool { return existing.Env == d.Env }) {
+			drivers = append(drivers, d)
+		}
+	}
+	if len(drivers) > 0 {
+		return drivers, nil
+	}
+	return nil, err
+}
+
+func readerNotFound(message string) *LpacError {
+	return &LpacError{
+		Function:  "driver apdu list",
+		Class:     ErrorClassReader,
+		Key:       "reader_unavailable",
+		Retryable: true,
+		Err:       errors.New(message),
+	}
+}
+
+// FindReader 按名称或 DRIVER_IFID 查找读卡器
+// name 为空时使用上次选择的读卡器，不存在时使用第一个
+func FindReader(name string) (*ApduDriver, error) {
+	drivers, err := ListApduDrivers()
+	if err != nil {
+		return nil, err
+	}
+	if len(drivers) == 0 {
+		return nil, readerNotFound("no card reader found")
+	}
+	wanted := name
+	if wanted == "" {
+		wanted = ConfigInstance.ReaderName
+	}
+	for _, d := range drivers {
+		if d.Name == wanted || d.Env == wanted {
+			return d, nil
+		}
+	}
+	if name != "" {
+		return nil, readerNotFound("card reader not found: " + name)
+	}
+	return drivers[0], nil
+}
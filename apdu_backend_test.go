@@ -0,0 +1,50 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLpacEnv(t *testing.T) {
+	origin := ConfigInstance
+	t.Cleanup(func() {
+		ConfigInstance = origin
+	})
+	job := &Job{Args: []string{"chip", "info"}, Reader: "/dev/cdc-wdm0", AID: AID_DEFAULT}
+
+	ConfigInstance.ApduBackend = "mbim"
+	ConfigInstance.HttpBackend = ""
+	ConfigInstance.UimSlot = 2
+	ConfigInstance.MbimProxy = true
+	env := lpacEnv(job)
+	assert.Subset(t, env, []string{
+		"LPAC_APDU=mbim", "LPAC_HTTP=curl", "LPAC_CUSTOM_ISD_R_AID=" + AID_DEFAULT,
+		"LPAC_APDU_MBIM_DEVICE=/dev/cdc-wdm0", "MBIM_DEVICE=/dev/cdc-wdm0",
+		"LPAC_APDU_MBIM_UIM_SLOT=2", "MBIM_UIM_SLOT=2",
+		"LPAC_APDU_MBIM_USE_PROXY=1", "MBIM_USE_PROXY=1",
+	})
+
+	ConfigInstance.ApduBackend = "unknown"
+	job.Reader = "1"
+	env = lpacEnv(job)
+	assert.Subset(t, env, []string{"LPAC_APDU=pcsc", "DRIVER_IFID=1", "LPAC_APDU_PCSC_DRV_IFID=1"})
+	assert.NotContains(t, env, "UIM_SLOT=2")
+}
+
+func TestListApduDriversDeviceFallback(t *testing.T) {
+	fake := useFakeLpac(t)
+	fake.Drivers = nil
+	origin := ConfigInstance
+	t.Cleanup(func() {
+		ConfigInstance = origin
+	})
+	ConfigInstance.ApduBackend = "at"
+	ConfigInstance.ApduDevice = "/dev/wwan0at0"
+
+	drivers, err := ListApduDrivers()
+	assert.NoError(t, err)
+	if assert.NotEmpty(t, drivers) {
+		assert.Equal(t, "/dev/wwan0at0", drivers[0].Env)
+	}
+}
@@ -0,0 +1,576 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultAPIListen 是本地 API 服务的默认地址
+const DefaultAPIListen = "127.0.0.1:8077"
+
+// validateAPIListen 检查 API 地址，只允许本机地址或 Unix socket
+func validateAPIListen(address string) error {
+	if path, ok := strings.CutPrefix(address, "unix:"); ok {
+		if path == "" {
+			return errors.New("empty unix socket path")
+		}
+		return nil
+	}
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	if host == "localhost" {
+		return nil
+	}
+	if ip := net.ParseIP(host); ip == nil || !ip.IsLoopback() {
+		return fmt.Errorf("%s is not a loopback address", host)
+	}
+	return nil
+}
+
+func listenAPI(address string) (net.Listener, error) {
+	if err := validateAPIListen(address); err != nil {
+		return nil, err
+	}
+	path, ok := strings.CutPrefix(address, "unix:")
+	if !ok {
+		return net.Listen("tcp", address)
+	}
+	// 删除上次异常退出时遗留的 socket
+	if info, err := os.Lstat(path); err == nil && info.Mode()&os.ModeSocket != 0 {
+		_ = os.Remove(path)
+	}
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	if err = os.Chmod(path, 0600); err != nil {
+		listener.Close()
+		return nil, err
+	}
+	return listener, nil
+}
+
+// NewAPIToken 生成随机的 API token
+func NewAPIToken() string {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
+
+// APIServer 通过 HTTP 提供 cmd.go 中的操作，所有命令和界面一样经过 CardJobs 排队
+type APIServer struct {
+	token    string
+	mux      *http.ServeMux
+	server   *http.Server
+	listener net.Listener
+	done     chan struct{}
+	closing  sync.Once
+
+	// SelectReader 在客户端选择读卡器时调用，界面模式下同步更新读卡器列表
+	SelectReader func(d *ApduDriver)
+	// CardChanged 在客户端修改卡片后调用，界面模式下刷新列表
+	CardChanged func()
+}
+
+// APIServerInstance 是界面中启动的 API 服务，未启动时为 nil
+var APIServerInstance *APIServer
+
+func NewAPIServer(token string) *APIServer {
+	s := &APIServer{
+		token:        token,
+		mux:          http.NewServeMux(),
+		done:         make(chan struct{}),
+		SelectReader: selectReader,
+		CardChanged:  func() {},
+	}
+	s.handle("GET /api/v1/status", s.getStatus)
+	s.handle("GET /api/v1/readers", s.getReaders)
+	s.handle("PUT /api/v1/reader", s.putReader)
+	s.handle("GET /api/v1/chip", s.getChip)
+	s.handle("GET /api/v1/profiles", s.getProfiles)
+	s.handle("POST /api/v1/profiles", s.downloadProfile)
+	s.handle("POST /api/v1/profiles/{iccid}/enable", s.profileOperation("profile enable", LpacProfileEnable))
+	s.handle("POST /api/v1/profiles/{iccid}/disable", s.profileOperation("profile disable", LpacProfileDisable))
+	s.handle("DELETE /api/v1/profiles/{iccid}", s.profileOperation("profile delete", LpacProfileDelete))
+	s.handle("PUT /api/v1/profiles/{iccid}/nickname", s.putNickname)
+	s.handle("GET /api/v1/notifications", s.getNotifications)
+	s.handle("POST /api/v1/notifications/{seq}/process", s.processNotification)
+	s.handle("DELETE /api/v1/notifications/{seq}", s.removeNotification)
+	s.handle("GET /api/v1/jobs", s.getJobs)
+	s.handle("DELETE /api/v1/jobs/{id}", s.cancelJob)
+	s.mux.Handle("GET /api/v1/events", s.authorize(http.HandlerFunc(s.streamEvents)))
+	return s
+}
+
+// Handler 返回 API 的 http.Handler
+func (s *APIServer) Handler() http.Handler {
+	return s.mux
+}
+
+// Start 在 address 上开始监听，address 为 host:port 或 unix:/path
+func (s *APIServer) Start(address string) error {
+	listener, err := listenAPI(address)
+	if err != nil {
+		return err
+	}
+	s.listener = listener
+	s.server = &http.Server{Handler: s.mux, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		if err := s.server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) && ConfigInstance.LogFile != nil {
+			fmt.Fprintln(ConfigInstance.LogFile, "api server:", err)
+		}
+	}()
+	return nil
+}
+
+// Addr 返回实际监听的地址
+func (s *APIServer) Addr() string {
+	if s.listener == nil {
+		return ""
+	}
+	return s.listener.Addr().String()
+}
+
+// Close 断开事件流并停止服务，正在执行的 lpac 命令不受影响
+func (s *APIServer) Close() error {
+	s.closing.Do(func() { close(s.done) })
+	if s.server == nil {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	return s.server.Shutdown(ctx)
+}
+
+// apiHandler 返回的值以 JSON 输出，错误按类型转换为 HTTP 状态码
+type apiHandler func(r *http.Request) (any, error)
+
+func (s *APIServer) handle(pattern string, h apiHandler) {
+	s.mux.Handle(pattern, s.authorize(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		v, err := h(r)
+		if err != nil {
+			writeJSON(w, apiStatus(err), map[string]errorInfo{"error": newErrorInfo(err)})
+			return
+		}
+		writeJSON(w, http.StatusOK, v)
+	})))
+}
+
+// authorize 检查 Authorization: Bearer 或 token 参数，浏览器的 EventSource 无法设置请求头
+func (s *APIServer) authorize(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok {
+			token = r.URL.Query().Get("token")
+		}
+		if s.token == "" || subtle.ConstantTimeCompare([]byte(token), []byte(s.token)) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			writeJSON(w, http.StatusUnauthorized, map[string]errorInfo{"error": {Error: "unauthorized"}})
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// notFoundError 表示请求的 Profile、通知或任务不存在
+type notFoundError string
+
+func (e notFoundError) Error() string {
+	return string(e)
+}
+
+// conflictError 表示请求与正在进行的操作冲突
+type conflictError string
+
+func (e conflictError) Error() string {
+	return string(e)
+}
+
+// apiStatus 根据错误类型返回 HTTP 状态码
+func apiStatus(err error) int {
+	var usage usageError
+	var notFound notFoundError
+	var conflict conflictError
+	switch {
+	case errors.As(err, &usage):
+		return http.StatusBadRequest
+	case errors.As(err, &notFound):
+		return http.StatusNotFound
+	case errors.As(err, &conflict):
+		return http.StatusConflict
+	}
+	lpacErr, ok := AsLpacError(err)
+	if !ok {
+		return http.StatusInternalServerError
+	}
+	switch lpacErr.Class {
+	case ErrorClassNetwork, ErrorClassServer:
+		return http.StatusBadGateway
+	case ErrorClassTimeout:
+		return http.StatusGatewayTimeout
+	case ErrorClassReader:
+		return http.StatusServiceUnavailable
+	case ErrorClassCard, ErrorClassCancelled:
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+func decodeBody(r *http.Request, v any) error {
+	decoder := json.NewDecoder(http.MaxBytesReader(nil, r.Body, 64*1024))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(v); err != nil {
+		return usageError("invalid request body: " + err.Error())
+	}
+	return nil
+}
+
+// notifyParam 读取 notify 参数，未指定时按通知策略处理，策略为询问的通知保留在卡片上
+func notifyParam(r *http.Request) (bool, error) {
+	value := r.URL.Query().Get("notify")
+	if value == "" {
+		return true, nil
+	}
+	notify, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, usageError("invalid notify parameter: " + value)
+	}
+	return notify, nil
+}
+
+// selectReader 是非界面模式下选择读卡器的方式
+func selectReader(d *ApduDriver) {
+	ConfigInstance.DriverIFID = d.Env
+	ConfigInstance.ReaderName = d.Name
+	ConfigChanged()
+	ApplyReaderMemory(d.Name)
+}
+
+// StatusView 是 API 的当前状态
+type StatusView struct {
+	Version  string `json:"version"`
+	Reader   string `json:"reader"`
+	ReaderID string `json:"reader_id"`
+	AID      string `json:"aid"`
+	Running  int    `json:"running"`
+	Queued   int    `json:"queued"`
+	// NotificationPolicy 是操作产生的通知的处理方式
+	NotificationPolicy *NotificationPolicy `json:"notification_policy"`
+}
+
+func currentStatus() *StatusView {
+	running, queued := CardJobs.Counts()
+	return &StatusView{
+		Version:  Version,
+		Reader:   ConfigInstance.ReaderName,
+		ReaderID: ConfigInstance.DriverIFID,
+		AID:      ConfigInstance.LpacAID,
+		Running:  running,
+		Queued:   queued,
+
+		NotificationPolicy: currentNotificationPolicy(),
+	}
+}
+
+func (s *APIServer) getStatus(*http.Request) (any, error) {
+	return currentStatus(), nil
+}
+
+func (s *APIServer) getReaders(*http.Request) (any, error) {
+	drivers, err := ListApduDrivers()
+	if drivers == nil {
+		drivers = []*ApduDriver{}
+	}
+	return drivers, err
+}
+
+func (s *APIServer) putReader(r *http.Request) (any, error) {
+	var body struct {
+		Name string `json:"name"`
+	}
+	if err := decodeBody(r, &body); err != nil {
+		return nil, err
+	}
+	if body.Name == "" {
+		return nil, usageError("name is required")
+	}
+	d, err := FindReader(body.Name)
+	if err != nil {
+		return nil, err
+	}
+	// 正在执行的操作的后续命令会使用新的读卡器，只能在空闲时更换
+	if CardJobs.Busy() {
+		return nil, conflictError("card reader is busy, try again after the running operations finish")
+	}
+	s.SelectReader(d)
+	status := currentStatus()
+	Events.Publish(Event{Type: "status", Status: status})
+	return status, nil
+}
+
+// requireReader 检查是否已经选择读卡器
+func requireReader() error {
+	if ConfigInstance.DriverIFID == "" {
+		return readerNotFound("no card reader selected")
+	}
+	return nil
+}
+
+func (s *APIServer) getChip(*http.Request) (any, error) {
+	if err := requireReader(); err != nil {
+		return nil, err
+	}
+	info, err := LpacChipInfo()
+	if err != nil {
+		return nil, err
+	}
+	result := struct {
+		*EuiccInfo
+		EUM *eumResult `json:"eum,omitempty"`
+	}{EuiccInfo: info}
+	if eum := GetEUM(info.EidValue); eum != nil {
+		result.EUM = &eumResult{EID: info.EidValue, EUM: eum.EUM, Manufacturer: eum.Manufacturer,
+			Country: eum.Country, Product: eum.ProductName(info.EidValue)}
+	}
+	return result, nil
+}
+
+func (s *APIServer) getProfiles(*http.Request) (any, error) {
+	if err := requireReader(); err != nil {
+		return nil, err
+	}
+	profiles, err := LpacProfileList()
+	if profiles == nil {
+		profiles = []*Profile{}
+	}
+	return profiles, err
+}
+
+// profileOperation 生成启用、禁用、删除 Profile 的处理函数
+func (s *APIServer) profileOperation(command string, run func(iccid string) error) apiHandler {
+	return func(r *http.Request) (any, error) {
+		if err := requireReader(); err != nil {
+			return nil, err
+		}
+		notify, err := notifyParam(r)
+		if err != nil {
+			return nil, err
+		}
+		iccid := r.PathValue("iccid")
+		outcomes, err := RunWithNotifications(notify, func() error { return run(iccid) })
+		if err != nil {
+			return nil, err
+		}
+		s.CardChanged()
+		result, _ := NewOperationResult(command, iccid, outcomes)
+		return result, nil
+	}
+}
+
+func (s *APIServer) putNickname(r *http.Request) (any, error) {
+	if err := requireReader(); err != nil {
+		return nil, err
+	}
+	var body struct {
+		Nickname string `json:"nickname"`
+	}
+	if err := decodeBody(r, &body); err != nil {
+		return nil, err
+	}
+	iccid := r.PathValue("iccid")
+	if err := LpacProfileNickname(iccid, body.Nickname); err != nil {
+		return nil, err
+	}
+	s.CardChanged()
+	result, _ := NewOperationResult("profile nickname", iccid, nil)
+	return result, nil
+}
+
+func (s *APIServer) downloadProfile(r *http.Request) (any, error) {
+	if err := requireReader(); err != nil {
+		return nil, err
+	}
+	notify, err := notifyParam(r)
+	if err != nil {
+		return nil, err
+	}
+	var body struct {
+		ActivationCode   string `json:"activation_code"`
+		SMDP             string `json:"smdp"`
+		MatchingID       string `json:"matching_id"`
+		ConfirmationCode string `json:"confirmation_code"`
+		IMEI             string `json:"imei"`
+	}
+	if err := decodeBody(r, &body); err != nil {
+		return nil, err
+	}
+	info := PullInfo{SMDP: body.SMDP, MatchID: body.MatchingID, ConfirmCode: body.ConfirmationCode, IMEI: body.IMEI}
+	if info.IMEI == "" {
+		info.IMEI = CurrentCardMemory().IMEI
+	}
+	if body.ActivationCode != "" {
+		if err := applyActivationCode(&info, body.ActivationCode); err != nil {
+			return nil, err
+		}
+	}
+	if err := validatePullInfo(info); err != nil {
+		return nil, err
+	}
+	iccid, outcomes, err := DownloadWithNotifications(info, notify)
+	if err != nil {
+		return nil, err
+	}
+	s.CardChanged()
+	result, _ := NewOperationResult("profile download", iccid, outcomes)
+	return result, nil
+}
+
+func (s *APIServer) getNotifications(*http.Request) (any, error) {
+	if err := requireReader(); err != nil {
+		return nil, err
+	}
+	notifications, err := LpacNotificationList()
+	if notifications == nil {
+		notifications = []*Notification{}
+	}
+	return notifications, err
+}
+
+// pathNotification 按路径中的序号查找通知
+func pathNotification(r *http.Request) (*Notification, error) {
+	if err := requireReader(); err != nil {
+		return nil, err
+	}
+	seq, err := strconv.Atoi(r.PathValue("seq"))
+	if err != nil {
+		return nil, usageError("invalid sequence number: " + r.PathValue("seq"))
+	}
+	notifications, err := LpacNotificationList()
+	if err != nil {
+		return nil, err
+	}
+	notification := findNotificationBySeq(notifications, seq)
+	if notification == nil {
+		return nil, notFoundError(fmt.Sprintf("notification %d not found", seq))
+	}
+	return notification, nil
+}
+
+func (s *APIServer) processNotification(r *http.Request) (any, error) {
+	defer CardJobs.Hold()()
+	notification, err := pathNotification(r)
+	if err != nil {
+		return nil, err
+	}
+	remove, _ := strconv.ParseBool(r.URL.Query().Get("remove"))
+	if err := LpacNotificationProcess(notification.SeqNumber, remove); err != nil {
+		return nil, err
+	}
+	s.CardChanged()
+	result, _ := NewOperationResult("notification process", notification.Iccid,
+		[]NotificationOutcome{{Notification: notification, Action: processAction(remove), Removed: remove}})
+	return result, nil
+}
+
+func (s *APIServer) removeNotification(r *http.Request) (any, error) {
+	defer CardJobs.Hold()()
+	notification, err := pathNotification(r)
+	if err != nil {
+		return nil, err
+	}
+	if err := LpacNotificationRemove(notification.SeqNumber); err != nil {
+		return nil, err
+	}
+	s.CardChanged()
+	result, _ := NewOperationResult("notification remove", notification.Iccid,
+		[]NotificationOutcome{{Notification: notification, Action: ActionRemove, Removed: true}})
+	return result, nil
+}
+
+func (s *APIServer) getJobs(*http.Request) (any, error) {
+	jobs := CardJobs.Snapshot()
+	views := make([]*JobView, 0, len(jobs))
+	for i := range jobs {
+		views = append(views, newJobView(&jobs[i]))
+	}
+	return views, nil
+}
+
+func (s *APIServer) cancelJob(r *http.Request) (any, error) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		return nil, usageError("invalid job id: " + r.PathValue("id"))
+	}
+	if !CardJobs.Cancel(id) {
+		return nil, notFoundError(fmt.Sprintf("job %d is not running or queued", id))
+	}
+	return map[string]int{"cancelled": id}, nil
+}
+
+// sseKeepAlive 是事件流的心跳间隔，防止代理关闭空闲连接
+const sseKeepAlive = 15 * time.Second
+
+// streamEvents 以 Server-Sent Events 推送任务状态和 lpac 进度，连接后先发送一次当前状态
+func (s *APIServer) streamEvents(w http.ResponseWriter, r *http.Request) {
+	controller := http.NewResponseController(w)
+	events, cancel := Events.Subscribe()
+	defer cancel()
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+
+	send := func(e Event) bool {
+		data, err := json.Marshal(e)
+		if err != nil {
+			return true
+		}
+		if _, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", e.Type, data); err != nil {
+			return false
+		}
+		return controller.Flush() == nil
+	}
+	if !send(Event{Type: "status", Time: time.Now(), Status: currentStatus()}) {
+		return
+	}
+	ticker := time.NewTicker(sseKeepAlive)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-s.done:
+			return
+		case e, ok := <-events:
+			if !ok || !send(e) {
+				return
+			}
+		case <-ticker.C:
+			if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil || controller.Flush() != nil {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testAPIToken = "test-token"
+
+// useAPIServer 启动使用 FakeLpac 的 API 服务并选择模拟读卡器
+func useAPIServer(t *testing.T) (*FakeLpac, *httptest.Server) {
+	fake := useFakeLpac(t)
+	useConfigFile(t, "")
+	api := NewAPIServer(testAPIToken)
+	server := httptest.NewServer(api.Handler())
+	t.Cleanup(func() {
+		api.Close()
+		server.Close()
+	})
+	resp := apiRequest(t, server, http.MethodPut, "/api/v1/reader", `{"name":"Fake Card Reader 00 00"}`)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	return fake, server
+}
+
+func apiRequest(t *testing.T, server *httptest.Server, method, path, body string) *http.Response {
+	req, err := http.NewRequest(method, server.URL+path, strings.NewReader(body))
+	require.NoError(t, err)
+	req.Header.Set("Authorization", "Bearer "+testAPIToken)
+	resp, err := server.Client().Do(req)
+	require.NoError(t, err)
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+func TestAPIRequiresToken(t *testing.T) {
+	_, server := useAPIServer(t)
+
+	resp, err := server.Client().Get(server.URL + "/api/v1/status")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	resp, err = server.Client().Get(server.URL + "/api/v1/status?token=" + testAPIToken)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var status StatusView
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&status))
+	assert.Equal(t, "Fake Card Reader 00 00", status.Reader)
+	assert.Equal(t, "0", status.ReaderID)
+}
+
+func TestAPIProfileOperations(t *testing.T) {
+	fake, server := useAPIServer(t)
+
+	resp := apiRequest(t, server, http.MethodPost, "/api/v1/profiles/8988303000000000010/enable?notify=true", "")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var result OperationResult
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&result))
+	assert.Len(t, result.Notifications, 2)
+	assert.Empty(t, fake.Notifications)
+
+	resp = apiRequest(t, server, http.MethodGet, "/api/v1/profiles", "")
+	var profiles []*Profile
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&profiles))
+	assert.Equal(t, "enabled", profiles[1].ProfileState)
+
+	fake.Fail("profile disable", &FakeFailure{Function: "es10c_disable_profile", Data: "catBusy"})
+	resp = apiRequest(t, server, http.MethodPost, "/api/v1/profiles/8988303000000000010/disable", "")
+	assert.Equal(t, http.StatusConflict, resp.StatusCode)
+	var body map[string]errorInfo
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+	assert.Equal(t, "cat_busy", body["error"].Key)
+
+	resp = apiRequest(t, server, http.MethodPost, "/api/v1/profiles", `{"matching_id":"MISSING"}`)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	resp = apiRequest(t, server, http.MethodDelete, "/api/v1/notifications/99", "")
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
+
+func TestAPIEventStream(t *testing.T) {
+	_, server := useAPIServer(t)
+
+	resp, err := server.Client().Get(server.URL + "/api/v1/events?token=" + testAPIToken)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+
+	events := make(chan Event, 16)
+	go func() {
+		scanner := bufio.NewScanner(resp.Body)
+		for scanner.Scan() {
+			if data, ok := strings.CutPrefix(scanner.Text(), "data: "); ok {
+				var e Event
+				if json.Unmarshal([]byte(data), &e) == nil {
+					events <- e
+				}
+			}
+		}
+		close(events)
+	}()
+	next := func() Event {
+		select {
+		case e := <-events:
+			return e
+		case <-time.After(2 * time.Second):
+			t.Fatal("no event received")
+			return Event{}
+		}
+	}
+
+	first := next()
+	assert.Equal(t, "status", first.Type)
+	require.NotNil(t, first.Status)
+
+	_, err = LpacChipInfo()
+	require.NoError(t, err)
+	var states []string
+	for len(states) < 3 {
+		if e := next(); e.Type == "job" && e.Job.Command == "chip info" {
+			states = append(states, e.Job.State)
+		}
+	}
+	assert.Equal(t, []string{"queued", "running", "succeeded"}, states)
+}
+
+func TestValidateAPIListen(t *testing.T) {
+	for _, address := range []string{"127.0.0.1:8077", "[::1]:8077", "localhost:0", "unix:/run/easylpac.sock"} {
+		assert.NoError(t, validateAPIListen(address), address)
+	}
+	for _, address := range []string{"0.0.0.0:8077", "192.168.1.2:8077", ":8077", "unix:", "localhost"} {
+		assert.Error(t, validateAPIListen(address), address)
+	}
+}
+
+func TestAPIReaderBusy(t *testing.T) {
+	_, server := useAPIServer(t)
+	release := CardJobs.Hold()
+	resp := apiRequest(t, server, http.MethodPut, "/api/v1/reader", `{"name":"Fake Card Reader 00 00"}`)
+	assert.Equal(t, http.StatusConflict, resp.StatusCode)
+	release()
+	resp = apiRequest(t, server, http.MethodPut, "/api/v1/reader", `{"name":"Fake Card Reader 00 00"}`)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
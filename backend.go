@@ -0,0 +1,64 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// LpacBackend 负责实际执行一条 lpac 命令
+// 实现需要把 lpac 的 JSON 输出逐行写入 stdout，由 runLpac 统一解析
+// job 提供命令参数以及要使用的读卡器和 AID
+// ctx 被取消或超时后实现应尽快返回
+type LpacBackend interface {
+	Run(ctx context.Context, job *Job, stdout io.Writer) error
+}
+
+// Backend 是当前使用的 lpac 后端，测试中可以替换为 FakeLpac
+var Backend LpacBackend = &ExecBackend{}
+
+// ExecBackend 通过执行 lpac 可执行文件来运行命令
+type ExecBackend struct{}
+
+func (b *ExecBackend) Run(ctx context.Context, job *Job, stdout io.Writer) error {
+	args := job.Args
+	// Save to LogFile
+	lpacPath := filepath.Join(ConfigInstance.LpacDir, ConfigInstance.EXEName)
+	command := lpacPath
+	for _, arg := range args {
+		command += fmt.Sprintf(" %s", arg)
+	}
+	if _, err := fmt.Fprintln(ConfigInstance.LogFile, command); err != nil {
+		return err
+	}
+
+	// 超时或取消时先让 lpac 自行退出，超过 WaitDelay 后强制结束
+	cmd := exec.CommandContext(ctx, lpacPath, args...)
+	HideCmdWindow(cmd)
+	InterruptCmdOnCancel(cmd)
+	cmd.WaitDelay = 3 * time.Second
+
+	cmd.Dir = ConfigInstance.LpacDir
+
+	cmd.Env = lpacEnv(job)
+	var stderr bytes.Buffer
+	cmd.Stdout = io.MultiWriter(stdout, ConfigInstance.LogFile)
+	cmd.Stderr = io.MultiWriter(ConfigInstance.LogFile, &stderr)
+
+	err := cmd.Run()
+	if err != nil && len(bytes.TrimSpace(stderr.Bytes())) != 0 {
+		// fixme
+		// if lpac debug enabled, some lpac debug output will write to stderr if something went wrong
+		// It shouldn't return here if it not pcsc error
+		if strings.Contains(stderr.String(), "SCard") {
+			return errors.New(stderr.String())
+		}
+	}
+	return nil
+}
@@ -0,0 +1,374 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FakeLpac 是一个进程内模拟的 eUICC，实现了 LpacBackend
+// 它按照 lpac 的格式输出 JSON，因此 cmd.go 之上的逻辑无需读卡器和实体卡即可测试
+type FakeLpac struct {
+	mu sync.Mutex
+
+	Version       string
+	Drivers       []*ApduDriver
+	Chip          EuiccInfo
+	Profiles      []*Profile
+	Notifications []*Notification
+	// Downloadable 以 Matching ID 为键，下载时安装对应的 Profile
+	Downloadable map[string]*Profile
+	// Failures 以命令（如 "profile enable"）为键注入错误
+	Failures map[string]*FakeFailure
+	// Delays 以命令为键模拟耗时，用于测试超时和取消
+	Delays map[string]time.Duration
+	// AID 不为空时，使用其他 ISD-R AID 的命令会像 lpac 一样在 euicc_init 失败
+	AID string
+	// Calls 记录收到的所有命令
+	Calls [][]string
+	// Removed 为 true 时模拟读卡器中没有卡片
+	Removed bool
+
+	addresses map[string]string
+	nextSeq   int
+	steps     []string
+}
+
+// FakeFailure 描述一次注入的 lpac 失败
+type FakeFailure struct {
+	// Function 和 Data 对应 lpac 失败时输出的 message 和 data
+	Function string
+	Data     string
+	// Err 不为 nil 时模拟 lpac 本身无法运行，例如 PC/SC 错误
+	Err error
+}
+
+const fakeDefaultSmdp = "smdp.example.com"
+
+func NewFakeLpac() *FakeLpac {
+	f := &FakeLpac{
+		Version: "v2.2.1-fake",
+		Drivers: []*ApduDriver{
+			{Env: "0", Name: "Fake Card Reader 00 00"},
+		},
+		Downloadable: make(map[string]*Profile),
+		Failures:     make(map[string]*FakeFailure),
+		Delays:       make(map[string]time.Duration),
+		addresses:    make(map[string]string),
+		nextSeq:      1,
+	}
+	f.Chip.EidValue = "89049032123451234512345678901235"
+	f.Chip.EuiccConfiguredAddresses.RootDsAddress = "lpa.ds.gsma.com"
+	f.Chip.EUICCInfo2.ExtCardResource.FreeNonVolatileMemory = 300 * 1024
+	return f
+}
+
+// AddProfile 直接向模拟卡片中放入一个 Profile，不产生通知
+func (f *FakeLpac) AddProfile(p *Profile) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.Profiles = append(f.Profiles, p)
+}
+
+// Fail 为指定命令注入错误，直到调用 ClearFailures
+func (f *FakeLpac) Fail(command string, failure *FakeFailure) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.Failures[command] = failure
+}
+
+// InsertCard 模拟换上一张只有 EID 的空白卡片
+func (f *FakeLpac) InsertCard(eid string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.Removed = false
+	f.Chip.EidValue = eid
+	f.Profiles = nil
+	f.Notifications = nil
+}
+
+// RemoveCard 模拟取出卡片
+func (f *FakeLpac) RemoveCard() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.Removed = true
+}
+
+func (f *FakeLpac) ClearFailures() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.Failures = make(map[string]*FakeFailure)
+}
+
+func (f *FakeLpac) Run(ctx context.Context, job *Job, stdout io.Writer) error {
+	args := job.Args
+	command := strings.Join(args[:min(len(args), 2)], " ")
+	f.mu.Lock()
+	delay := f.Delays[command]
+	f.mu.Unlock()
+	if delay > 0 {
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.Calls = append(f.Calls, args)
+
+	if failure, ok := f.Failures[command]; ok {
+		if failure.Err != nil {
+			return failure.Err
+		}
+		return writeLpa(stdout, -1, failure.Function, failure.Data)
+	}
+	if f.Removed && job.needsCard() {
+		return errors.New("SCardConnect() failed: 8010000C")
+	}
+	if f.AID != "" && job.needsCard() && !strings.EqualFold(job.AID, f.AID) {
+		return writeLpa(stdout, -1, "euicc_init", "")
+	}
+
+	f.steps = nil
+	data, fn, err := f.dispatch(command, args[min(len(args), 2):])
+	for _, step := range f.steps {
+		if err := writeLine(stdout, "progress", 0, step, nil); err != nil {
+			return err
+		}
+	}
+	if err != nil {
+		return writeLpa(stdout, -1, fn, err.Error())
+	}
+	return writeLpa(stdout, 0, "success", data)
+}
+
+func (f *FakeLpac) dispatch(command string, args []string) (any, string, error) {
+	switch command {
+	case "version":
+		return f.Version, "", nil
+	case "driver apdu":
+		return f.Drivers, "", nil
+	case "chip info":
+		return f.Chip, "", nil
+	case "chip defaultsmdp":
+		if len(args) == 0 || args[0] == "" {
+			f.Chip.EuiccConfiguredAddresses.DefaultDpAddress = nil
+		} else {
+			f.Chip.EuiccConfiguredAddresses.DefaultDpAddress = args[0]
+		}
+		return nil, "", nil
+	case "profile list":
+		return f.Profiles, "", nil
+	case "profile enable":
+		return nil, "es10c_enable_profile", f.enable(arg(args, 0))
+	case "profile disable":
+		return nil, "es10c_disable_profile", f.disable(arg(args, 0))
+	case "profile delete":
+		return nil, "es10c_delete_profile", f.delete(arg(args, 0))
+	case "profile nickname":
+		p := f.findProfile(arg(args, 0))
+		if p == nil {
+			return nil, "es10c_set_nickname", errors.New("iccidNotFound")
+		}
+		if nickname := arg(args, 1); nickname != "" {
+			p.ProfileNickname = &nickname
+		} else {
+			p.ProfileNickname = nil
+		}
+		return nil, "", nil
+	case "profile download":
+		fn, err := f.download(args)
+		return nil, fn, err
+	case "notification list":
+		return f.Notifications, "", nil
+	case "notification process":
+		remove := len(args) > 1 && args[0] == "-r"
+		seq, err := strconv.Atoi(arg(args, len(args)-1))
+		if err != nil || f.findNotification(seq) < 0 {
+			return nil, "es10b_retrieve_notifications_list", errors.New("notification not found")
+		}
+		if remove {
+			f.removeNotification(seq)
+		}
+		return nil, "", nil
+	case "notification remove":
+		seq, err := strconv.Atoi(arg(args, 0))
+		if err != nil || f.findNotification(seq) < 0 {
+			return nil, "es10b_remove_notification_from_list", errors.New("notification not found")
+		}
+		f.removeNotification(seq)
+		return nil, "", nil
+	}
+	return nil, "unknown", fmt.Errorf("unsupported command: %s", command)
+}
+
+func (f *FakeLpac) enable(iccid string) error {
+	p := f.findProfile(iccid)
+	if p == nil {
+		return errors.New("iccidOrAidNotFound")
+	}
+	if p.ProfileState == "enabled" {
+		return errors.New("profileNotInDisabledState")
+	}
+	for _, other := range f.Profiles {
+		if other.ProfileState == "enabled" {
+			other.ProfileState = "disabled"
+			f.addNotification("disable", other.Iccid)
+		}
+	}
+	p.ProfileState = "enabled"
+	f.addNotification("enable", p.Iccid)
+	return nil
+}
+
+func (f *FakeLpac) disable(iccid string) error {
+	p := f.findProfile(iccid)
+	if p == nil {
+		return errors.New("iccidOrAidNotFound")
+	}
+	if p.ProfileState != "enabled" {
+		return errors.New("profileNotInEnabledState")
+	}
+	p.ProfileState = "disabled"
+	f.addNotification("disable", p.Iccid)
+	return nil
+}
+
+func (f *FakeLpac) delete(iccid string) error {
+	p := f.findProfile(iccid)
+	if p == nil {
+		return errors.New("iccidOrAidNotFound")
+	}
+	if p.ProfileState == "enabled" {
+		return errors.New("profileNotInDisabledState")
+	}
+	for i, profile := range f.Profiles {
+		if profile == p {
+			f.Profiles = append(f.Profiles[:i], f.Profiles[i+1:]...)
+			break
+		}
+	}
+	f.addNotification("delete", iccid)
+	return nil
+}
+
+// download 模拟 lpac 的下载流程，返回失败时所处的步骤
+func (f *FakeLpac) download(args []string) (string, error) {
+	var info PullInfo
+	for i := 0; i+1 < len(args); i += 2 {
+		switch args[i] {
+		case "-s":
+			info.SMDP = args[i+1]
+		case "-m":
+			info.MatchID = args[i+1]
+		case "-c":
+			info.ConfirmCode = args[i+1]
+		case "-i":
+			info.IMEI = args[i+1]
+		}
+	}
+	if info.SMDP == "" {
+		info.SMDP = fakeDefaultSmdp
+	}
+	f.steps = append(f.steps,
+		"es10b_get_euicc_challenge_and_info",
+		"es9p_initiate_authentication",
+		"es10b_authenticate_server",
+		"es9p_authenticate_client")
+	template, ok := f.Downloadable[info.MatchID]
+	if !ok {
+		return "es9p_authenticate_client", errors.New(`{"subjectCode":"8.2.6","reasonCode":"3.8","message":"Refused"}`)
+	}
+	f.steps = append(f.steps,
+		"es10b_prepare_download",
+		"es9p_get_bound_profile_package",
+		"es8p_meatadata_parse",
+		"es10b_load_bound_profile_package")
+	if f.findProfile(template.Iccid) != nil {
+		return "es10b_load_bound_profile_package", errors.New("installFailedDueToIccidAlreadyExistsOnEuicc")
+	}
+	p := *template
+	p.ProfileState = "disabled"
+	f.Profiles = append(f.Profiles, &p)
+	f.addresses[p.Iccid] = info.SMDP
+	delete(f.Downloadable, info.MatchID)
+	f.addNotification("install", p.Iccid)
+	return "", nil
+}
+
+func (f *FakeLpac) addNotification(operation, iccid string) {
+	address, ok := f.addresses[iccid]
+	if !ok {
+		address = fakeDefaultSmdp
+	}
+	f.Notifications = append(f.Notifications, &Notification{
+		SeqNumber:                  f.nextSeq,
+		ProfileManagementOperation: operation,
+		NotificationAddress:        address,
+		Iccid:                      iccid,
+	})
+	f.nextSeq++
+}
+
+func (f *FakeLpac) removeNotification(seq int) {
+	if i := f.findNotification(seq); i >= 0 {
+		f.Notifications = append(f.Notifications[:i], f.Notifications[i+1:]...)
+	}
+}
+
+func (f *FakeLpac) findNotification(seq int) int {
+	for i, n := range f.Notifications {
+		if n.SeqNumber == seq {
+			return i
+		}
+	}
+	return -1
+}
+
+func (f *FakeLpac) findProfile(iccid string) *Profile {
+	for _, p := range f.Profiles {
+		if p.Iccid == iccid {
+			return p
+		}
+	}
+	return nil
+}
+
+func arg(args []string, i int) string {
+	if i < 0 || i >= len(args) {
+		return ""
+	}
+	return args[i]
+}
+
+// writeLpa 按 lpac 的格式输出最终结果
+// 失败时 lpac 的 data 是一个字符串
+func writeLpa(w io.Writer, code int, message string, data any) error {
+	return writeLine(w, "lpa", code, message, data)
+}
+
+func writeLine(w io.Writer, typ string, code int, message string, data any) error {
+	var resp LpacReturnValue
+	resp.Type = typ
+	resp.Payload.Code = code
+	resp.Payload.Message = message
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	resp.Payload.Data = raw
+	line, err := json.Marshal(resp)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "%s\n", line)
+	return err
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// useFakeLpac 在测试期间把 Backend 替换为预置两个 Profile 的 FakeLpac
+func useFakeLpac(t *testing.T) *FakeLpac {
+	fake := NewFakeLpac()
+	fake.AddProfile(&Profile{Iccid: "8988303000000000002", ProfileState: "enabled", ServiceProviderName: "Home", ProfileClass: "operational"})
+	fake.AddProfile(&Profile{Iccid: "8988303000000000010", ProfileState: "disabled", ServiceProviderName: "Roaming", ProfileClass: "operational"})
+	origin := Backend
+	Backend = fake
+	t.Cleanup(func() {
+		Backend = origin
+	})
+	return fake
+}
+
+func TestFakeLpacChipInfo(t *testing.T) {
+	fake := useFakeLpac(t)
+	info, err := LpacChipInfo()
+	require.NoError(t, err)
+	assert.Equal(t, fake.Chip.EidValue, info.EidValue)
+
+	require.NoError(t, LpacChipDefaultSmdp("smdp.example.org"))
+	info, err = LpacChipInfo()
+	require.NoError(t, err)
+	assert.Equal(t, "smdp.example.org", info.EuiccConfiguredAddresses.DefaultDpAddress)
+}
+
+func TestFakeLpacSwitchProfile(t *testing.T) {
+	useFakeLpac(t)
+	before, err := LpacNotificationList()
+	require.NoError(t, err)
+
+	require.NoError(t, LpacProfileEnable("8988303000000000010"))
+	profiles, err := LpacProfileList()
+	require.NoError(t, err)
+	assert.Equal(t, "disabled", profiles[0].ProfileState)
+	assert.Equal(t, "enabled", profiles[1].ProfileState)
+
+	after, err := LpacNotificationList()
+	require.NoError(t, err)
+	switchNotifications := findNewNotifications(before, after)
+	require.Len(t, switchNotifications, 2)
+	assert.Equal(t, "disable", switchNotifications[0].ProfileManagementOperation)
+	assert.Equal(t, "enable", switchNotifications[1].ProfileManagementOperation)
+
+	assert.Error(t, LpacProfileEnable("8988303000000000010"), "enabling an enabled profile")
+	assert.Error(t, LpacProfileDelete("8988303000000000010"), "deleting an enabled profile")
+}
+
+func TestFakeLpacDownloadAndNotification(t *testing.T) {
+	fake := useFakeLpac(t)
+	fake.Downloadable["MATCHING-ID"] = &Profile{Iccid: "8988303000000000028", ServiceProviderName: "New"}
+
+	assert.Error(t, LpacProfileDownload(PullInfo{SMDP: "rsp.example.com", MatchID: "WRONG"}))
+	require.NoError(t, LpacProfileDownload(PullInfo{SMDP: "rsp.example.com", MatchID: "MATCHING-ID"}))
+
+	profiles, err := LpacProfileList()
+	require.NoError(t, err)
+	require.Len(t, profiles, 3)
+	assert.Equal(t, "disabled", profiles[2].ProfileState)
+
+	notifications, err := LpacNotificationList()
+	require.NoError(t, err)
+	installNotification := findNewNotification(nil, notifications)
+	require.NotNil(t, installNotification)
+	assert.Equal(t, "install", installNotification.ProfileManagementOperation)
+	assert.Equal(t, "rsp.example.com", installNotification.NotificationAddress)
+
+	require.NoError(t, LpacNotificationProcess(installNotification.SeqNumber, true))
+	notifications, err = LpacNotificationList()
+	require.NoError(t, err)
+	assert.Empty(t, notifications)
+}
+
+func TestFakeLpacFailureInjection(t *testing.T) {
+	fake := useFakeLpac(t)
+	fake.Fail("profile disable", &FakeFailure{Function: "es10c_disable_profile", Data: "catBusy"})
+	err := LpacProfileDisable("8988303000000000002")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "es10c_disable_profile")
+
+	scardErr := errors.New("SCardConnect() failed: 8010000C")
+	fake.Fail("chip info", &FakeFailure{Err: scardErr})
+	_, err = LpacChipInfo()
+	assert.ErrorIs(t, err, scardErr)
+
+	fake.ClearFailures()
+	assert.NoError(t, LpacProfileDisable("8988303000000000002"))
+	assert.Equal(t, []string{"profile", "disable", "8988303000000000002"}, fake.Calls[len(fake.Calls)-1])
+}
+
+func TestFakeLpacDownloadProgress(t *testing.T) {
+	fake := useFakeLpac(t)
+	fake.Downloadable["MATCHING-ID"] = &Profile{Iccid: "8988303000000000028"}
+	for len(ProgressChan) > 0 {
+		<-ProgressChan
+	}
+
+	require.NoError(t, LpacProfileDownload(PullInfo{MatchID: "MATCHING-ID"}))
+	var stages []ProgressStage
+	var done bool
+	for len(ProgressChan) > 0 {
+		p := <-ProgressChan
+		assert.Equal(t, "profile download", p.Command)
+		if p.Done {
+			done = true
+			continue
+		}
+		if len(stages) == 0 || stages[len(stages)-1] != p.Stage {
+			stages = append(stages, p.Stage)
+		}
+	}
+	assert.Equal(t, DownloadStages, stages)
+	assert.True(t, done)
+}
+
+func TestFakeLpacTimeoutAndCancel(t *testing.T) {
+	fake := useFakeLpac(t)
+	origin := ConfigInstance.Timeouts
+	t.Cleanup(func() {
+		ConfigInstance.Timeouts = origin
+	})
+	ConfigInstance.Timeouts = LpacTimeouts{Query: 50 * time.Millisecond, Card: time.Second, Network: time.Minute}
+
+	fake.Delays["chip info"] = time.Second
+	_, err := LpacChipInfo()
+	assert.ErrorIs(t, err, ErrLpacTimeout)
+
+	fake.Delays["profile download"] = time.Minute
+	fake.Downloadable["MATCHING-ID"] = &Profile{Iccid: "8988303000000000028"}
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		CardJobs.CancelRunning()
+	}()
+	assert.ErrorIs(t, LpacProfileDownload(PullInfo{MatchID: "MATCHING-ID"}), ErrLpacCancelled)
+	profiles, err := LpacProfileList()
+	require.NoError(t, err)
+	assert.Len(t, profiles, 2, "cancelled download must not install the profile")
+}
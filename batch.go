@@ -0,0 +1,428 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// BatchStatus 是批量下载中一行的状态
+type BatchStatus int
+
+const (
+	BatchPending BatchStatus = iota
+	BatchRunning
+	BatchSucceeded
+	BatchFailed
+	// BatchInvalid 表示文件中的内容不合法，不会下载
+	BatchInvalid
+)
+
+func (s BatchStatus) String() string {
+	switch s {
+	case BatchRunning:
+		return "running"
+	case BatchSucceeded:
+		return "succeeded"
+	case BatchFailed:
+		return "failed"
+	case BatchInvalid:
+		return "invalid"
+	default:
+		return "pending"
+	}
+}
+
+// BatchRow 是激活码列表中的一行
+type BatchRow struct {
+	// Line 是行在文件中的位置，JSON 文件中是数组下标加一
+	Line     int
+	Info     PullInfo
+	Nickname string
+	Status   BatchStatus
+	Iccid    string
+	Err      error
+	// NicknameErr 是下载成功后设置昵称失败的原因
+	NicknameErr   error
+	Notifications []NotificationOutcome
+	Finished      time.Time
+}
+
+// batchColumns 把文件中的列名映射为统一的名称
+var batchColumns = map[string]string{
+	"activation_code":   "activation_code",
+	"code":              "activation_code",
+	"lpa":               "activation_code",
+	"smdp":              "smdp",
+	"smdp_address":      "smdp",
+	"sm_dp+":            "smdp",
+	"matching_id":       "matching_id",
+	"match_id":          "matching_id",
+	"matchingid":        "matching_id",
+	"confirmation_code": "confirmation_code",
+	"confirm_code":      "confirmation_code",
+	"imei":              "imei",
+	"nickname":          "nickname",
+}
+
+// batchDefaultColumns 是没有表头的 CSV 文件中各列的含义
+var batchDefaultColumns = []string{"activation_code", "confirmation_code", "imei", "nickname"}
+
+func batchColumn(name string) string {
+	name = strings.ToLower(strings.TrimSpace(name))
+	name = strings.NewReplacer(" ", "_", "-", "_").Replace(name)
+	return batchColumns[name]
+}
+
+// ParseBatchFile 解析 CSV 或 JSON 格式的激活码列表，不合法的行标记为 BatchInvalid
+func ParseBatchFile(name string, data []byte) ([]*BatchRow, error) {
+	data = bytes.TrimPrefix(data, []byte("\xEF\xBB\xBF"))
+	trimmed := bytes.TrimSpace(data)
+	var rows []*BatchRow
+	var err error
+	if strings.EqualFold(filepath.Ext(name), ".json") || bytes.HasPrefix(trimmed, []byte("[")) {
+		rows, err = parseBatchJSON(trimmed)
+	} else {
+		rows, err = parseBatchCSV(data)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return nil, errors.New("no activation codes found")
+	}
+	// 同一个 Matching ID 只能下载一次
+	seen := make(map[string]int)
+	for _, row := range rows {
+		if row.Status == BatchInvalid || row.Info.MatchID == "" {
+			continue
+		}
+		key := row.Info.SMDP + "$" + row.Info.MatchID
+		if line, ok := seen[key]; ok {
+			row.Status = BatchInvalid
+			row.Err = fmt.Errorf("duplicate of line %d", line)
+			continue
+		}
+		seen[key] = row.Line
+	}
+	return rows, nil
+}
+
+func parseBatchCSV(data []byte) ([]*BatchRow, error) {
+	r := csv.NewReader(bytes.NewReader(data))
+	r.FieldsPerRecord = -1
+	r.TrimLeadingSpace = true
+	r.Comment = '#'
+	var rows []*BatchRow
+	columns := batchDefaultColumns
+	for first := true; ; first = false {
+		record, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if first && isBatchHeader(record) {
+			columns = make([]string, len(record))
+			for i, name := range record {
+				columns[i] = batchColumn(name)
+			}
+			continue
+		}
+		if strings.TrimSpace(strings.Join(record, "")) == "" {
+			continue
+		}
+		fields := make(map[string]string)
+		for i, value := range record {
+			if i < len(columns) && columns[i] != "" {
+				fields[columns[i]] = strings.TrimSpace(value)
+			}
+		}
+		line, _ := r.FieldPos(0)
+		rows = append(rows, newBatchRow(line, fields))
+	}
+	return rows, nil
+}
+
+// isBatchHeader 判断 CSV 的第一行是否为表头
+func isBatchHeader(record []string) bool {
+	for _, name := range record {
+		if batchColumn(name) != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// parseBatchJSON 解析激活码字符串或对象组成的数组
+func parseBatchJSON(data []byte) ([]*BatchRow, error) {
+	var items []json.RawMessage
+	if err := json.Unmarshal(data, &items); err != nil {
+		return nil, err
+	}
+	var rows []*BatchRow
+	for i, item := range items {
+		fields := make(map[string]string)
+		var code string
+		if err := json.Unmarshal(item, &code); err == nil {
+			fields["activation_code"] = strings.TrimSpace(code)
+		} else {
+			var object map[string]string
+			if err = json.Unmarshal(item, &object); err != nil {
+				rows = append(rows, &BatchRow{Line: i + 1, Status: BatchInvalid, Err: errors.New("expected a string or an object of strings")})
+				continue
+			}
+			for name, value := range object {
+				if column := batchColumn(name); column != "" {
+					fields[column] = strings.TrimSpace(value)
+				}
+			}
+		}
+		rows = append(rows, newBatchRow(i+1, fields))
+	}
+	return rows, nil
+}
+
+// newBatchRow 用激活码或单独的 SM-DP+、Matching ID 列生成下载参数并检查
+func newBatchRow(line int, fields map[string]string) *BatchRow {
+	row := &BatchRow{
+		Line: line,
+		Info: PullInfo{
+			SMDP:        fields["smdp"],
+			MatchID:     fields["matching_id"],
+			ConfirmCode: fields["confirmation_code"],
+			IMEI:        fields["imei"],
+		},
+		Nickname: fields["nickname"],
+	}
+	var err error
+	if code := fields["activation_code"]; code != "" {
+		err = applyActivationCode(&row.Info, code)
+	}
+	if err == nil {
+		err = validatePullInfo(row.Info)
+	}
+	if err != nil {
+		row.Status = BatchInvalid
+		row.Err = err
+	}
+	return row
+}
+
+// Batch 把激活码列表依次下载到当前卡片
+type Batch struct {
+	mu   sync.Mutex
+	rows []*BatchRow
+	// NicknameTemplate 用于没有 nickname 列的行，支持 ExpandNicknameTemplate 的占位符
+	NicknameTemplate string
+	// Notify 为 true 时按通知策略处理 install 通知
+	Notify bool
+}
+
+func NewBatch(rows []*BatchRow) *Batch {
+	return &Batch{rows: rows}
+}
+
+// Rows 返回各行当前状态的副本，可以在 Run 执行时调用
+func (b *Batch) Rows() []BatchRow {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	rows := make([]BatchRow, len(b.rows))
+	for i, row := range b.rows {
+		rows[i] = *row
+	}
+	return rows
+}
+
+// Counts 返回各状态的行数
+func (b *Batch) Counts() map[BatchStatus]int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	counts := make(map[BatchStatus]int)
+	for _, row := range b.rows {
+		counts[row.Status]++
+	}
+	return counts
+}
+
+// Run 依次下载等待中和失败的行，已经成功的行不会重新下载
+// ctx 取消后不再开始新的下载，onUpdate 在每行状态变化后调用
+func (b *Batch) Run(ctx context.Context, onUpdate func()) {
+	defer CardJobs.Hold()()
+	if onUpdate == nil {
+		onUpdate = func() {}
+	}
+	for i := range b.rows {
+		if ctx.Err() != nil {
+			return
+		}
+		b.mu.Lock()
+		row := b.rows[i]
+		runnable := row.Status == BatchPending || row.Status == BatchFailed
+		b.mu.Unlock()
+		if runnable {
+			b.runRow(row, onUpdate)
+		}
+	}
+}
+
+// RunNext 下载下一个等待中的行并返回结果，没有等待中的行时返回 false
+func (b *Batch) RunNext() (BatchRow, bool) {
+	b.mu.Lock()
+	var next *BatchRow
+	for _, row := range b.rows {
+		if row.Status == BatchPending {
+			next = row
+			break
+		}
+	}
+	b.mu.Unlock()
+	if next == nil {
+		return BatchRow{}, false
+	}
+	b.runRow(next, func() {})
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return *next, true
+}
+
+// Requeue 把下载失败的行重新标记为等待中
+func (b *Batch) Requeue(line int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, row := range b.rows {
+		if row.Line == line && row.Status == BatchFailed {
+			row.Status = BatchPending
+		}
+	}
+}
+
+func (b *Batch) runRow(row *BatchRow, onUpdate func()) {
+	b.mu.Lock()
+	row.Status = BatchRunning
+	row.Err, row.NicknameErr, row.Notifications = nil, nil, nil
+	info, nickname := row.Info, row.Nickname
+	b.mu.Unlock()
+	onUpdate()
+
+	iccid, outcomes, err := DownloadWithNotifications(info, b.Notify)
+	var nicknameErr error
+	if err == nil {
+		if nickname == "" {
+			nickname = b.NicknameTemplate
+		}
+		nicknameErr = setBatchNickname(iccid, nickname)
+	}
+
+	b.mu.Lock()
+	row.Iccid, row.Notifications, row.NicknameErr = iccid, outcomes, nicknameErr
+	row.Err = err
+	row.Status = BatchSucceeded
+	if err != nil {
+		row.Status = BatchFailed
+	}
+	row.Finished = time.Now()
+	b.mu.Unlock()
+	onUpdate()
+}
+
+// setBatchNickname 按模板设置新 Profile 的昵称
+func setBatchNickname(iccid, template string) error {
+	if iccid == "" || template == "" {
+		return nil
+	}
+	profiles, err := LpacProfileList()
+	if err != nil {
+		return err
+	}
+	for _, p := range profiles {
+		if p.Iccid == iccid {
+			return LpacProfileNickname(iccid, ExpandNicknameTemplate(template, p))
+		}
+	}
+	return nil
+}
+
+// batchReportRow 是结果报告中的一行
+type batchReportRow struct {
+	Line          int                  `json:"line"`
+	SMDP          string               `json:"smdp"`
+	MatchingID    string               `json:"matching_id"`
+	Status        string               `json:"status"`
+	Iccid         string               `json:"iccid,omitempty"`
+	Notifications []NotificationResult `json:"notifications,omitempty"`
+	Error         string               `json:"error,omitempty"`
+	NicknameError string               `json:"nickname_error,omitempty"`
+	Finished      *time.Time           `json:"finished,omitempty"`
+}
+
+// WriteReport 以 csv 或 json 格式输出各行的结果
+func (b *Batch) WriteReport(w io.Writer, format string) error {
+	var report []batchReportRow
+	for _, row := range b.Rows() {
+		r := batchReportRow{
+			Line:       row.Line,
+			SMDP:       row.Info.SMDP,
+			MatchingID: row.Info.MatchID,
+			Status:     row.Status.String(),
+			Iccid:      row.Iccid,
+		}
+		if row.Err != nil {
+			r.Error = row.Err.Error()
+		}
+		if row.NicknameErr != nil {
+			r.NicknameError = row.NicknameErr.Error()
+		}
+		if !row.Finished.IsZero() {
+			finished := row.Finished
+			r.Finished = &finished
+		}
+		result, _ := NewOperationResult("profile download", row.Iccid, row.Notifications)
+		r.Notifications = result.Notifications
+		report = append(report, r)
+	}
+	switch format {
+	case "json":
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(report)
+	case "csv":
+		cw := csv.NewWriter(w)
+		_ = cw.Write([]string{"line", "smdp", "matching_id", "status", "iccid", "notifications", "error"})
+		for _, r := range report {
+			var notifications []string
+			for _, n := range r.Notifications {
+				state := "sent"
+				switch {
+				case n.Error != "":
+					state = "failed"
+				case n.Action == ActionKeep:
+					state = "kept"
+				case n.Action == ActionAsk:
+					state = "pending"
+				case n.Removed:
+					state = "removed"
+				}
+				notifications = append(notifications, fmt.Sprintf("%d:%s", n.SeqNumber, state))
+			}
+			errText := r.Error
+			if r.NicknameError != "" {
+				errText = strings.TrimSpace(errText + " nickname: " + r.NicknameError)
+			}
+			_ = cw.Write([]string{fmt.Sprint(r.Line), r.SMDP, r.MatchingID, r.Status, r.Iccid, strings.Join(notifications, " "), errText})
+		}
+		cw.Flush()
+		return cw.Error()
+	default:
+		return fmt.Errorf("unsupported report format: %s", format)
+	}
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseBatchFileCSV(t *testing.T) {
+	rows, err := ParseBatchFile("codes.csv", []byte("\xEF\xBB\xBFSM-DP+,Matching ID,Nickname\n"+
+		"smdp.example.com,AAA,Lab 1\n"+
+		"\n"+
+		"# comment\n"+
+		"smdp.example.com,AAA,Lab 2\n"+
+		",BBB,\n"))
+	require.NoError(t, err)
+	require.Len(t, rows, 3)
+	assert.Equal(t, PullInfo{SMDP: "smdp.example.com", MatchID: "AAA"}, rows[0].Info)
+	assert.Equal(t, "Lab 1", rows[0].Nickname)
+	assert.Equal(t, 2, rows[0].Line)
+	assert.Equal(t, BatchPending, rows[0].Status)
+	// 重复的 Matching ID 和缺少 SM-DP+ 的行不会下载
+	assert.Equal(t, BatchInvalid, rows[1].Status)
+	assert.Equal(t, 5, rows[1].Line)
+	assert.Equal(t, BatchInvalid, rows[2].Status)
+
+	rows, err = ParseBatchFile("codes.txt", []byte("LPA:1$smdp.example.com$AAA,,356938035643809\n"+
+		"LPA:1$smdp.example.com$BBB$$1,\n"+
+		"LPA:1$smdp.example.com$CCC$$1,1234\n"+
+		"not an activation code\n"))
+	require.NoError(t, err)
+	require.Len(t, rows, 4)
+	assert.Equal(t, PullInfo{SMDP: "smdp.example.com", MatchID: "AAA", IMEI: "356938035643809"}, rows[0].Info)
+	assert.Equal(t, BatchInvalid, rows[1].Status, "confirmation code required")
+	assert.Equal(t, BatchPending, rows[2].Status)
+	assert.Equal(t, "1234", rows[2].Info.ConfirmCode)
+	assert.Equal(t, BatchInvalid, rows[3].Status)
+
+	_, err = ParseBatchFile("empty.csv", []byte("activation_code\n"))
+	assert.Error(t, err)
+}
+
+func TestParseBatchFileJSON(t *testing.T) {
+	rows, err := ParseBatchFile("codes.json", []byte(`[
+		"LPA:1$smdp.example.com$AAA",
+		{"smdp": "smdp.example.com", "matching_id": "BBB", "imei": "12"},
+		42
+	]`))
+	require.NoError(t, err)
+	require.Len(t, rows, 3)
+	assert.Equal(t, "AAA", rows[0].Info.MatchID)
+	assert.Equal(t, BatchInvalid, rows[1].Status, "invalid IMEI")
+	assert.Equal(t, BatchInvalid, rows[2].Status)
+	assert.Equal(t, 3, rows[2].Line)
+}
+
+func TestBatchRunAndRetry(t *testing.T) {
+	fake := useFakeLpac(t)
+	fake.Downloadable["AAA"] = &Profile{Iccid: "8988303000000000028", ServiceProviderName: "Lab"}
+	rows, err := ParseBatchFile("codes.csv", []byte("activation_code,nickname\n"+
+		"LPA:1$smdp.example.com$AAA,\n"+
+		"LPA:1$smdp.example.com$BBB,Second\n"+
+		"LPA:1$smdp.example.com$CCC,\n"))
+	require.NoError(t, err)
+	batch := NewBatch(rows)
+	batch.NicknameTemplate = "{provider} {iccid4}"
+	batch.Notify = true
+
+	batch.Run(context.Background(), nil)
+	result := batch.Rows()
+	assert.Equal(t, BatchSucceeded, result[0].Status)
+	assert.Equal(t, "8988303000000000028", result[0].Iccid)
+	require.Len(t, result[0].Notifications, 1)
+	assert.True(t, result[0].Notifications[0].Removed)
+	assert.Equal(t, BatchFailed, result[1].Status)
+	assert.Equal(t, BatchFailed, result[2].Status)
+	profiles, err := LpacProfileList()
+	require.NoError(t, err)
+	require.Len(t, profiles, 3)
+	require.NotNil(t, profiles[2].ProfileNickname)
+	assert.Equal(t, "Lab 0028", *profiles[2].ProfileNickname)
+
+	// 重试只下载失败的行，成功的行不会重复下载
+	fake.Downloadable["BBB"] = &Profile{Iccid: "8901260123456789011"}
+	batch.Run(context.Background(), nil)
+	counts := batch.Counts()
+	assert.Equal(t, 2, counts[BatchSucceeded])
+	assert.Equal(t, 1, counts[BatchFailed])
+	profiles, err = LpacProfileList()
+	require.NoError(t, err)
+	require.Len(t, profiles, 4)
+	require.NotNil(t, profiles[3].ProfileNickname)
+	assert.Equal(t, "Second", *profiles[3].ProfileNickname)
+
+	// 取消后不再开始新的下载
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	batch.Run(ctx, nil)
+	assert.Equal(t, counts, batch.Counts())
+
+	var buf bytes.Buffer
+	require.NoError(t, batch.WriteReport(&buf, "csv"))
+	records, err := csv.NewReader(&buf).ReadAll()
+	require.NoError(t, err)
+	require.Len(t, records, 4)
+	assert.Equal(t, []string{"2", "smdp.example.com", "AAA", "succeeded", "8988303000000000028"}, records[1][:5])
+	assert.NotEmpty(t, records[3][6])
+
+	buf.Reset()
+	require.NoError(t, batch.WriteReport(&buf, "json"))
+	var report []batchReportRow
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &report))
+	assert.Equal(t, "failed", report[2].Status)
+	assert.Error(t, batch.WriteReport(&buf, "xml"))
+}
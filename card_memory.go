@@ -0,0 +1,180 @@
+package main
+
+import (
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+)
+
+// CardMemory 是为某个读卡器或 eUICC 记住的设置
+type CardMemory struct {
+	AID  string `json:"aid,omitempty"`
+	IMEI string `json:"imei,omitempty"`
+	// NicknameTemplate 下载后自动设置昵称，支持 {provider} {name} {iccid} {iccid4} {date}
+	NicknameTemplate string `json:"nickname_template,omitempty"`
+}
+
+var imeiPattern = regexp.MustCompile(`^[0-9]{15,16}$`)
+
+// validateCardMemory 清除不合法的值，返回是否有值被清除
+func validateCardMemory(m *CardMemory) bool {
+	invalid := false
+	if m.AID != "" && !aidPattern.MatchString(m.AID) {
+		m.AID = ""
+		invalid = true
+	}
+	if m.IMEI != "" && !imeiPattern.MatchString(m.IMEI) {
+		m.IMEI = ""
+		invalid = true
+	}
+	return invalid
+}
+
+func memoryFor(memories *map[string]*CardMemory, key string) *CardMemory {
+	if *memories == nil {
+		*memories = make(map[string]*CardMemory)
+	}
+	m, ok := (*memories)[key]
+	if !ok {
+		m = &CardMemory{}
+		(*memories)[key] = m
+	}
+	return m
+}
+
+func currentEid() string {
+	if ChipInfo == nil {
+		return ""
+	}
+	return ChipInfo.EidValue
+}
+
+// CurrentCardMemory 合并当前读卡器和 eUICC 的设置，eUICC 的设置优先
+func CurrentCardMemory() CardMemory {
+	var merged CardMemory
+	for _, m := range []*CardMemory{ConfigInstance.Readers[ConfigInstance.ReaderName], ConfigInstance.Cards[currentEid()]} {
+		if m == nil {
+			continue
+		}
+		if m.AID != "" {
+			merged.AID = m.AID
+		}
+		if m.IMEI != "" {
+			merged.IMEI = m.IMEI
+		}
+		if m.NicknameTemplate != "" {
+			merged.NicknameTemplate = m.NicknameTemplate
+		}
+	}
+	return merged
+}
+
+// setLpacAID 修改设置中的 AID，同时更新设置页面的输入框
+func setLpacAID(aid string) {
+	if AidEntry != nil {
+		// 由输入框的 OnChanged 校验并保存
+		AidEntry.SetText(aid)
+		return
+	}
+	ConfigInstance.LpacAID = aid
+	ConfigChanged()
+}
+
+// ApplyReaderMemory 在选择读卡器时使用该读卡器上次成功的 AID
+func ApplyReaderMemory(name string) {
+	if m := ConfigInstance.Readers[name]; m != nil && m.AID != "" && m.AID != ConfigInstance.LpacAID {
+		setLpacAID(m.AID)
+	}
+}
+
+// RememberWorkingAID 在成功读取芯片信息后记住当前读卡器和 eUICC 使用的 AID
+func RememberWorkingAID() {
+	changed := false
+	remember := func(m *CardMemory) {
+		if m.AID != ConfigInstance.LpacAID {
+			m.AID = ConfigInstance.LpacAID
+			changed = true
+		}
+	}
+	if ConfigInstance.ReaderName != "" {
+		remember(memoryFor(&ConfigInstance.Readers, ConfigInstance.ReaderName))
+	}
+	if eid := currentEid(); eid != "" {
+		remember(memoryFor(&ConfigInstance.Cards, eid))
+	}
+	if changed {
+		ConfigChanged()
+	}
+}
+
+// RecoverAID 在当前 AID 无法访问 eUICC 时（例如更换了卡片）
+// 依次测试其他读卡器和 eUICC 记住的 AID，找到可用的 AID 时切换过去
+func RecoverAID() bool {
+	var candidates []string
+	add := func(aid string) {
+		if aid != "" && aid != ConfigInstance.LpacAID && !slices.Contains(candidates, aid) {
+			candidates = append(candidates, aid)
+		}
+	}
+	// 优先测试当前读卡器记住的 AID，其余按顺序测试
+	if m := ConfigInstance.Readers[ConfigInstance.ReaderName]; m != nil {
+		add(m.AID)
+	}
+	var others []string
+	for _, memories := range []map[string]*CardMemory{ConfigInstance.Cards, ConfigInstance.Readers} {
+		for _, m := range memories {
+			others = append(others, m.AID)
+		}
+	}
+	slices.Sort(others)
+	for _, aid := range others {
+		add(aid)
+	}
+	for _, aid := range candidates {
+		if TestAid(aid) {
+			setLpacAID(aid)
+			return true
+		}
+	}
+	return false
+}
+
+// RememberIMEI 记住下载时使用的 IMEI，下次下载时自动填写
+func RememberIMEI(imei string) {
+	if imei != "" && !imeiPattern.MatchString(imei) {
+		return
+	}
+	if ConfigInstance.ReaderName != "" {
+		memoryFor(&ConfigInstance.Readers, ConfigInstance.ReaderName).IMEI = imei
+	}
+	if eid := currentEid(); eid != "" {
+		memoryFor(&ConfigInstance.Cards, eid).IMEI = imei
+	}
+	ConfigChanged()
+}
+
+// RememberNicknameTemplate 为当前 eUICC 记住昵称模板
+func RememberNicknameTemplate(template string) {
+	eid := currentEid()
+	if eid == "" {
+		return
+	}
+	memoryFor(&ConfigInstance.Cards, eid).NicknameTemplate = template
+	ConfigChanged()
+}
+
+// ExpandNicknameTemplate 用 Profile 的信息替换模板中的占位符
+func ExpandNicknameTemplate(template string, p *Profile) string {
+	iccid4 := p.Iccid
+	if len(iccid4) > 4 {
+		iccid4 = iccid4[len(iccid4)-4:]
+	}
+	return strings.NewReplacer(
+		"{provider}", p.ServiceProviderName,
+		"{name}", p.ProfileName,
+		"{iccid}", p.Iccid,
+		"{iccid4}", iccid4,
+		"{date}", time.Now().Format("2006-01-02"),
+	).Replace(template)
+}
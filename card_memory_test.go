@@ -0,0 +1,50 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExpandNicknameTemplate(t *testing.T) {
+	p := &Profile{Iccid: "8988303000000000028", ServiceProviderName: "Travel", ProfileName: "Data"}
+	assert.Equal(t, "Travel 0028", ExpandNicknameTemplate("{provider} {iccid4}", p))
+	assert.Equal(t, "Data "+time.Now().Format("2006-01-02"), ExpandNicknameTemplate("{name} {date}", p))
+	assert.Equal(t, "{unknown}", ExpandNicknameTemplate("{unknown}", p))
+}
+
+func TestRememberWorkingAID(t *testing.T) {
+	useConfigFile(t, "")
+	ConfigInstance.Readers, ConfigInstance.Cards = nil, nil
+	ConfigInstance.ReaderName = "Reader A"
+	ConfigInstance.LpacAID = AID_5BER
+	RememberWorkingAID()
+	assert.Equal(t, AID_5BER, ConfigInstance.Readers["Reader A"].AID)
+
+	ConfigInstance.LpacAID = AID_DEFAULT
+	ApplyReaderMemory("Reader B")
+	assert.Equal(t, AID_DEFAULT, ConfigInstance.LpacAID)
+	ApplyReaderMemory("Reader A")
+	assert.Equal(t, AID_5BER, ConfigInstance.LpacAID)
+}
+
+func TestRecoverAID(t *testing.T) {
+	fake := useFakeLpac(t)
+	fake.AID = AID_5BER
+	useConfigFile(t, "")
+	ConfigInstance.LpacAID = AID_DEFAULT
+	ConfigInstance.Readers = nil
+	ConfigInstance.Cards = map[string]*CardMemory{"89049032000000000000000000000001": {AID: AID_5BER}}
+
+	_, err := LpacChipInfo()
+	lpacErr, ok := AsLpacError(err)
+	require.True(t, ok)
+	assert.Equal(t, "euicc_init", lpacErr.Key)
+
+	require.True(t, RecoverAID())
+	assert.Equal(t, AID_5BER, ConfigInstance.LpacAID)
+	_, err = LpacChipInfo()
+	assert.NoError(t, err)
+}
@@ -0,0 +1,981 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// 命令行模式的退出码，脚本可以据此区分失败原因
+const (
+	ExitOK           = 0
+	ExitFailure      = 1
+	ExitUsage        = 2
+	ExitNetwork      = 3
+	ExitServer       = 4
+	ExitCard         = 5
+	ExitReader       = 6
+	ExitTimeout      = 7
+	ExitLpacNotFound = 127
+	ExitCancelled    = 130
+)
+
+// usageError 表示命令行参数错误
+type usageError string
+
+func (e usageError) Error() string {
+	return string(e)
+}
+
+// reportedError 表示结果已经输出，只需要根据 err 设置退出码
+type reportedError struct {
+	err error
+}
+
+func (e *reportedError) Error() string {
+	return e.err.Error()
+}
+
+func (e *reportedError) Unwrap() error {
+	return e.err
+}
+
+// cliExitCode 根据错误类型返回退出码
+func cliExitCode(err error) int {
+	if err == nil {
+		return ExitOK
+	}
+	var usage usageError
+	if errors.As(err, &usage) {
+		return ExitUsage
+	}
+	lpacErr, ok := AsLpacError(err)
+	if !ok {
+		return ExitFailure
+	}
+	switch lpacErr.Class {
+	case ErrorClassNetwork:
+		return ExitNetwork
+	case ErrorClassServer:
+		return ExitServer
+	case ErrorClassCard:
+		return ExitCard
+	case ErrorClassReader:
+		return ExitReader
+	case ErrorClassTimeout:
+		return ExitTimeout
+	case ErrorClassCancelled:
+		return ExitCancelled
+	default:
+		return ExitFailure
+	}
+}
+
+// cli 保存一次命令行调用的选项
+type cli struct {
+	stdout io.Writer
+	stderr io.Writer
+	json   bool
+	notify bool
+	reader string
+	aid    string
+}
+
+type cliCommand struct {
+	Name  string // 如 "profile enable"
+	Usage string
+	// Card 为 true 时在执行前选择读卡器
+	Card bool
+	Run  func(c *cli, args []string) error
+}
+
+var cliCommands = []cliCommand{
+	{"chip info", "", true, (*cli).chipInfo},
+	{"profile list", "", true, (*cli).profileList},
+	{"profile enable", "<ICCID>", true, profileCommand("profile enable", LpacProfileEnable)},
+	{"profile disable", "<ICCID>", true, profileCommand("profile disable", LpacProfileDisable)},
+	{"profile delete", "<ICCID>", true, profileCommand("profile delete", LpacProfileDelete)},
+	{"profile switch", "<ICCID>", true, (*cli).profileSwitch},
+	{"profile nickname", "<ICCID> [nickname]", true, (*cli).profileNickname},
+	{"profile download", downloadUsage, true, (*cli).profileDownload},
+	{"notification list", "", true, (*cli).notificationList},
+	{"notification process", "[-r | -policy] (-all | <seq>...)", true, (*cli).notificationProcess},
+	{"notification remove", "<seq>...", true, (*cli).notificationRemove},
+	{"aid probe", "[-all] [AID...]", true, (*cli).aidProbe},
+	{"report", "[-format json|csv|md|html] [-mask]", true, (*cli).exportReport},
+	{"eum", "[EID]", false, (*cli).eum},
+	{"iccid", "<ICCID>", false, (*cli).iccid},
+	{"schedule list", "", false, (*cli).scheduleList},
+	{"schedule run", "<name>", true, (*cli).scheduleRun},
+	{"reader list", "", false, (*cli).readerList},
+	{"version", "", false, (*cli).version},
+}
+
+const downloadUsage = "[-s SM-DP+] [-m matching ID] [-c confirmation code] [-i IMEI] [LPA:1$...]"
+
+// findCLICommand 按最长匹配查找命令，返回命令和剩余的参数
+func findCLICommand(words []string) (*cliCommand, []string) {
+	var found *cliCommand
+	var rest []string
+	for i, command := range cliCommands {
+		fields := strings.Fields(command.Name)
+		if len(words) < len(fields) || !slicesEqualFold(words[:len(fields)], fields) {
+			continue
+		}
+		if found == nil || len(fields) > len(strings.Fields(found.Name)) {
+			found = &cliCommands[i]
+			rest = words[len(fields):]
+		}
+	}
+	return found, rest
+}
+
+func slicesEqualFold(a, b []string) bool {
+	for i := range a {
+		if !strings.EqualFold(a[i], b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// RunCLI 以命令行模式运行，不创建窗口，返回进程的退出码
+func RunCLI(args []string, stdout, stderr io.Writer) int {
+	c := &cli{stdout: stdout, stderr: stderr}
+	flags := flag.NewFlagSet("cli", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.BoolVar(&c.json, "json", false, "print results as JSON")
+	flags.StringVar(&c.reader, "reader", "", "card reader name or index (default: last used reader)")
+	flags.StringVar(&c.aid, "aid", "", "ISD-R AID (default: configured AID)")
+	flags.BoolVar(&c.notify, "notify", true, "handle notifications generated by profile operations with the notification policy")
+	flags.Usage = func() { c.usage(flags) }
+	if err := flags.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return ExitOK
+		}
+		return ExitUsage
+	}
+	// 命令行模式只读取设置，不修改配置文件
+	configState.readOnly = true
+
+	command, rest := findCLICommand(flags.Args())
+	if command == nil {
+		if flags.NArg() > 0 {
+			fmt.Fprintf(stderr, "unknown command: %s\n", strings.Join(flags.Args(), " "))
+		}
+		c.usage(flags)
+		return ExitUsage
+	}
+	if c.aid != "" {
+		if !aidPattern.MatchString(c.aid) {
+			return c.fail(usageError("invalid AID: " + c.aid))
+		}
+		ConfigInstance.LpacAID = strings.ToUpper(c.aid)
+	}
+	if command.Card {
+		if err := c.selectReader(); err != nil {
+			return c.fail(err)
+		}
+	}
+	stop := cancelOnInterrupt()
+	defer stop()
+	if err := command.Run(c, rest); err != nil {
+		return c.fail(err)
+	}
+	return ExitOK
+}
+
+func (c *cli) usage(flags *flag.FlagSet) {
+	fmt.Fprintln(c.stderr, "Usage: EasyLPAC [-config file] cli [options] <command> [arguments]")
+	fmt.Fprintln(c.stderr, "\nCommands:")
+	w := tabwriter.NewWriter(c.stderr, 0, 4, 2, ' ', 0)
+	for _, command := range cliCommands {
+		fmt.Fprintf(w, "  %s\t%s\n", command.Name, command.Usage)
+	}
+	w.Flush()
+	fmt.Fprintln(c.stderr, "\nOptions:")
+	flags.PrintDefaults()
+	fmt.Fprintln(c.stderr, "\nExit codes: 0 success, 1 failure, 2 usage, 3 network, 4 SM-DP+ rejected,")
+	fmt.Fprintln(c.stderr, "5 eUICC, 6 card reader, 7 timeout, 127 lpac not found, 130 cancelled")
+}
+
+// fail 输出错误并返回对应的退出码
+func (c *cli) fail(err error) int {
+	code := cliExitCode(err)
+	var reported *reportedError
+	if errors.As(err, &reported) {
+		return code
+	}
+	var usage usageError
+	if errors.As(err, &usage) {
+		fmt.Fprintf(c.stderr, "%s\nRun 'EasyLPAC cli -h' for usage.\n", err)
+		return code
+	}
+	if c.json {
+		c.print(cliError{newErrorInfo(err), code})
+		return code
+	}
+	fmt.Fprintln(c.stderr, "Error:", strings.TrimSpace(err.Error()))
+	if lpacErr, ok := AsLpacError(err); ok {
+		if explanation, remedy := lpacErr.Explanation(); explanation != "" {
+			fmt.Fprintf(c.stderr, "\n%s\n%s\n", explanation, remedy)
+		}
+	}
+	return code
+}
+
+// errorInfo 是错误的 JSON 表示，命令行和 API 共用
+type errorInfo struct {
+	Error       string `json:"error"`
+	Class       string `json:"class,omitempty"`
+	Key         string `json:"key,omitempty"`
+	Function    string `json:"function,omitempty"`
+	SubjectCode string `json:"subject_code,omitempty"`
+	ReasonCode  string `json:"reason_code,omitempty"`
+	Explanation string `json:"explanation,omitempty"`
+	Remedy      string `json:"remedy,omitempty"`
+	Retryable   bool   `json:"retryable"`
+}
+
+func newErrorInfo(err error) errorInfo {
+	info := errorInfo{Error: strings.TrimSpace(err.Error())}
+	if lpacErr, ok := AsLpacError(err); ok {
+		info.Class = lpacErr.Class.String()
+		info.Key = lpacErr.Key
+		info.Function = lpacErr.Function
+		info.SubjectCode = lpacErr.SubjectCode
+		info.ReasonCode = lpacErr.ReasonCode
+		info.Explanation, info.Remedy = lpacErr.Explanation()
+		info.Retryable = lpacErr.Retryable
+	}
+	return info
+}
+
+type cliError struct {
+	errorInfo
+	ExitCode int `json:"exit_code"`
+}
+
+func (c *cli) print(v any) error {
+	encoder := json.NewEncoder(c.stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
+
+func (c *cli) table() *tabwriter.Writer {
+	return tabwriter.NewWriter(c.stdout, 0, 4, 2, ' ', 0)
+}
+
+// cancelOnInterrupt 在收到 Ctrl+C 时取消正在执行的 lpac 命令
+func cancelOnInterrupt() (stop func()) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt)
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-signals:
+				CardJobs.CancelRunning()
+			case <-done:
+				return
+			}
+		}
+	}()
+	return func() {
+		signal.Stop(signals)
+		close(done)
+	}
+}
+
+// selectReader 按 -reader 选择读卡器，未指定时使用上次选择的读卡器
+func (c *cli) selectReader() error {
+	d, err := FindReader(c.reader)
+	if err != nil {
+		return err
+	}
+	ConfigInstance.DriverIFID = d.Env
+	ConfigInstance.ReaderName = d.Name
+	// 和界面一样使用该读卡器上次成功的 AID，-aid 优先
+	if m := ConfigInstance.Readers[d.Name]; m != nil && m.AID != "" && c.aid == "" {
+		ConfigInstance.LpacAID = m.AID
+	}
+	return nil
+}
+
+func expectArgs(args []string, min, max int, usage string) error {
+	if len(args) < min || (max >= 0 && len(args) > max) {
+		return usageError("usage: " + usage)
+	}
+	return nil
+}
+
+func (c *cli) chipInfo(args []string) error {
+	if err := expectArgs(args, 0, 0, "chip info"); err != nil {
+		return err
+	}
+	info, err := LpacChipInfo()
+	if err != nil {
+		return err
+	}
+	if c.json {
+		return c.print(info)
+	}
+	w := c.table()
+	fmt.Fprintf(w, "EID:\t%s\n", info.EidValue)
+	if eum := GetEUM(info.EidValue); eum != nil {
+		fmt.Fprintf(w, "Manufacturer:\t%s\n", eumDescription(eum, info.EidValue))
+	}
+	defaultDp := ""
+	if info.EuiccConfiguredAddresses.DefaultDpAddress != nil {
+		defaultDp = fmt.Sprint(info.EuiccConfiguredAddresses.DefaultDpAddress)
+	}
+	fmt.Fprintf(w, "Default SM-DP+:\t%s\n", defaultDp)
+	fmt.Fprintf(w, "Root SM-DS:\t%s\n", info.EuiccConfiguredAddresses.RootDsAddress)
+	fmt.Fprintf(w, "SGP.22 version:\t%s\n", info.EUICCInfo2.ProfileVersion)
+	fmt.Fprintf(w, "Firmware version:\t%s\n", info.EUICCInfo2.EuiccFirmwareVer)
+	fmt.Fprintf(w, "Free NVM:\t%.2f KiB\n", float64(info.EUICCInfo2.ExtCardResource.FreeNonVolatileMemory)/1024)
+	return w.Flush()
+}
+
+func eumDescription(eum *EUMIdentifier, eid string) string {
+	description := eum.Manufacturer
+	if product := eum.ProductName(eid); product != "" {
+		description += " " + product
+	}
+	if eum.Country != "" {
+		description += " (" + eum.Country + ")"
+	}
+	return description
+}
+
+func (c *cli) profileList(args []string) error {
+	if err := expectArgs(args, 0, 0, "profile list"); err != nil {
+		return err
+	}
+	profiles, err := LpacProfileList()
+	if err != nil {
+		return err
+	}
+	if c.json {
+		if profiles == nil {
+			profiles = []*Profile{}
+		}
+		return c.print(profiles)
+	}
+	w := c.table()
+	fmt.Fprintln(w, "ICCID\tSTATE\tCLASS\tPROVIDER\tNAME\tNICKNAME")
+	for _, p := range profiles {
+		nickname := ""
+		if p.ProfileNickname != nil {
+			nickname = *p.ProfileNickname
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n",
+			p.Iccid, p.ProfileState, p.ProfileClass, p.ServiceProviderName, p.ProfileName, nickname)
+	}
+	return w.Flush()
+}
+
+// report 输出操作结果，有通知发送失败时返回 reportedError
+func (c *cli) report(command, iccid string, outcomes []NotificationOutcome) error {
+	result, firstErr := NewOperationResult(command, iccid, outcomes)
+	if c.json {
+		if err := c.print(result); err != nil {
+			return err
+		}
+	} else {
+		if iccid != "" {
+			command += " " + iccid
+		}
+		fmt.Fprintf(c.stdout, "%s: ok\n", command)
+		for _, r := range result.Notifications {
+			status := "sent"
+			switch {
+			case r.Error != "":
+				status = "failed: " + strings.ReplaceAll(r.Error, "\n", " ")
+			case r.Action == ActionRemove:
+				status = "removed"
+			case r.Action == ActionKeep:
+				status = "kept"
+			case r.Action == ActionAsk:
+				status = "left on card"
+			case r.Removed:
+				status = "sent and removed"
+			}
+			fmt.Fprintf(c.stdout, "notification %d (%s): %s\n", r.SeqNumber, r.ProfileManagementOperation, status)
+		}
+	}
+	if firstErr != nil {
+		return &reportedError{firstErr}
+	}
+	return nil
+}
+
+// profileCommand 生成只需要 ICCID 参数的 Profile 命令
+func profileCommand(name string, run func(iccid string) error) func(c *cli, args []string) error {
+	return func(c *cli, args []string) error {
+		if err := expectArgs(args, 1, 1, name+" <ICCID>"); err != nil {
+			return err
+		}
+		// 只提示不阻止，部分测试卡的 ICCID 没有正确的校验位
+		if err := ValidateICCID(args[0]); err != nil {
+			fmt.Fprintln(c.stderr, "warning:", err)
+		}
+		outcomes, err := RunWithNotifications(c.notify, func() error { return run(args[0]) })
+		if err != nil {
+			return err
+		}
+		return c.report(name, args[0], outcomes)
+	}
+}
+
+// profileSwitch 启用 Profile 并验证结果，失败时切换回原来启用的 Profile
+func (c *cli) profileSwitch(args []string) error {
+	if err := expectArgs(args, 1, 1, "profile switch <ICCID>"); err != nil {
+		return err
+	}
+	profiles, err := LpacProfileList()
+	if err != nil {
+		return err
+	}
+	s := NewSafeSwitch(args[0], profiles)
+	s.Notify = c.notify
+	result := s.Run(context.Background())
+	if result.Err != nil {
+		if result.RollbackErr != nil {
+			fmt.Fprintf(c.stderr, "failed to switch back to %q: %v\n", s.Previous, result.RollbackErr)
+		} else {
+			fmt.Fprintf(c.stderr, "switched back to %q\n", s.Previous)
+		}
+		return result.Err
+	}
+	return c.report("profile switch", args[0], result.Notifications)
+}
+
+func (c *cli) profileNickname(args []string) error {
+	if err := expectArgs(args, 1, 2, "profile nickname <ICCID> [nickname]"); err != nil {
+		return err
+	}
+	nickname := ""
+	if len(args) > 1 {
+		nickname = args[1]
+	}
+	if err := LpacProfileNickname(args[0], nickname); err != nil {
+		return err
+	}
+	return c.report("profile nickname", args[0], nil)
+}
+
+func (c *cli) profileDownload(args []string) error {
+	var info PullInfo
+	flags := flag.NewFlagSet("profile download", flag.ContinueOnError)
+	flags.SetOutput(c.stderr)
+	flags.StringVar(&info.SMDP, "s", "", "SM-DP+ address")
+	flags.StringVar(&info.MatchID, "m", "", "matching ID")
+	flags.StringVar(&info.ConfirmCode, "c", "", "confirmation code")
+	flags.StringVar(&info.IMEI, "i", CurrentCardMemory().IMEI, "IMEI sent to the SM-DP+")
+	if err := flags.Parse(args); err != nil {
+		return usageError("usage: profile download " + downloadUsage)
+	}
+	if flags.NArg() > 1 {
+		return usageError("usage: profile download " + downloadUsage)
+	}
+	if flags.NArg() == 1 {
+		if err := applyActivationCode(&info, flags.Arg(0)); err != nil {
+			return err
+		}
+	}
+	if err := validatePullInfo(info); err != nil {
+		return err
+	}
+	iccid, outcomes, err := DownloadWithNotifications(info, c.notify)
+	if err != nil {
+		return err
+	}
+	return c.report("profile download", iccid, outcomes)
+}
+
+// applyActivationCode 用激活码补充 info 中未填写的 SM-DP+ 地址和 Matching ID
+func applyActivationCode(info *PullInfo, activationCode string) error {
+	code, confirmCodeNeeded, err := DecodeLpaActivationCode(CompleteActivationCode(activationCode))
+	if err != nil {
+		return usageError(err.Error())
+	}
+	if info.SMDP == "" {
+		info.SMDP = code.SMDP
+	}
+	if info.MatchID == "" {
+		info.MatchID = code.MatchID
+	}
+	if confirmCodeNeeded && info.ConfirmCode == "" {
+		return usageError("this activation code requires a confirmation code")
+	}
+	return nil
+}
+
+func validatePullInfo(info PullInfo) error {
+	if info.SMDP == "" {
+		return usageError("SM-DP+ address is required")
+	}
+	if info.IMEI != "" && !imeiPattern.MatchString(info.IMEI) {
+		return usageError("invalid IMEI: " + info.IMEI)
+	}
+	return nil
+}
+
+// DownloadWithNotifications 下载 Profile 并返回新 Profile 的 ICCID，send 为 true 时发送 install 通知
+func DownloadWithNotifications(info PullInfo, send bool) (string, []NotificationOutcome, error) {
+	defer CardJobs.Hold()()
+	var before []*Profile
+	outcomes, err := RunWithNotifications(send, func() error {
+		var err error
+		if before, err = LpacProfileList(); err != nil {
+			return err
+		}
+		return LpacProfileDownload(info)
+	})
+	if err != nil {
+		return "", nil, err
+	}
+	iccid := ""
+	if after, err := LpacProfileList(); err == nil {
+		iccid = findNewIccid(before, after)
+	}
+	return iccid, outcomes, nil
+}
+
+// findNewIccid 返回下载后新出现的 Profile 的 ICCID
+func findNewIccid(before, after []*Profile) string {
+	exists := make(map[string]bool)
+	for _, p := range before {
+		exists[p.Iccid] = true
+	}
+	for _, p := range after {
+		if !exists[p.Iccid] {
+			return p.Iccid
+		}
+	}
+	return ""
+}
+
+func (c *cli) notificationList(args []string) error {
+	if err := expectArgs(args, 0, 0, "notification list"); err != nil {
+		return err
+	}
+	notifications, err := LpacNotificationList()
+	if err != nil {
+		return err
+	}
+	if c.json {
+		if notifications == nil {
+			notifications = []*Notification{}
+		}
+		return c.print(notifications)
+	}
+	w := c.table()
+	fmt.Fprintln(w, "SEQ\tOPERATION\tICCID\tADDRESS")
+	for _, n := range notifications {
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\n", n.SeqNumber, n.ProfileManagementOperation, n.Iccid, n.NotificationAddress)
+	}
+	return w.Flush()
+}
+
+// selectNotifications 按序号从卡片上的通知中选择，all 为 true 时选择全部
+func selectNotifications(seqs []string, all bool) ([]*Notification, error) {
+	if all == (len(seqs) > 0) {
+		return nil, usageError("specify either -all or sequence numbers")
+	}
+	notifications, err := LpacNotificationList()
+	if err != nil || all {
+		return notifications, err
+	}
+	var selected []*Notification
+	for _, s := range seqs {
+		seq, err := strconv.Atoi(s)
+		if err != nil {
+			return nil, usageError("invalid sequence number: " + s)
+		}
+		notification := findNotificationBySeq(notifications, seq)
+		if notification == nil {
+			return nil, fmt.Errorf("notification %d not found", seq)
+		}
+		selected = append(selected, notification)
+	}
+	return selected, nil
+}
+
+func findNotificationBySeq(notifications []*Notification, seq int) *Notification {
+	for _, n := range notifications {
+		if n.SeqNumber == seq {
+			return n
+		}
+	}
+	return nil
+}
+
+func (c *cli) notificationProcess(args []string) error {
+	flags := flag.NewFlagSet("notification process", flag.ContinueOnError)
+	flags.SetOutput(c.stderr)
+	remove := flags.Bool("r", false, "remove notifications after sending")
+	policy := flags.Bool("policy", false, "handle notifications with the notification policy, ask leaves them on the card")
+	all := flags.Bool("all", false, "process all notifications")
+	if err := flags.Parse(args); err != nil || (*remove && *policy) {
+		return usageError("usage: notification process [-r | -policy] (-all | <seq>...)")
+	}
+	notifications, err := selectNotifications(flags.Args(), *all)
+	if err != nil {
+		return err
+	}
+	if *policy {
+		return c.report("notification process", "", SendNotifications(notifications))
+	}
+	outcomes := make([]NotificationOutcome, 0, len(notifications))
+	for _, n := range notifications {
+		outcomes = append(outcomes, ApplyNotificationAction(n, processAction(*remove)))
+	}
+	return c.report("notification process", "", outcomes)
+}
+
+func (c *cli) notificationRemove(args []string) error {
+	if err := expectArgs(args, 1, -1, "notification remove <seq>..."); err != nil {
+		return err
+	}
+	notifications, err := selectNotifications(args, false)
+	if err != nil {
+		return err
+	}
+	for _, n := range notifications {
+		if err := LpacNotificationRemove(n.SeqNumber); err != nil {
+			return err
+		}
+		if !c.json {
+			fmt.Fprintf(c.stdout, "notification %d (%s): removed\n", n.SeqNumber, n.ProfileManagementOperation)
+		}
+	}
+	if c.json {
+		return c.print(notifications)
+	}
+	return nil
+}
+
+type aidProbeResult struct {
+	AID         string `json:"aid"`
+	Description string `json:"description,omitempty"`
+	OK          bool   `json:"ok"`
+}
+
+var hexPattern = regexp.MustCompile(`^[[:xdigit:]]+$`)
+
+// aidCandidates 返回要测试的 AID：当前设置、记住的 AID、常见的 eUICC AID 以及 AID 列表中的 eUICC AID
+func aidCandidates() []string {
+	var candidates []string
+	add := func(aid string) {
+		aid = strings.ToUpper(aid)
+		if aid != "" && !slices.Contains(candidates, aid) {
+			candidates = append(candidates, aid)
+		}
+	}
+	add(ConfigInstance.LpacAID)
+	for _, memories := range []map[string]*CardMemory{ConfigInstance.Readers, ConfigInstance.Cards} {
+		for _, m := range memories {
+			add(m.AID)
+		}
+	}
+	for _, aid := range []string{AID_DEFAULT, AID_5BER, AID_ESIMME, AID_XESIM} {
+		add(aid)
+	}
+	for _, item := range AidList {
+		if item.IsEuicc {
+			add(item.AID)
+		}
+	}
+	return candidates
+}
+
+func aidDescription(aid string) string {
+	for _, item := range AidList {
+		if strings.EqualFold(item.AID, aid) {
+			return item.Description
+		}
+	}
+	return ""
+}
+
+func (c *cli) aidProbe(args []string) error {
+	flags := flag.NewFlagSet("aid probe", flag.ContinueOnError)
+	flags.SetOutput(c.stderr)
+	all := flags.Bool("all", false, "test every AID instead of stopping at the first working one")
+	if err := flags.Parse(args); err != nil {
+		return usageError("usage: aid probe [-all] [AID...]")
+	}
+	candidates := flags.Args()
+	for _, aid := range candidates {
+		if !hexPattern.MatchString(aid) {
+			return usageError("invalid AID: " + aid)
+		}
+	}
+	if len(candidates) == 0 {
+		candidates = aidCandidates()
+	}
+	results := make([]aidProbeResult, 0, len(candidates))
+	found := false
+	for _, aid := range candidates {
+		ok := TestAid(aid)
+		result := aidProbeResult{AID: aid, Description: aidDescription(aid), OK: ok}
+		results = append(results, result)
+		if !c.json {
+			status := "failed"
+			if ok {
+				status = "ok"
+			}
+			fmt.Fprintf(c.stdout, "%s  %-6s  %s\n", aid, status, result.Description)
+		}
+		found = found || ok
+		if ok && !*all {
+			break
+		}
+	}
+	if c.json {
+		if err := c.print(results); err != nil {
+			return err
+		}
+	}
+	if !found {
+		return &reportedError{&LpacError{
+			Function: "euicc_init",
+			Class:    ErrorClassCard,
+			Key:      "euicc_init",
+			Err:      errors.New("no working ISD-R AID found"),
+		}}
+	}
+	return nil
+}
+
+type eumResult struct {
+	EID          string `json:"eid"`
+	EUM          string `json:"eum"`
+	Manufacturer string `json:"manufacturer"`
+	Country      string `json:"country"`
+	Product      string `json:"product,omitempty"`
+}
+
+func (c *cli) exportReport(args []string) error {
+	flags := flag.NewFlagSet("report", flag.ContinueOnError)
+	flags.SetOutput(c.stderr)
+	format := flags.String("format", "md", "report format: "+strings.Join(ReportFormats, ", "))
+	mask := flags.Bool("mask", false, "mask EID and ICCIDs")
+	if err := flags.Parse(args); err != nil || flags.NArg() > 0 || !slices.Contains(ReportFormats, *format) {
+		return usageError("usage: report [-format json|csv|md|html] [-mask]")
+	}
+	if c.json {
+		*format = "json"
+	}
+	report, err := CollectReport()
+	if err != nil {
+		return err
+	}
+	if *mask {
+		report.Mask()
+	}
+	return report.Write(c.stdout, *format)
+}
+
+func (c *cli) eum(args []string) error {
+	if err := expectArgs(args, 0, 1, "eum [EID]"); err != nil {
+		return err
+	}
+	var eid string
+	if len(args) == 1 {
+		eid = args[0]
+	} else {
+		// 未指定 EID 时读取当前卡片
+		if err := c.selectReader(); err != nil {
+			return err
+		}
+		info, err := LpacChipInfo()
+		if err != nil {
+			return err
+		}
+		eid = info.EidValue
+	}
+	eum := GetEUM(eid)
+	if eum == nil {
+		return fmt.Errorf("no EUM found for EID %s", eid)
+	}
+	result := eumResult{EID: eid, EUM: eum.EUM, Manufacturer: eum.Manufacturer, Country: eum.Country, Product: eum.ProductName(eid)}
+	if c.json {
+		return c.print(result)
+	}
+	w := c.table()
+	fmt.Fprintf(w, "EID:\t%s\n", result.EID)
+	fmt.Fprintf(w, "EUM:\t%s\n", result.EUM)
+	fmt.Fprintf(w, "Manufacturer:\t%s\n", result.Manufacturer)
+	fmt.Fprintf(w, "Country:\t%s %s\n", CountryCodeToEmoji(result.Country), result.Country)
+	if result.Product != "" {
+		fmt.Fprintf(w, "Product:\t%s\n", result.Product)
+	}
+	return w.Flush()
+}
+
+type iccidResult struct {
+	Iccid    string `json:"iccid"`
+	Valid    bool   `json:"valid"`
+	Error    string `json:"error,omitempty"`
+	Country  string `json:"country,omitempty"`
+	Operator string `json:"operator,omitempty"`
+}
+
+// iccid 校验 ICCID 并按 IIN 查询国家和运营商，ICCID 无效时退出码为 ExitUsage
+func (c *cli) iccid(args []string) error {
+	if err := expectArgs(args, 1, 1, "iccid <ICCID>"); err != nil {
+		return err
+	}
+	// 允许直接粘贴带空格或连字符的 ICCID
+	result := iccidResult{Iccid: strings.NewReplacer(" ", "", "-", "").Replace(args[0])}
+	err := ValidateICCID(result.Iccid)
+	result.Valid = err == nil
+	if err != nil {
+		result.Error = err.Error()
+	}
+	if iin := GetIIN(result.Iccid); iin != nil {
+		result.Country, result.Operator = iin.Country, iin.Operator
+	}
+	if c.json {
+		if printErr := c.print(result); printErr != nil {
+			return printErr
+		}
+	} else {
+		w := c.table()
+		fmt.Fprintf(w, "ICCID:\t%s\n", result.Iccid)
+		if result.Valid {
+			fmt.Fprintf(w, "Valid:\tyes\n")
+		} else {
+			fmt.Fprintf(w, "Valid:\tno (%s)\n", result.Error)
+		}
+		if result.Country != "" {
+			fmt.Fprintf(w, "Country:\t%s %s\n", CountryCodeToEmoji(result.Country), result.Country)
+		}
+		if result.Operator != "" {
+			fmt.Fprintf(w, "Operator:\t%s\n", result.Operator)
+		}
+		if flushErr := w.Flush(); flushErr != nil {
+			return flushErr
+		}
+	}
+	if err != nil {
+		return &reportedError{usageError(err.Error())}
+	}
+	return nil
+}
+
+// scheduleView 是 schedule list 的 JSON 输出
+type scheduleView struct {
+	*ScheduleEntry
+	Next *time.Time `json:"next,omitempty"`
+}
+
+func (c *cli) scheduleList(args []string) error {
+	if err := expectArgs(args, 0, 0, "schedule list"); err != nil {
+		return err
+	}
+	now := time.Now()
+	views := []scheduleView{}
+	for _, e := range ConfigInstance.Schedules {
+		view := scheduleView{ScheduleEntry: e}
+		if next := e.Next(now); !next.IsZero() {
+			view.Next = &next
+		}
+		views = append(views, view)
+	}
+	if c.json {
+		return c.print(views)
+	}
+	w := c.table()
+	fmt.Fprintln(w, "NAME\tICCID\tEID\tREADER\tWHEN\tNEXT")
+	for _, view := range views {
+		next := "-"
+		switch {
+		case view.Disabled:
+			next = "disabled"
+		case view.Next != nil:
+			next = view.Next.Format(ScheduleTimeLayout)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", view.Name, view.Iccid, view.EID, view.Reader, view.When(), next)
+	}
+	return w.Flush()
+}
+
+// scheduleRun 立即执行一个计划，用于在计划时间之前检查设置
+func (c *cli) scheduleRun(args []string) error {
+	if err := expectArgs(args, 1, 1, "schedule run <name>"); err != nil {
+		return err
+	}
+	scheduler := NewScheduler(ConfigInstance.Schedules)
+	scheduler.Notify = func() bool { return c.notify }
+	e := scheduler.Find(args[0])
+	if e == nil {
+		return usageError("schedule not found: " + args[0])
+	}
+	run := scheduler.Run(context.Background(), e)
+	switch run.Status {
+	case ScheduleUnchanged:
+		fmt.Fprintf(c.stderr, "%s is already enabled\n", e.Iccid)
+	case ScheduleNoCard, ScheduleBusy, ScheduleFailed:
+		if run.Outcome.RolledBack {
+			fmt.Fprintln(c.stderr, "switched back to the previous profile")
+		}
+		return run.Err
+	}
+	return c.report("schedule run", e.Iccid, run.Outcome.Notifications)
+}
+
+func (c *cli) readerList(args []string) error {
+	if err := expectArgs(args, 0, 0, "reader list"); err != nil {
+		return err
+	}
+	drivers, err := ListApduDrivers()
+	if err != nil {
+		return err
+	}
+	if c.json {
+		if drivers == nil {
+			drivers = []*ApduDriver{}
+		}
+		return c.print(drivers)
+	}
+	w := c.table()
+	fmt.Fprintln(w, "ID\tNAME\t")
+	for _, d := range drivers {
+		remembered := ""
+		if d.Name == ConfigInstance.ReaderName {
+			remembered = "(last used)"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", d.Env, d.Name, remembered)
+	}
+	return w.Flush()
+}
+
+func (c *cli) version(args []string) error {
+	if err := expectArgs(args, 0, 0, "version"); err != nil {
+		return err
+	}
+	lpacVersion, err := LpacVersion()
+	if err != nil {
+		return err
+	}
+	if c.json {
+		return c.print(map[string]string{"easylpac": Version, "lpac": lpacVersion, "euicc_data": EUICCDataVersion})
+	}
+	fmt.Fprintf(c.stdout, "EasyLPAC %s\nlpac %s\neUICC data %s\n", Version, lpacVersion, EUICCDataVersion)
+	return nil
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// runCLI 在测试中运行命令行模式，返回退出码和输出
+func runCLI(t *testing.T, args ...string) (int, string, string) {
+	var stdout, stderr bytes.Buffer
+	code := RunCLI(args, &stdout, &stderr)
+	return code, stdout.String(), stderr.String()
+}
+
+func TestCLIProfileList(t *testing.T) {
+	useFakeLpac(t)
+	useConfigFile(t, "")
+
+	code, stdout, _ := runCLI(t, "-json", "profile", "list")
+	require.Equal(t, ExitOK, code)
+	var profiles []*Profile
+	require.NoError(t, json.Unmarshal([]byte(stdout), &profiles))
+	assert.Len(t, profiles, 2)
+
+	code, stdout, _ = runCLI(t, "profile", "list")
+	require.Equal(t, ExitOK, code)
+	assert.True(t, strings.HasPrefix(stdout, "ICCID"))
+	assert.Contains(t, stdout, "8988303000000000010")
+}
+
+func TestCLISwitchSendsNotifications(t *testing.T) {
+	fake := useFakeLpac(t)
+	useConfigFile(t, "")
+
+	code, stdout, _ := runCLI(t, "-json", "-notify", "profile", "enable", "8988303000000000010")
+	require.Equal(t, ExitOK, code)
+	var result OperationResult
+	require.NoError(t, json.Unmarshal([]byte(stdout), &result))
+	require.Len(t, result.Notifications, 2)
+	for _, n := range result.Notifications {
+		assert.True(t, n.Removed)
+	}
+	assert.Empty(t, fake.Notifications)
+
+	// 不发送时通知保留在卡片上
+	code, _, _ = runCLI(t, "-notify=false", "profile", "disable", "8988303000000000010")
+	require.Equal(t, ExitOK, code)
+	assert.Len(t, fake.Notifications, 1)
+}
+
+func TestCLIDownloadActivationCode(t *testing.T) {
+	fake := useFakeLpac(t)
+	useConfigFile(t, "")
+	fake.Downloadable["MATCHING-ID"] = &Profile{Iccid: "8988303000000000028", ServiceProviderName: "Travel"}
+
+	code, stdout, _ := runCLI(t, "-notify", "profile", "download", "LPA:1$smdp.example.com$MATCHING-ID")
+	require.Equal(t, ExitOK, code)
+	assert.Contains(t, stdout, "profile download 8988303000000000028: ok")
+	assert.Contains(t, stdout, "(install): sent and removed")
+
+	code, _, _ = runCLI(t, "profile", "download", "LPA:1$smdp.example.com$WRONG")
+	assert.Equal(t, ExitServer, code)
+	code, _, _ = runCLI(t, "profile", "download")
+	assert.Equal(t, ExitUsage, code)
+}
+
+func TestCLIExitCodes(t *testing.T) {
+	fake := useFakeLpac(t)
+	useConfigFile(t, "")
+
+	code, _, _ := runCLI(t, "profile", "frobnicate")
+	assert.Equal(t, ExitUsage, code)
+
+	fake.Fail("profile enable", &FakeFailure{Function: "es10c_enable_profile", Data: "catBusy"})
+	code, stdout, _ := runCLI(t, "-json", "profile", "enable", "8988303000000000010")
+	assert.Equal(t, ExitCard, code)
+	var out cliError
+	require.NoError(t, json.Unmarshal([]byte(stdout), &out))
+	assert.Equal(t, "cat_busy", out.Key)
+	assert.True(t, out.Retryable)
+
+	fake.Fail("chip info", &FakeFailure{Err: errors.New("SCardConnect() failed: 8010000C")})
+	code, _, _ = runCLI(t, "chip", "info")
+	assert.Equal(t, ExitReader, code)
+
+	code, _, _ = runCLI(t, "-reader", "Missing Reader", "profile", "list")
+	assert.Equal(t, ExitReader, code)
+}
+
+func TestCLIAidProbe(t *testing.T) {
+	fake := useFakeLpac(t)
+	fake.AID = AID_5BER
+	useConfigFile(t, "")
+	ConfigInstance.LpacAID = AID_DEFAULT
+
+	code, stdout, _ := runCLI(t, "-json", "aid", "probe")
+	require.Equal(t, ExitOK, code)
+	var results []aidProbeResult
+	require.NoError(t, json.Unmarshal([]byte(stdout), &results))
+	require.NotEmpty(t, results)
+	last := results[len(results)-1]
+	assert.Equal(t, AID_5BER, last.AID)
+	assert.True(t, last.OK)
+	// 测试 AID 不修改设置
+	assert.Equal(t, AID_DEFAULT, ConfigInstance.LpacAID)
+
+	code, _, _ = runCLI(t, "aid", "probe", AID_ESIMME)
+	assert.Equal(t, ExitCard, code)
+}
+
+func TestCLIIccid(t *testing.T) {
+	useConfigFile(t, "")
+	InitIinRegistry()
+	code, stdout, _ := runCLI(t, "-json", "iccid", "8986 0012 3456 7890 1238")
+	require.Equal(t, ExitOK, code)
+	var result iccidResult
+	require.NoError(t, json.Unmarshal([]byte(stdout), &result))
+	assert.True(t, result.Valid)
+	assert.Equal(t, "CN", result.Country)
+	assert.Equal(t, "China Mobile", result.Operator)
+
+	code, stdout, _ = runCLI(t, "iccid", "89860012345678901237")
+	assert.Equal(t, ExitUsage, code)
+	assert.Contains(t, stdout, "expected 8")
+}
+
+func TestCLISchedule(t *testing.T) {
+	useFakeLpac(t)
+	useConfigFile(t, "")
+	ConfigInstance.Schedules = []*ScheduleEntry{
+		{Name: "roaming", Iccid: "8988303000000000010", Reader: "Fake Card Reader 00 00", Cron: "0 8 * * 1-5"},
+		{Name: "elsewhere", Iccid: "8988303000000000002", Reader: "Missing Reader", From: "2026-10-18 08:00"},
+	}
+
+	code, stdout, _ := runCLI(t, "schedule", "list")
+	require.Equal(t, ExitOK, code)
+	assert.Contains(t, stdout, "0 8 * * 1-5")
+
+	code, stdout, _ = runCLI(t, "-json", "schedule", "run", "roaming")
+	require.Equal(t, ExitOK, code)
+	var result OperationResult
+	require.NoError(t, json.Unmarshal([]byte(stdout), &result))
+	assert.Equal(t, "8988303000000000010", result.Iccid)
+
+	code, _, _ = runCLI(t, "schedule", "run", "elsewhere")
+	assert.Equal(t, ExitReader, code)
+	code, _, _ = runCLI(t, "schedule", "run", "unknown")
+	assert.Equal(t, ExitUsage, code)
+}
+
+func TestCLIProfileSwitch(t *testing.T) {
+	fake := useFakeLpac(t)
+	useConfigFile(t, "")
+
+	code, stdout, _ := runCLI(t, "-json", "-notify", "profile", "switch", "8988303000000000010")
+	require.Equal(t, ExitOK, code)
+	var result OperationResult
+	require.NoError(t, json.Unmarshal([]byte(stdout), &result))
+	assert.Len(t, result.Notifications, 2)
+
+	fake.Fail("profile enable", &FakeFailure{Function: "es10c_enable_profile", Data: "catBusy"})
+	code, _, stderr := runCLI(t, "profile", "switch", "8988303000000000002")
+	assert.Equal(t, ExitCard, code)
+	assert.Contains(t, stderr, `switched back to "8988303000000000010"`)
+}
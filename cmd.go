@@ -2,82 +2,67 @@ package main
 
 import (
 	"bufio"
-	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
-	"os/exec"
-	"path/filepath"
 	"strconv"
 	"strings"
 	"time"
-
-	"fyne.io/fyne/v2/dialog"
-	"github.com/mattn/go-runewidth"
 )
 
-func runLpac(args ...string) (json.RawMessage, error) {
-	StatusChan <- StatusProcess
-	LockButtonChan <- true
-	defer func() {
-		StatusChan <- StatusReady
-		LockButtonChan <- false
-	}()
+var ErrLpacCancelled = errors.New("lpac: operation cancelled")
+var ErrLpacTimeout = errors.New("lpac: operation timed out")
 
-	// Save to LogFile
-	lpacPath := filepath.Join(ConfigInstance.LpacDir, ConfigInstance.EXEName)
-	command := lpacPath
-	for _, arg := range args {
-		command += fmt.Sprintf(" %s", arg)
-	}
-	if _, err := fmt.Fprintln(ConfigInstance.LogFile, command); err != nil {
-		return nil, err
+// lpacTimeout 根据命令类型返回超时时间
+func lpacTimeout(args []string) time.Duration {
+	switch strings.Join(args[:min(len(args), 2)], " ") {
+	case "profile download", "notification process":
+		return ConfigInstance.Timeouts.Network
+	case "profile enable", "profile disable", "profile delete", "profile nickname",
+		"chip defaultsmdp", "notification remove":
+		return ConfigInstance.Timeouts.Card
+	default:
+		return ConfigInstance.Timeouts.Query
 	}
+}
 
-	// 使用context设置超时（5秒），避免命令执行时间过长（特别是AID测试时）
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-	
-	// 将context关联到command
-	cmd := exec.CommandContext(ctx, lpacPath, args...)
-	HideCmdWindow(cmd)
-
-	cmd.Dir = ConfigInstance.LpacDir
+func runLpac(args ...string) (json.RawMessage, error) {
+	return CardJobs.Do(&Job{Args: args})
+}
 
-	cmd.Env = []string{
-		"LPAC_APDU=pcsc",
-		"LPAC_HTTP=curl",
-		fmt.Sprintf("DRIVER_IFID=%s", ConfigInstance.DriverIFID),
-		fmt.Sprintf("LPAC_CUSTOM_ISD_R_AID=%s", ConfigInstance.LpacAID),
-	}
-	if ConfigInstance.DebugHTTP {
-		cmd.Env = append(cmd.Env, "LIBEUICC_DEBUG_HTTP=1")
-	}
-	if ConfigInstance.DebugAPDU {
-		cmd.Env = append(cmd.Env, "LIBEUICC_DEBUG_APDU=1")
-	}
-	var stdout bytes.Buffer
-	var stderr bytes.Buffer
-	writer := io.MultiWriter(&stdout, ConfigInstance.LogFile)
-	errWriter := io.MultiWriter(ConfigInstance.LogFile, &stderr)
-	cmd.Stdout = writer
-	cmd.Stderr = errWriter
+// executeLpac 在 JobQueue 中执行 job，ctx 被取消时中止 lpac
+func executeLpac(ctx context.Context, job *Job) (json.RawMessage, error) {
+	args := job.Args
+	timeout := lpacTimeout(args)
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
 
-	err := cmd.Run()
-	if err != nil && len(bytes.TrimSpace(stderr.Bytes())) != 0 {
-		// fixme
-		// if lpac debug enabled, some lpac debug output will write to stderr if something went wrong
-		// It shouldn't return here if it not pcsc error
-		if strings.Contains(stderr.String(), "SCard") {
-			return nil, errors.New(stderr.String())
+	stdout := newProgressWriter(job)
+	defer stdout.Close()
+	err := Backend.Run(ctx, job, stdout)
+	// lpac 被中止时不会输出结果，优先报告中止原因
+	switch {
+	case errors.Is(ctx.Err(), context.Canceled):
+		return nil, &LpacError{Function: stdout.command, Class: ErrorClassCancelled, Key: "cancelled", Err: ErrLpacCancelled}
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		return nil, &LpacError{Function: stdout.command, Class: ErrorClassTimeout, Key: "timeout", Retryable: true,
+			Err: fmt.Errorf("%w after %s: %s", ErrLpacTimeout, timeout, stdout.command)}
+	case err != nil:
+		if strings.Contains(err.Error(), "SCard") {
+			return nil, newPCSCError(err)
 		}
+		return nil, err
 	}
+	return parseLpacOutput(&stdout.output)
+}
 
+// parseLpacOutput 从 lpac 的 JSON 输出中找到最终的 lpa 结果
+func parseLpacOutput(r io.Reader) (json.RawMessage, error) {
 	var resp LpacReturnValue
 
-	scanner := bufio.NewScanner(&stdout)
+	scanner := bufio.NewScanner(r)
 	for scanner.Scan() {
 		if err := json.Unmarshal(scanner.Bytes(), &resp); err != nil {
 			continue
@@ -92,7 +77,7 @@ func runLpac(args ...string) (json.RawMessage, error) {
 			_ = json.Unmarshal(resp.Payload.Data, &jsonString)
 			// 内层
 			var result map[string]interface{}
-			err = json.Unmarshal([]byte(jsonString), &result)
+			err := json.Unmarshal([]byte(jsonString), &result)
 			if err != nil {
 				dataString = jsonString
 			} else {
@@ -103,31 +88,7 @@ func runLpac(args ...string) (json.RawMessage, error) {
 					dataString = string(formattedJSON)
 				}
 			}
-			wrapText := func(text string, maxWidth int) string {
-				var wrappedText strings.Builder
-				lines := strings.Split(text, "\n")
-				for _, line := range lines {
-					var currentWidth int
-					var currentLine strings.Builder
-					for _, runeValue := range line {
-						// fixme 现在貌似没有必要了
-						// 使用字符宽度而不是长度，让包含 CJK 字符的字符串也能正确限制显示长度
-						runeWidth := runewidth.RuneWidth(runeValue)
-						if currentWidth+runeWidth > maxWidth {
-							wrappedText.WriteString(currentLine.String() + "\n")
-							currentLine.Reset()
-							currentWidth = 0
-						}
-						currentLine.WriteRune(runeValue)
-						currentWidth += runeWidth
-					}
-					if currentLine.Len() > 0 {
-						wrappedText.WriteString(currentLine.String() + "\n")
-					}
-				}
-				return wrappedText.String()
-			}
-			return nil, fmt.Errorf("Function: %s\nData: %s", resp.Payload.Message, wrapText(dataString, 90))
+			return nil, newLpacError(resp.Payload.Message, dataString)
 		}
 	}
 	if err := scanner.Err(); err != nil {
@@ -161,30 +122,37 @@ func LpacProfileList() ([]*Profile, error) {
 }
 
 func LpacProfileEnable(iccid string) error {
+	succeeded := expectNotifications(OriginEnable, iccid)
 	_, err := runLpac("profile", "enable", iccid)
 	if err != nil {
 		return err
 	}
+	succeeded()
 	return nil
 }
 
 func LpacProfileDisable(iccid string) error {
+	succeeded := expectNotifications(OriginDisable, iccid)
 	_, err := runLpac("profile", "disable", iccid)
 	if err != nil {
 		return err
 	}
+	succeeded()
 	return nil
 }
 
 func LpacProfileDelete(iccid string) error {
+	succeeded := expectNotifications(OriginDelete, iccid)
 	_, err := runLpac("profile", "delete", iccid)
 	if err != nil {
 		return err
 	}
+	succeeded()
 	return nil
 }
 
-func LpacProfileDownload(info PullInfo) {
+func LpacProfileDownload(info PullInfo) error {
+	succeeded := expectNotifications(OriginDownload, "")
 	args := []string{"profile", "download"}
 	if info.SMDP != "" {
 		args = append(args, "-s", info.SMDP)
@@ -200,36 +168,10 @@ func LpacProfileDownload(info PullInfo) {
 	}
 	_, err := runLpac(args...)
 	if err != nil {
-		ShowLpacErrDialog(err)
-	} else {
-		notificationOrigin := Notifications
-		Refresh()
-		downloadNotification := findNewNotification(notificationOrigin, Notifications)
-		if downloadNotification == nil {
-			dialog.ShowError(errors.New("notification not found"), WMain)
-			return
-		}
-		if ConfigInstance.AutoMode {
-			var dialogText string
-			if err2 := LpacNotificationProcess(downloadNotification.SeqNumber, true); err2 != nil {
-				dialogText = "Download successful\nSend install notification failed\n"
-			} else {
-				dialogText = "Download successful\nSend install notification successful\nRemove install notification successful\n"
-			}
-			if err2 := RefreshNotification(); err2 != nil {
-				ShowLpacErrDialog(err2)
-			}
-			dialog.ShowInformation("Info", dialogText, WMain)
-		} else {
-			dialog.ShowConfirm("Send Install Notification",
-				"Download successful\nSend the install notification now?\n",
-				func(b bool) {
-					if b {
-						go processNotificationManually(downloadNotification.SeqNumber)
-					}
-				}, WMain)
-		}
+		return err
 	}
+	succeeded()
+	return nil
 }
 
 func LpacProfileNickname(iccid, nickname string) error {
@@ -262,6 +204,8 @@ func LpacNotificationProcess(seq int, remove bool) error {
 	if err != nil {
 		return err
 	}
+	// 手动发送成功的通知不再重试
+	notificationHandled(seq)
 	return nil
 }
 
@@ -270,6 +214,7 @@ func LpacNotificationRemove(seq int) error {
 	if err != nil {
 		return err
 	}
+	notificationHandled(seq)
 	return nil
 }
 
@@ -13,32 +13,83 @@ const AID_5BER = "A0000005591010FFFFFFFF8900050500"
 const AID_ESIMME = "A0000005591010000000008900000300"
 const AID_XESIM = "A0000005591010FFFFFFFF8900000177"
 
+// LpacTimeouts 是不同类型 lpac 命令的超时时间
+type LpacTimeouts struct {
+	Query   time.Duration // chip info、profile list 以及 AID 测试
+	Card    time.Duration // enable、disable、delete 等只涉及卡片的操作
+	Network time.Duration // 下载 Profile 和发送通知，需要访问 SM-DP+
+}
+
+var DefaultTimeouts = LpacTimeouts{
+	Query:   5 * time.Second,
+	Card:    30 * time.Second,
+	Network: 3 * time.Minute,
+}
+
 type Config struct {
 	LpacDir     string
 	LpacAID     string
 	EXEName     string
 	DriverIFID  string
+	ApduBackend string // LPAC_APDU，如 pcsc、at、qmi、mbim
+	HttpBackend string // LPAC_HTTP，如 curl
+	ApduDevice  string // 手动指定的 AT/QMI/MBIM 设备路径
+	UimSlot     int    // QMI/MBIM 的 UIM 卡槽
+	MbimProxy   bool   // 通过 mbim-proxy 访问 MBIM 设备
 	DebugHTTP   bool
 	DebugAPDU   bool
 	LogDir      string
 	LogFilename string
 	LogFile     *os.File
-	AutoMode    bool
 	Language    string // 语言设置，如 "en", "zh-TW", "ja-JP"
+	Timeouts    LpacTimeouts
+	ReaderName  string // 上次选择的读卡器，刷新读卡器列表后自动选择
+	// HideTestProfiles 在 Profile 列表中隐藏测试 Profile
+	HideTestProfiles bool
+	// SwitchGracePeriod 是启用 Profile 后等待用户确认的时间，超时自动切换回原 Profile，0 表示不等待
+	SwitchGracePeriod time.Duration
+	// Schedules 是定时切换 Profile 的计划
+	Schedules []*ScheduleEntry
+	// NotificationPolicy 决定操作产生的通知如何处理
+	NotificationPolicy *NotificationPolicy
+	// NotificationWarnAfter 之后仍未发送的通知在退出或取出卡片时提醒，0 表示不提醒
+	NotificationWarnAfter time.Duration
+	// Readers 以读卡器名称为键，Cards 以 EID 为键
+	Readers map[string]*CardMemory
+	Cards   map[string]*CardMemory
+	// 本地 API 服务，APIListen 为 host:port 或 unix:/path
+	APIEnabled bool
+	APIListen  string
+	APIToken   string
+
+	ConfigPath     string
+	Portable       bool
+	ConfigWarnings []string // 加载配置时被恢复为默认值的设置
+	// Args 是解析完启动参数后剩余的命令行参数
+	Args []string
 }
 
 var ConfigInstance Config
 
-func LoadConfig() error {
+// executableDir 返回程序所在的目录
+func executableDir() (string, error) {
 	exePath, err := os.Executable()
 	if err != nil {
-		return err
+		return "", err
 	}
 	exePath, err = filepath.EvalSymlinks(exePath)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Dir(exePath), nil
+}
+
+// LoadConfig 设置各平台的默认值，配置文件由 LoadConfigFile 读取
+func LoadConfig() error {
+	exeDir, err := executableDir()
 	if err != nil {
 		return err
 	}
-	exeDir := filepath.Dir(exePath)
 	ConfigInstance.LpacDir = exeDir
 
 	switch platform := runtime.GOOS; platform {
@@ -67,10 +118,22 @@ func LoadConfig() error {
 			}
 		}
 	}
-	ConfigInstance.AutoMode = true
-	ConfigInstance.LpacAID = AID_DEFAULT
-	ConfigInstance.Language = "" // 空值表示使用系统默认语言
+	applyConfigFile(defaultConfigFile())
 
 	ConfigInstance.LogFilename = fmt.Sprintf("lpac-%s.txt", time.Now().Format("20060102-150405"))
 	return nil
 }
+
+// LoadConfigFile 读取启动参数、环境变量和配置文件，在 main 开始时调用
+func LoadConfigFile() error {
+	exeDir, err := executableDir()
+	if err != nil {
+		return err
+	}
+	args, err := parseConfigArgs(os.Args[1:], os.Getenv, os.Stderr)
+	if err != nil {
+		return err
+	}
+	ConfigInstance.Args = args.rest
+	return loadConfigFile(args, exeDir)
+}
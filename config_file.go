@@ -0,0 +1,487 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ConfigVersion 是当前配置文件的版本，修改 ConfigFile 的结构时递增并添加迁移
+const ConfigVersion = 2
+
+const configFilename = "config.json"
+
+// portableMarker 存在于程序目录时使用便携模式，配置保存在程序目录
+const portableMarker = "portable"
+
+// ConfigFile 是保存到磁盘的设置
+type ConfigFile struct {
+	Version     int    `json:"version"`
+	LpacDir     string `json:"lpac_dir,omitempty"` // 为空时自动查找 lpac
+	LpacAID     string `json:"lpac_aid"`
+	Language    string `json:"language"` // 为空时跟随系统
+	DebugHTTP   bool   `json:"debug_http"`
+	DebugAPDU   bool   `json:"debug_apdu"`
+	HideTest    bool   `json:"hide_test_profiles"`
+	SwitchGrace string `json:"switch_grace_period"`
+	Reader      string `json:"reader"` // 上次选择的读卡器名称
+	ApduBackend string `json:"apdu_backend"`
+	HttpBackend string `json:"http_backend"`
+	ApduDevice  string `json:"apdu_device,omitempty"`
+	UimSlot     int    `json:"uim_slot"`
+	MbimProxy   bool   `json:"mbim_proxy"`
+	Timeouts    struct {
+		Query   string `json:"query"`
+		Card    string `json:"card"`
+		Network string `json:"network"`
+	} `json:"timeouts"`
+	Readers map[string]*CardMemory `json:"readers,omitempty"`
+	Cards   map[string]*CardMemory `json:"cards,omitempty"`
+	API     struct {
+		Enabled bool   `json:"enabled"`
+		Listen  string `json:"listen"`
+		Token   string `json:"token,omitempty"`
+	} `json:"api"`
+	// Schedules 是定时切换 Profile 的计划
+	Schedules []*ScheduleEntry `json:"schedules,omitempty"`
+	// NotificationPolicy 代替了版本 1 的 auto_mode
+	NotificationPolicy *NotificationPolicy `json:"notification_policy"`
+	// NotificationWarnAfter 之后仍未发送的通知在退出或取出卡片时提醒，0 表示不提醒
+	NotificationWarnAfter string `json:"notification_warn_after"`
+}
+
+// configMigrations[n] 把版本 n 的配置升级到版本 n+1
+// 没有 version 字段的文件视为版本 1
+var configMigrations = map[int]func(raw map[string]any){
+	// auto_mode 为 true 时使用默认的通知策略，为 false 时所有通知都询问
+	1: func(raw map[string]any) {
+		policy := DefaultNotificationPolicy()
+		if auto, ok := raw["auto_mode"].(bool); ok && !auto {
+			policy = AskNotificationPolicy()
+		}
+		raw["notification_policy"] = policy
+		delete(raw, "auto_mode")
+	},
+}
+
+// configOverride 是可以通过命令行参数或环境变量覆盖的设置
+// 覆盖的值只在本次运行中生效，不会写入配置文件
+type configOverride struct {
+	Flag  string
+	Env   string
+	Usage string
+	field func(f *ConfigFile) any // *string、*bool 或 *int
+}
+
+var configOverrides = []configOverride{
+	{"lpac-dir", "EASYLPAC_LPAC_DIR", "directory containing the lpac executable", func(f *ConfigFile) any { return &f.LpacDir }},
+	{"aid", "EASYLPAC_AID", "ISD-R AID", func(f *ConfigFile) any { return &f.LpacAID }},
+	{"lang", "EASYLPAC_LANGUAGE", "UI language (en, zh-TW, ja-JP)", func(f *ConfigFile) any { return &f.Language }},
+	{"reader", "EASYLPAC_READER", "card reader name", func(f *ConfigFile) any { return &f.Reader }},
+	{"apdu", "EASYLPAC_APDU", "lpac APDU backend", func(f *ConfigFile) any { return &f.ApduBackend }},
+	{"http", "EASYLPAC_HTTP", "lpac HTTP backend", func(f *ConfigFile) any { return &f.HttpBackend }},
+	{"device", "EASYLPAC_APDU_DEVICE", "AT/QMI/MBIM device path", func(f *ConfigFile) any { return &f.ApduDevice }},
+	{"debug-http", "EASYLPAC_DEBUG_HTTP", "enable LIBEUICC_DEBUG_HTTP", func(f *ConfigFile) any { return &f.DebugHTTP }},
+	{"debug-apdu", "EASYLPAC_DEBUG_APDU", "enable LIBEUICC_DEBUG_APDU", func(f *ConfigFile) any { return &f.DebugAPDU }},
+	{"api-listen", "EASYLPAC_API_LISTEN", "local API address (host:port or unix:/path)", func(f *ConfigFile) any { return &f.API.Listen }},
+	{"api-token", "EASYLPAC_API_TOKEN", "local API token", func(f *ConfigFile) any { return &f.API.Token }},
+}
+
+// configArgs 是从命令行和环境变量中解析出的启动参数
+type configArgs struct {
+	path     string
+	portable bool
+	values   map[string]string // 以 configOverride.Flag 为键
+	rest     []string
+	warnings []string
+}
+
+// parseConfigArgs 解析启动参数和环境变量，只在 -h 时向 stderr 输出用法
+func parseConfigArgs(args []string, getenv func(string) string, stderr io.Writer) (*configArgs, error) {
+	parsed := &configArgs{values: make(map[string]string)}
+	for _, o := range configOverrides {
+		if value := getenv(o.Env); value != "" {
+			parsed.values[o.Flag] = value
+		}
+	}
+	parsed.path = getenv("EASYLPAC_CONFIG")
+	parsed.portable, _ = strconv.ParseBool(getenv("EASYLPAC_PORTABLE"))
+
+	fs := flag.NewFlagSet("EasyLPAC", flag.ContinueOnError)
+	fs.StringVar(&parsed.path, "config", parsed.path, "config file path")
+	fs.BoolVar(&parsed.portable, "portable", parsed.portable, "store the config next to the executable")
+	for _, o := range configOverrides {
+		set := func(value string) error {
+			parsed.values[o.Flag] = value
+			return nil
+		}
+		if _, ok := o.field(&ConfigFile{}).(*bool); ok {
+			fs.BoolFunc(o.Flag, o.Usage, set)
+		} else {
+			fs.Func(o.Flag, o.Usage, set)
+		}
+	}
+	// 无法识别的参数只作为警告显示，不输出用法
+	fs.SetOutput(io.Discard)
+	if err := fs.Parse(args); errors.Is(err, flag.ErrHelp) {
+		fs.SetOutput(stderr)
+		fs.Usage()
+		return nil, err
+	} else if err != nil {
+		// 系统或启动器可能传入无法识别的参数，忽略剩余的参数并启动界面
+		parsed.warnings = append(parsed.warnings, err.Error())
+		return parsed, nil
+	}
+	parsed.rest = fs.Args()
+	return parsed, nil
+}
+
+// configPath 返回配置文件的位置
+// 便携模式下保存在程序目录，否则保存在用户配置目录（Linux 上为 $XDG_CONFIG_HOME）
+func configPath(args *configArgs, exeDir string) (path string, portable bool, err error) {
+	if args.path != "" {
+		return args.path, args.portable, nil
+	}
+	portable = args.portable
+	for _, name := range []string{portableMarker, configFilename} {
+		if _, err := os.Stat(filepath.Join(exeDir, name)); err == nil {
+			portable = true
+		}
+	}
+	if portable {
+		return filepath.Join(exeDir, configFilename), true, nil
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", false, err
+	}
+	return filepath.Join(dir, "EasyLPAC", configFilename), false, nil
+}
+
+// readConfigFile 读取并迁移配置文件，文件不存在时返回 nil
+func readConfigFile(path string) (*ConfigFile, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var raw map[string]any
+	if err = json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	version := 1
+	if v, ok := raw["version"].(float64); ok {
+		version = int(v)
+	}
+	if version > ConfigVersion {
+		return nil, fmt.Errorf("%s: config version %d is newer than supported version %d", path, version, ConfigVersion)
+	}
+	for ; version < ConfigVersion; version++ {
+		if migrate, ok := configMigrations[version]; ok {
+			migrate(raw)
+		}
+	}
+	raw["version"] = ConfigVersion
+	if data, err = json.Marshal(raw); err != nil {
+		return nil, err
+	}
+	f := defaultConfigFile()
+	if err = json.Unmarshal(data, f); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return f, nil
+}
+
+func writeConfigFile(path string, f *ConfigFile) error {
+	data, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	// 先写入临时文件再替换，避免写入中断导致配置损坏
+	tmp := path + ".tmp"
+	if err = os.WriteFile(tmp, append(data, '\n'), 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func defaultConfigFile() *ConfigFile {
+	f := &ConfigFile{
+		Version:     ConfigVersion,
+		LpacAID:     AID_DEFAULT,
+		ApduBackend: "pcsc",
+		HttpBackend: "curl",
+		UimSlot:     1,
+	}
+	f.SwitchGrace = time.Duration(0).String()
+	f.NotificationPolicy = DefaultNotificationPolicy()
+	f.NotificationWarnAfter = DefaultNotificationWarnAfter.String()
+	f.Timeouts.Query = DefaultTimeouts.Query.String()
+	f.Timeouts.Card = DefaultTimeouts.Card.String()
+	f.Timeouts.Network = DefaultTimeouts.Network.String()
+	f.API.Listen = DefaultAPIListen
+	return f
+}
+
+var aidPattern = regexp.MustCompile(`^[[:xdigit:]]{32}$`)
+
+// validateConfigFile 把不合法的值恢复为默认值，返回说明
+func validateConfigFile(f *ConfigFile) []string {
+	var warnings []string
+	defaults := defaultConfigFile()
+	reset := func(name string, value any) {
+		warnings = append(warnings, fmt.Sprintf("%s: invalid value %q", name, fmt.Sprint(value)))
+	}
+	if !aidPattern.MatchString(f.LpacAID) {
+		reset("lpac_aid", f.LpacAID)
+		f.LpacAID = defaults.LpacAID
+	}
+	f.LpacAID = strings.ToUpper(f.LpacAID)
+	if !slices.Contains([]string{"", "en", "zh-TW", "ja-JP"}, f.Language) {
+		reset("language", f.Language)
+		f.Language = defaults.Language
+	}
+	if !slices.ContainsFunc(ApduBackends, func(b ApduBackend) bool { return b.Name == f.ApduBackend }) {
+		reset("apdu_backend", f.ApduBackend)
+		f.ApduBackend = defaults.ApduBackend
+	}
+	if !slices.Contains(HttpBackends, f.HttpBackend) {
+		reset("http_backend", f.HttpBackend)
+		f.HttpBackend = defaults.HttpBackend
+	}
+	if f.UimSlot < 1 {
+		reset("uim_slot", f.UimSlot)
+		f.UimSlot = defaults.UimSlot
+	}
+	for _, timeout := range []struct {
+		name  string
+		value *string
+		def   string
+	}{
+		{"timeouts.query", &f.Timeouts.Query, defaults.Timeouts.Query},
+		{"timeouts.card", &f.Timeouts.Card, defaults.Timeouts.Card},
+		{"timeouts.network", &f.Timeouts.Network, defaults.Timeouts.Network},
+	} {
+		if d, err := time.ParseDuration(*timeout.value); err != nil || d <= 0 {
+			reset(timeout.name, *timeout.value)
+			*timeout.value = timeout.def
+		}
+	}
+	if d, err := time.ParseDuration(f.SwitchGrace); err != nil || d < 0 {
+		reset("switch_grace_period", f.SwitchGrace)
+		f.SwitchGrace = defaults.SwitchGrace
+	}
+	if d, err := time.ParseDuration(f.NotificationWarnAfter); err != nil || d < 0 {
+		reset("notification_warn_after", f.NotificationWarnAfter)
+		f.NotificationWarnAfter = defaults.NotificationWarnAfter
+	}
+	if err := validateAPIListen(f.API.Listen); err != nil {
+		reset("api.listen", f.API.Listen)
+		f.API.Listen = defaults.API.Listen
+	}
+	if f.NotificationPolicy == nil {
+		f.NotificationPolicy = defaults.NotificationPolicy
+	}
+	warnings = append(warnings, f.NotificationPolicy.validate()...)
+	names := make(map[string]bool)
+	f.Schedules = slices.DeleteFunc(f.Schedules, func(e *ScheduleEntry) bool {
+		if e == nil {
+			return true
+		}
+		err := e.Validate()
+		if err == nil && names[e.Name] {
+			err = ErrScheduleDuplicateName
+		}
+		if err != nil {
+			warnings = append(warnings, fmt.Sprintf("schedules.%s: %v", e.Name, err))
+			return true
+		}
+		names[e.Name] = true
+		return false
+	})
+	for name, memories := range map[string]map[string]*CardMemory{"readers": f.Readers, "cards": f.Cards} {
+		for key, m := range memories {
+			if m == nil {
+				delete(memories, key)
+			} else if validateCardMemory(m) {
+				warnings = append(warnings, fmt.Sprintf("%s.%s: invalid value", name, key))
+			}
+		}
+	}
+	return warnings
+}
+
+// applyOverrides 把命令行参数和环境变量写入 f，返回被覆盖的设置
+func applyOverrides(f *ConfigFile, values map[string]string) ([]string, error) {
+	var overridden []string
+	for _, o := range configOverrides {
+		value, ok := values[o.Flag]
+		if !ok {
+			continue
+		}
+		switch field := o.field(f).(type) {
+		case *string:
+			*field = value
+		case *bool:
+			b, err := strconv.ParseBool(value)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", o.Flag, err)
+			}
+			*field = b
+		case *int:
+			i, err := strconv.Atoi(value)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", o.Flag, err)
+			}
+			*field = i
+		}
+		overridden = append(overridden, o.Flag)
+	}
+	return overridden, nil
+}
+
+// applyConfigFile 把配置文件中的设置应用到 ConfigInstance
+func applyConfigFile(f *ConfigFile) {
+	if f.LpacDir != "" {
+		ConfigInstance.LpacDir = f.LpacDir
+	}
+	ConfigInstance.LpacAID = f.LpacAID
+	ConfigInstance.Language = f.Language
+	ConfigInstance.DebugHTTP = f.DebugHTTP
+	ConfigInstance.DebugAPDU = f.DebugAPDU
+	ConfigInstance.HideTestProfiles = f.HideTest
+	ConfigInstance.SwitchGracePeriod, _ = time.ParseDuration(f.SwitchGrace)
+	ConfigInstance.ReaderName = f.Reader
+	ConfigInstance.ApduBackend = f.ApduBackend
+	ConfigInstance.HttpBackend = f.HttpBackend
+	ConfigInstance.ApduDevice = f.ApduDevice
+	ConfigInstance.UimSlot = f.UimSlot
+	ConfigInstance.MbimProxy = f.MbimProxy
+	ConfigInstance.Readers = f.Readers
+	ConfigInstance.Cards = f.Cards
+	ConfigInstance.Schedules = f.Schedules
+	ConfigInstance.NotificationPolicy = f.NotificationPolicy
+	ConfigInstance.NotificationWarnAfter, _ = time.ParseDuration(f.NotificationWarnAfter)
+	ConfigInstance.APIEnabled = f.API.Enabled
+	ConfigInstance.APIListen = f.API.Listen
+	ConfigInstance.APIToken = f.API.Token
+	ConfigInstance.Timeouts.Query, _ = time.ParseDuration(f.Timeouts.Query)
+	ConfigInstance.Timeouts.Card, _ = time.ParseDuration(f.Timeouts.Card)
+	ConfigInstance.Timeouts.Network, _ = time.ParseDuration(f.Timeouts.Network)
+}
+
+// currentConfigFile 从 ConfigInstance 生成要保存的配置
+func currentConfigFile() *ConfigFile {
+	f := defaultConfigFile()
+	if configState.saved != nil {
+		f.LpacDir = configState.saved.LpacDir
+	}
+	f.LpacAID = ConfigInstance.LpacAID
+	f.Language = ConfigInstance.Language
+	f.DebugHTTP = ConfigInstance.DebugHTTP
+	f.DebugAPDU = ConfigInstance.DebugAPDU
+	f.HideTest = ConfigInstance.HideTestProfiles
+	f.SwitchGrace = ConfigInstance.SwitchGracePeriod.String()
+	f.Reader = ConfigInstance.ReaderName
+	f.ApduBackend = ConfigInstance.ApduBackend
+	f.HttpBackend = ConfigInstance.HttpBackend
+	f.ApduDevice = ConfigInstance.ApduDevice
+	f.UimSlot = ConfigInstance.UimSlot
+	f.MbimProxy = ConfigInstance.MbimProxy
+	f.Readers = ConfigInstance.Readers
+	f.Cards = ConfigInstance.Cards
+	f.Schedules = ConfigInstance.Schedules
+	f.NotificationPolicy = ConfigInstance.NotificationPolicy
+	f.NotificationWarnAfter = ConfigInstance.NotificationWarnAfter.String()
+	f.API.Enabled = ConfigInstance.APIEnabled
+	f.API.Listen = ConfigInstance.APIListen
+	f.API.Token = ConfigInstance.APIToken
+	f.Timeouts.Query = ConfigInstance.Timeouts.Query.String()
+	f.Timeouts.Card = ConfigInstance.Timeouts.Card.String()
+	f.Timeouts.Network = ConfigInstance.Timeouts.Network.String()
+	return f
+}
+
+// configState 记录配置文件的加载状态
+var configState struct {
+	saved      *ConfigFile // 磁盘上的配置，被覆盖的设置保存时使用这里的值
+	overridden []string
+	readOnly   bool // 配置文件来自更新的版本或无法解析，不覆盖它
+}
+
+// loadConfigFile 加载配置文件并应用命令行参数和环境变量
+func loadConfigFile(args *configArgs, exeDir string) error {
+	path, portable, err := configPath(args, exeDir)
+	if err != nil {
+		return err
+	}
+	ConfigInstance.ConfigPath = path
+	ConfigInstance.Portable = portable
+	ConfigInstance.ConfigWarnings = append(ConfigInstance.ConfigWarnings, args.warnings...)
+
+	f, err := readConfigFile(path)
+	if err != nil {
+		configState.readOnly = true
+		ConfigInstance.ConfigWarnings = append(ConfigInstance.ConfigWarnings, err.Error())
+	}
+	if f == nil {
+		f = defaultConfigFile()
+	}
+	ConfigInstance.ConfigWarnings = append(ConfigInstance.ConfigWarnings, validateConfigFile(f)...)
+	saved := *f
+	configState.saved = &saved
+
+	if configState.overridden, err = applyOverrides(f, args.values); err != nil {
+		return err
+	}
+	ConfigInstance.ConfigWarnings = append(ConfigInstance.ConfigWarnings, validateConfigFile(f)...)
+	applyConfigFile(f)
+	return nil
+}
+
+// SaveConfig 把当前设置写入配置文件
+func SaveConfig() error {
+	if ConfigInstance.ConfigPath == "" || configState.readOnly {
+		return nil
+	}
+	f := currentConfigFile()
+	for _, o := range configOverrides {
+		if !slices.Contains(configState.overridden, o.Flag) {
+			continue
+		}
+		switch field := o.field(f).(type) {
+		case *string:
+			*field = *o.field(configState.saved).(*string)
+		case *bool:
+			*field = *o.field(configState.saved).(*bool)
+		case *int:
+			*field = *o.field(configState.saved).(*int)
+		}
+	}
+	if err := writeConfigFile(ConfigInstance.ConfigPath, f); err != nil {
+		return err
+	}
+	configState.saved = f
+	return nil
+}
+
+// ConfigChanged 在设置被修改后保存配置，失败时写入日志
+func ConfigChanged() {
+	if err := SaveConfig(); err != nil && ConfigInstance.LogFile != nil {
+		fmt.Fprintln(ConfigInstance.LogFile, "failed to save config:", err)
+	}
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// useConfigFile 在测试期间使用临时目录中的配置文件
+func useConfigFile(t *testing.T, content string) string {
+	path := filepath.Join(t.TempDir(), configFilename)
+	if content != "" {
+		require.NoError(t, os.WriteFile(path, []byte(content), 0600))
+	}
+	origin, originState := ConfigInstance, configState
+	t.Cleanup(func() {
+		ConfigInstance, configState = origin, originState
+	})
+	return path
+}
+
+func TestConfigFileRoundTrip(t *testing.T) {
+	path := useConfigFile(t, "")
+	args, err := parseConfigArgs([]string{"-config", path}, func(string) string { return "" }, io.Discard)
+	require.NoError(t, err)
+	require.NoError(t, loadConfigFile(args, t.TempDir()))
+	assert.Equal(t, AID_DEFAULT, ConfigInstance.LpacAID)
+	assert.Empty(t, ConfigInstance.ConfigWarnings)
+
+	ConfigInstance.LpacAID = AID_5BER
+	ConfigInstance.Language = "ja-JP"
+	ConfigInstance.ReaderName = "Fake Card Reader 00 00"
+	ConfigInstance.Timeouts.Network = 5 * time.Minute
+	ConfigInstance.SwitchGracePeriod = 30 * time.Second
+	require.NoError(t, SaveConfig())
+
+	saved, err := readConfigFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, ConfigVersion, saved.Version)
+	assert.Equal(t, AID_5BER, saved.LpacAID)
+	assert.Equal(t, "ja-JP", saved.Language)
+	assert.Equal(t, "Fake Card Reader 00 00", saved.Reader)
+	assert.Equal(t, "5m0s", saved.Timeouts.Network)
+	assert.Equal(t, "30s", saved.SwitchGrace)
+}
+
+func TestConfigFileValidation(t *testing.T) {
+	path := useConfigFile(t, `{"lpac_aid":"not-hex","language":"fr","uim_slot":0,"timeouts":{"query":"-1s","card":"10s","network":"soon"},
+		"schedules":[{"name":"home","iccid":"8988303000000000002","cron":"0 8 * * 1-5"},{"name":"roaming","iccid":"8988303000000000010","cron":"0 25 * * *"},
+		{"name":"home","iccid":"8988303000000000002","from":"2026-01-01 08:00"}]}`)
+	args, err := parseConfigArgs([]string{"-config", path}, func(string) string { return "" }, io.Discard)
+	require.NoError(t, err)
+	require.NoError(t, loadConfigFile(args, t.TempDir()))
+	assert.Len(t, ConfigInstance.ConfigWarnings, 7)
+	require.Len(t, ConfigInstance.Schedules, 1)
+	assert.Equal(t, "0 8 * * 1-5", ConfigInstance.Schedules[0].Cron)
+	assert.Equal(t, AID_DEFAULT, ConfigInstance.LpacAID)
+	assert.Equal(t, "", ConfigInstance.Language)
+	assert.Equal(t, 1, ConfigInstance.UimSlot)
+	assert.Equal(t, DefaultTimeouts.Query, ConfigInstance.Timeouts.Query)
+	assert.Equal(t, 10*time.Second, ConfigInstance.Timeouts.Card)
+}
+
+func TestConfigOverridesAreNotSaved(t *testing.T) {
+	path := useConfigFile(t, `{"version":1,"lpac_aid":"`+AID_DEFAULT+`","debug_apdu":false}`)
+	env := map[string]string{"EASYLPAC_AID": AID_ESIMME, "EASYLPAC_DEBUG_APDU": "false"}
+	args, err := parseConfigArgs([]string{"-config", path, "-debug-apdu"}, func(key string) string { return env[key] }, io.Discard)
+	require.NoError(t, err)
+	require.NoError(t, loadConfigFile(args, t.TempDir()))
+	assert.Equal(t, AID_ESIMME, ConfigInstance.LpacAID)
+	assert.True(t, ConfigInstance.DebugAPDU, "flags take priority over the environment")
+
+	ConfigInstance.Language = "zh-TW"
+	require.NoError(t, SaveConfig())
+	saved, err := readConfigFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, AID_DEFAULT, saved.LpacAID)
+	assert.False(t, saved.DebugAPDU)
+	assert.Equal(t, "zh-TW", saved.Language)
+}
+
+func TestConfigUnknownArgument(t *testing.T) {
+	path := useConfigFile(t, "")
+	args, err := parseConfigArgs([]string{"-config", path, "-psn_0_12345", "cli"}, func(string) string { return "" }, io.Discard)
+	require.NoError(t, err)
+	assert.Empty(t, args.rest)
+	require.NoError(t, loadConfigFile(args, t.TempDir()))
+	assert.Len(t, ConfigInstance.ConfigWarnings, 1)
+	assert.Contains(t, ConfigInstance.ConfigWarnings[0], "psn_0_12345")
+
+	// 只有 -h 输出用法
+	var usage strings.Builder
+	_, err = parseConfigArgs([]string{"-h"}, func(string) string { return "" }, &usage)
+	assert.ErrorIs(t, err, flag.ErrHelp)
+	assert.Contains(t, usage.String(), "-config")
+}
+
+func TestConfigMigrationAutoMode(t *testing.T) {
+	for _, test := range []struct {
+		content string
+		policy  *NotificationPolicy
+	}{
+		{`{"auto_mode":true}`, DefaultNotificationPolicy()},
+		{`{"version":1,"auto_mode":false}`, AskNotificationPolicy()},
+		{`{"version":1}`, DefaultNotificationPolicy()},
+	} {
+		path := useConfigFile(t, test.content)
+		args, err := parseConfigArgs([]string{"-config", path}, func(string) string { return "" }, io.Discard)
+		require.NoError(t, err)
+		require.NoError(t, loadConfigFile(args, t.TempDir()))
+		assert.Empty(t, ConfigInstance.ConfigWarnings)
+		assert.Equal(t, test.policy, ConfigInstance.NotificationPolicy, test.content)
+	}
+}
+
+func TestConfigFileFromNewerVersion(t *testing.T) {
+	content := `{"version":99,"lpac_aid":"` + AID_5BER + `"}`
+	path := useConfigFile(t, content)
+	args, err := parseConfigArgs([]string{"-config", path}, func(string) string { return "" }, io.Discard)
+	require.NoError(t, err)
+	require.NoError(t, loadConfigFile(args, t.TempDir()))
+	assert.NotEmpty(t, ConfigInstance.ConfigWarnings)
+
+	require.NoError(t, SaveConfig())
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, content, string(data), "a newer config must not be overwritten")
+}
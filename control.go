@@ -6,42 +6,123 @@ import (
 	"fyne.io/fyne/v2/dialog"
 	"fyne.io/fyne/v2/theme"
 	"fyne.io/fyne/v2/widget"
+	"github.com/fullpipe/icu-mf/mf"
 	"math"
 	"os/exec"
 	"runtime"
 	"sort"
 	"strings"
+	"time"
 )
 
-const StatusProcess = 1
-const StatusReady = 0
 const Unselected = -1
 
-var SelectedProfile = Unselected
+// SelectedProfileIccid 记录选中 Profile 的 ICCID，筛选、排序或刷新后仍然指向同一个 Profile
+var SelectedProfileIccid string
 var SelectedNotification = Unselected
 
+// VisibleProfiles 是 Profiles 经过 CurrentProfileFilter 筛选和排序后显示在列表中的部分
+var VisibleProfiles []*Profile
+var CurrentProfileFilter ProfileFilter
+
+// CheckedProfileIccids 记录列表中勾选用于批量操作的 Profile
+var CheckedProfileIccids = make(map[string]bool)
+
 var RefreshNeeded = true
 var ProfileMaskNeeded bool
 var NotificationMaskNeeded bool
 var ProfileStateAllowDisable bool
 
-var StatusChan = make(chan int)
-var LockButtonChan = make(chan bool)
-
 func RefreshProfile() error {
 	var err error
 	Profiles, err = LpacProfileList()
 	if err != nil {
 		return err
 	}
-	// 刷新 List
+	ApplyProfileFilter()
+	return nil
+}
+
+// ApplyProfileFilter 重新筛选 Profile 列表，选中的 Profile 仍然可见时保持选中
+func ApplyProfileFilter() {
+	selected := SelectedProfileIccid
+	CurrentProfileFilter.HideTest = ConfigInstance.HideTestProfiles
+	VisibleProfiles = CurrentProfileFilter.Apply(Profiles)
+	if CurrentProfileFilter.Active() {
+		ProfileFilterLabel.SetText(TR.Trans("label.profile_filter_count",
+			mf.Arg("shown", len(VisibleProfiles)), mf.Arg("total", len(Profiles))))
+		ProfileFilterLabel.Show()
+	} else {
+		ProfileFilterLabel.Hide()
+	}
+	// 已删除或被筛选隐藏的 Profile 取消勾选，批量操作只针对看得到的 Profile
+	checked := CheckedProfiles()
+	clear(CheckedProfileIccids)
+	for _, p := range checked {
+		CheckedProfileIccids[p.Iccid] = true
+	}
+	updateBulkActions()
 	ProfileList.Refresh()
+	for i, p := range VisibleProfiles {
+		if p.Iccid == selected {
+			ProfileList.Select(i)
+			SelectedProfileIccid = selected
+			updateSwitchStateButton(p)
+			return
+		}
+	}
 	ProfileList.UnselectAll()
-	SwitchStateButton.SetText(TR.Trans("label.switch_state_button_enable"))
-	SwitchStateButton.SetIcon(theme.ConfirmIcon())
+	SelectedProfileIccid = ""
+	updateSwitchStateButton(nil)
+}
+
+// SelectedProfile 返回选中的 Profile，未选择或 Profile 已不存在时返回 nil
+func SelectedProfile() *Profile {
+	if SelectedProfileIccid == "" {
+		return nil
+	}
+	for _, p := range Profiles {
+		if p.Iccid == SelectedProfileIccid {
+			return p
+		}
+	}
 	return nil
 }
 
+// CheckedProfiles 按列表顺序返回勾选的 Profile
+func CheckedProfiles() []*Profile {
+	var checked []*Profile
+	for _, p := range VisibleProfiles {
+		if CheckedProfileIccids[p.Iccid] {
+			checked = append(checked, p)
+		}
+	}
+	return checked
+}
+
+// updateBulkActions 有勾选的 Profile 时显示批量操作栏
+func updateBulkActions() {
+	count := len(CheckedProfiles())
+	BulkSelectionLabel.SetText(TR.Trans("label.bulk_selected", mf.Arg("count", count)))
+	if count == 0 {
+		BulkActionBar.Hide()
+	} else {
+		BulkActionBar.Show()
+	}
+}
+
+// updateSwitchStateButton 按选中 Profile 的状态切换启用或禁用按钮
+func updateSwitchStateButton(p *Profile) {
+	ProfileStateAllowDisable = p != nil && p.ProfileState == "enabled"
+	if ProfileStateAllowDisable {
+		SwitchStateButton.SetText(TR.Trans("label.switch_state_button_disable"))
+		SwitchStateButton.SetIcon(theme.CancelIcon())
+	} else {
+		SwitchStateButton.SetText(TR.Trans("label.switch_state_button_enable"))
+		SwitchStateButton.SetIcon(theme.ConfirmIcon())
+	}
+}
+
 func RefreshNotification() error {
 	var err error
 	Notifications, err = LpacNotificationList()
@@ -51,13 +132,30 @@ func RefreshNotification() error {
 	sort.Slice(Notifications, func(i, j int) bool {
 		return Notifications[i].SeqNumber < Notifications[j].SeqNumber
 	})
+	NotificationsSeen.Observe(currentEid(), Notifications)
 	// 刷新 List
 	NotificationList.Refresh()
 	NotificationList.UnselectAll()
+	RefreshNotificationGroups()
 	return nil
 }
 
+// RefreshNotificationGroups 按选择的方式重新分组，不分组时显示原来的列表
+func RefreshNotificationGroups() {
+	if NotificationGroupBy == GroupNone {
+		NotificationGroups = nil
+		NotificationGroupList.Hide()
+		NotificationList.Show()
+		return
+	}
+	NotificationGroups = GroupNotifications(Notifications, NotificationGroupBy)
+	NotificationList.Hide()
+	NotificationGroupList.Show()
+	NotificationGroupList.Refresh()
+}
+
 func RefreshChipInfo() error {
+	previous := currentEid()
 	var err error
 	ChipInfo, err = LpacChipInfo()
 	if err != nil {
@@ -66,6 +164,13 @@ func RefreshChipInfo() error {
 	if ChipInfo == nil {
 		return nil
 	}
+	RememberWorkingAID()
+	// 更换了卡片
+	if previous != "" && !strings.EqualFold(previous, ChipInfo.EidValue) {
+		WarnOverdueNotifications(previous)
+	}
+	overdueWarnedEid = ""
+	NotificationRetries.SetCard(ChipInfo.EidValue)
 
 	convertToString := func(value interface{}) string {
 		if value == nil {
@@ -106,12 +211,14 @@ func RefreshChipInfo() error {
 	ViewCertInfoButton.Show()
 	EUICCManufacturerLabel.Show()
 	CopyEuiccInfo2Button.Show()
+	ExportReportButton.Show()
+	UpdateNotificationRetries()
 	return nil
 }
 
 func RefreshApduDriver() {
 	var err error
-	ApduDrivers, err = LpacDriverApduList()
+	ApduDrivers, err = ListApduDrivers()
 	if err != nil {
 		ShowLpacErrDialog(err)
 	}
@@ -161,70 +268,144 @@ func Refresh() {
 		ShowSelectCardReaderDialog()
 		return
 	}
+	eid := currentEid()
+	showErr := func(err error) {
+		ShowLpacErrDialog(err)
+		// 卡片被取出或读卡器被拔出
+		if lpacErr, ok := AsLpacError(err); ok && lpacErr.Class == ErrorClassReader {
+			WarnOverdueNotifications(eid)
+		}
+	}
 	err := RefreshProfile()
+	// 当前 AID 无法访问 eUICC 时尝试记住的其他 AID，例如更换了卡片
+	if lpacErr, ok := AsLpacError(err); ok && lpacErr.Key == "euicc_init" && RecoverAID() {
+		err = RefreshProfile()
+	}
 	if err != nil {
-		ShowLpacErrDialog(err)
+		showErr(err)
 		return
 	}
-	err = RefreshNotification()
+	// 先读取 EID，通知记录才能对应到正确的卡片
+	err = RefreshChipInfo()
 	if err != nil {
-		ShowLpacErrDialog(err)
+		showErr(err)
 		return
 	}
-	err = RefreshChipInfo()
+	err = RefreshNotification()
 	if err != nil {
-		ShowLpacErrDialog(err)
+		showErr(err)
 		return
 	}
 	RefreshNeeded = false
 }
 
+// overdueWarnedEid 是已经提醒过的卡片，避免每次刷新失败都重复提醒
+var overdueWarnedEid string
+
+// WarnOverdueNotifications 在卡片 eid 被取出或更换后，提醒卡片上还有长时间未发送的通知
+func WarnOverdueNotifications(eid string) {
+	if eid == "" || strings.EqualFold(eid, overdueWarnedEid) {
+		return
+	}
+	overdue := NotificationsSeen.Overdue(eid, ConfigInstance.NotificationWarnAfter)
+	if len(overdue) == 0 {
+		return
+	}
+	overdueWarnedEid = eid
+	dialog.ShowInformation(TR.Trans("dialog.notification_overdue"),
+		TR.Trans("message.notification_overdue_removed", mf.Arg("count", len(overdue)), mf.Arg("eid", eid))+
+			"\n\n"+overdueNotificationsText(overdue), WMain)
+}
+
 func UpdateStatusBarListener() {
+	// 当前 lpac 命令的进度，两次进度之间由 ticker 更新已用时间
+	var progress LpacProgress
+	var progressReceived time.Time
+	var busy bool
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
 	for {
-		status := <-StatusChan
-		switch status {
-		case StatusProcess:
-			StatusLabel.SetText(TR.Trans("label.status_processing"))
-			StatusProcessBar.Start()
-			StatusProcessBar.Show()
+		select {
+		case <-CardJobs.Changed:
+			running, queued := CardJobs.Counts()
+			// 多步操作的两个命令之间也保持锁定，避免中途更换读卡器
+			if nowBusy := CardJobs.Busy(); nowBusy != busy {
+				busy = nowBusy
+				lockButtons(busy)
+				if busy {
+					StatusProcessBar.Start()
+					StatusProcessBar.Show()
+					CancelButton.Show()
+				} else {
+					progress = LpacProgress{}
+					StatusLabel.SetText(TR.Trans("label.status_ready"))
+					StatusProcessBar.Stop()
+					StatusProcessBar.Hide()
+					CancelButton.Hide()
+				}
+			}
+			CancelButton.Disable()
+			if running > 0 {
+				CancelButton.Enable()
+			}
+			if busy && (progress.Step == "" || progress.Done) {
+				StatusLabel.SetText(statusProcessingText(queued))
+			}
+			RefreshActivity()
 			continue
-		case StatusReady:
-			StatusLabel.SetText(TR.Trans("label.status_ready"))
-			StatusProcessBar.Stop()
-			StatusProcessBar.Hide()
+		case progress = <-ProgressChan:
+			progressReceived = time.Now()
+		case <-ticker.C:
+			if busy {
+				RefreshActivity()
+			}
+		}
+		if progress.Step == "" || progress.Done {
 			continue
 		}
+		shown := progress
+		shown.Elapsed += time.Since(progressReceived)
+		StatusLabel.SetText(shown.String())
+		if view := ActiveStageProgress.Load(); view != nil {
+			view.Update(shown)
+		}
 	}
 }
 
-func LockButtonListener() {
+func statusProcessingText(queued int) string {
+	if queued == 0 {
+		return TR.Trans("label.status_processing")
+	}
+	return TR.Trans("label.status_processing_queued", mf.Arg("count", queued))
+}
+
+// lockButtons 在队列中有任务时禁用会访问卡片的按钮
+func lockButtons(lock bool) {
 	buttons := []*widget.Button{
 		RefreshButton, DownloadButton, SetNicknameButton, SwitchStateButton, DeleteProfileButton,
+		ProfileCheckAllButton, BulkDeleteButton, BulkNicknameButton, BulkExportButton,
 		ProcessNotificationButton, ProcessAllNotificationButton, RemoveNotificationButton, BatchRemoveNotificationButton,
-		SetDefaultSmdpButton, ApduDriverRefreshButton,
+		SetDefaultSmdpButton, ExportReportButton, ApduDriverRefreshButton,
 	}
 	checks := []*widget.Check{
 		ProfileMaskCheck, NotificationMaskCheck,
 	}
-	for {
-		lock := <-LockButtonChan
-		if lock {
-			for _, button := range buttons {
-				button.Disable()
-			}
-			for _, check := range checks {
-				check.Disable()
-			}
-			ApduDriverSelect.Disable()
-		} else {
-			for _, button := range buttons {
-				button.Enable()
-			}
-			for _, check := range checks {
-				check.Enable()
-			}
-			ApduDriverSelect.Enable()
+	if lock {
+		for _, button := range buttons {
+			button.Disable()
 		}
+		for _, check := range checks {
+			check.Disable()
+		}
+		ApduDriverSelect.Disable()
+	} else {
+		for _, button := range buttons {
+			button.Enable()
+		}
+		for _, check := range checks {
+			check.Enable()
+		}
+		ApduDriverSelect.Enable()
 	}
 }
 
@@ -239,6 +420,152 @@ func SetDriverIFID(name string) {
 				ConfigInstance.DriverIFID = d.Env
 				RefreshNeeded = true
 			}
+			ConfigInstance.ReaderName = name
+			ConfigChanged()
+			ApplyReaderMemory(name)
+		}
+	}
+}
+
+// SelectRememberedReader 选择上次使用的读卡器，不存在时选择第一个
+func SelectRememberedReader() {
+	for _, option := range ApduDriverSelect.Options {
+		if option == ConfigInstance.ReaderName {
+			ApduDriverSelect.SetSelected(option)
+			return
+		}
+	}
+	if len(ApduDriverSelect.Options) > 0 {
+		ApduDriverSelect.SetSelectedIndex(0)
+	}
+}
+
+// StartAPIServer 按设置启动本地 API 服务，已经启动时先停止
+func StartAPIServer() error {
+	StopAPIServer()
+	if ConfigInstance.APIToken == "" {
+		ConfigInstance.APIToken = NewAPIToken()
+		ConfigChanged()
+	}
+	server := NewAPIServer(ConfigInstance.APIToken)
+	// 通过 API 的操作同步到界面
+	server.SelectReader = func(d *ApduDriver) {
+		RefreshApduDriver()
+		ApduDriverSelect.SetSelected(d.Name)
+		go Refresh()
+	}
+	server.CardChanged = func() {
+		go Refresh()
+	}
+	if err := server.Start(ConfigInstance.APIListen); err != nil {
+		return err
+	}
+	APIServerInstance = server
+	return nil
+}
+
+func StopAPIServer() {
+	if APIServerInstance != nil {
+		_ = APIServerInstance.Close()
+		APIServerInstance = nil
+	}
+}
+
+// StartScheduler 启动定时切换，执行时同步读卡器选择和界面
+func StartScheduler() {
+	scheduler := NewScheduler(ConfigInstance.Schedules)
+	scheduler.SelectReader = func(d *ApduDriver) {
+		RefreshApduDriver()
+		ApduDriverSelect.SetSelected(d.Name)
+	}
+	scheduler.CardChanged = func() {
+		go Refresh()
+	}
+	scheduler.OnRun = func(*ScheduleRun) {
+		if ScheduleHistoryList != nil {
+			ScheduleHistoryList.Refresh()
+		}
+	}
+	scheduler.Start()
+	SchedulerInstance = scheduler
+}
+
+func StopScheduler() {
+	if SchedulerInstance != nil {
+		SchedulerInstance.Stop()
+		SchedulerInstance = nil
+	}
+}
+
+// StartNotificationRetries 在后台重新发送失败的通知，成功后刷新界面
+func StartNotificationRetries() {
+	NotificationRetries.OnChange = UpdateNotificationRetries
+	NotificationRetries.CardChanged = func() {
+		go Refresh()
+	}
+	NotificationRetries.Start()
+}
+
+// UpdateNotificationRetries 在通知页面提示当前卡片等待重试和长时间未发送成功的通知
+func UpdateNotificationRetries() {
+	var queued, stale, otherCards int
+	var next time.Time
+	now := time.Now()
+	for _, item := range NotificationRetries.Items() {
+		switch {
+		case !strings.EqualFold(item.EID, currentEid()):
+			otherCards++
+		case item.Stale(now):
+			stale++
+		default:
+			queued++
+			if next.IsZero() || item.NextAttempt.Before(next) {
+				next = item.NextAttempt
+			}
 		}
 	}
+	var lines []string
+	if stale > 0 {
+		lines = append(lines, TR.Trans("message.notification_retry_stale", mf.Arg("count", stale)))
+	}
+	if queued > 0 {
+		lines = append(lines, TR.Trans("message.notification_retry_queued",
+			mf.Arg("count", queued), mf.Arg("time", next.Format("15:04"))))
+	}
+	if otherCards > 0 {
+		lines = append(lines, TR.Trans("message.notification_retry_other_cards", mf.Arg("count", otherCards)))
+	}
+	NotificationRetryLabel.SetText(strings.Join(lines, "\n"))
+	NotificationRetryLabel.Importance = widget.WarningImportance
+	if stale > 0 {
+		NotificationRetryLabel.Importance = widget.DangerImportance
+	}
+	if len(lines) > 0 {
+		NotificationRetryLabel.Show()
+	} else {
+		NotificationRetryLabel.Hide()
+	}
+	NotificationRetryLabel.Refresh()
+	NotificationList.Refresh()
+}
+
+// SetSchedules 保存计划列表并应用到正在运行的定时切换
+func SetSchedules(entries []*ScheduleEntry) {
+	ConfigInstance.Schedules = entries
+	ConfigChanged()
+	if SchedulerInstance != nil {
+		SchedulerInstance.SetEntries(entries)
+	}
+}
+
+// UpdateAPIStatus 在设置页面显示 API 服务的监听地址或启动失败的原因
+func UpdateAPIStatus(err error) {
+	switch {
+	case err != nil:
+		APIStatusLabel.SetText(TR.Trans("message.api_server_failed") + " " + err.Error())
+	case APIServerInstance != nil:
+		APIStatusLabel.SetText(TR.Trans("label.api_listening", mf.Arg("address", APIServerInstance.Addr())))
+	default:
+		APIStatusLabel.SetText("")
+	}
 }
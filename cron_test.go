@@ -0,0 +1,46 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseCronSpec(t *testing.T) {
+	for _, spec := range []string{"", "* * * *", "60 * * * *", "*/0 * * * *", "5-1 * * * *", "* * 0 * *", "a * * * *"} {
+		_, err := ParseCronSpec(spec)
+		assert.Error(t, err, spec)
+	}
+
+	c, err := ParseCronSpec("*/15 8-18/2 * * 1-5")
+	require.NoError(t, err)
+	// 2026-10-19 是星期一
+	assert.True(t, c.Matches(time.Date(2026, 10, 19, 8, 45, 0, 0, time.UTC)))
+	assert.False(t, c.Matches(time.Date(2026, 10, 19, 9, 0, 0, 0, time.UTC)))
+	assert.False(t, c.Matches(time.Date(2026, 10, 18, 8, 0, 0, 0, time.UTC)))
+
+	// 日和周都有限制时满足其一即可，周日可以写成 7
+	c, err = ParseCronSpec("0 0 1 * 7")
+	require.NoError(t, err)
+	assert.True(t, c.Matches(time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)))
+	assert.True(t, c.Matches(time.Date(2026, 10, 18, 0, 0, 0, 0, time.UTC)))
+	assert.False(t, c.Matches(time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC)))
+}
+
+func TestCronSpecNext(t *testing.T) {
+	c, err := ParseCronSpec("30 7 * * 1-5")
+	require.NoError(t, err)
+	// 星期五之后是下周一
+	next := c.Next(time.Date(2026, 10, 23, 7, 30, 0, 0, time.UTC))
+	assert.Equal(t, time.Date(2026, 10, 26, 7, 30, 0, 0, time.UTC), next)
+
+	c, err = ParseCronSpec("0 12 29 2 *")
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2028, 2, 29, 12, 0, 0, 0, time.UTC), c.Next(time.Date(2026, 10, 18, 0, 0, 0, 0, time.UTC)))
+
+	c, err = ParseCronSpec("0 0 30 2 *")
+	require.NoError(t, err)
+	assert.True(t, c.Next(time.Date(2026, 10, 18, 0, 0, 0, 0, time.UTC)).IsZero())
+}
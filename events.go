@@ -0,0 +1,107 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// Event 是发布给 API 客户端的状态、任务或进度事件
+type Event struct {
+	Type     string        `json:"type"` // "status"、"job" 或 "progress"
+	Time     time.Time     `json:"time"`
+	Status   *StatusView   `json:"status,omitempty"`
+	Job      *JobView      `json:"job,omitempty"`
+	Progress *ProgressView `json:"progress,omitempty"`
+}
+
+// JobView 是 Job 对外公开的部分，不包含 Matching ID 等参数
+type JobView struct {
+	ID       int        `json:"id"`
+	Command  string     `json:"command"`
+	Reader   string     `json:"reader"`
+	AID      string     `json:"aid"`
+	State    string     `json:"state"`
+	Queued   time.Time  `json:"queued"`
+	Started  *time.Time `json:"started,omitempty"`
+	Finished *time.Time `json:"finished,omitempty"`
+	Error    *errorInfo `json:"error,omitempty"`
+}
+
+func newJobView(job *Job) *JobView {
+	v := &JobView{
+		ID:      job.ID,
+		Command: job.Command(),
+		Reader:  job.Reader,
+		AID:     job.AID,
+		State:   job.State.String(),
+		Queued:  job.Queued,
+	}
+	if !job.Started.IsZero() {
+		started := job.Started
+		v.Started = &started
+	}
+	if !job.Finished.IsZero() {
+		finished := job.Finished
+		v.Finished = &finished
+	}
+	if job.Err != nil {
+		info := newErrorInfo(job.Err)
+		v.Error = &info
+	}
+	return v
+}
+
+// ProgressView 是 LpacProgress 的 JSON 表示
+type ProgressView struct {
+	JobID   int    `json:"job_id"`
+	Command string `json:"command"`
+	Step    string `json:"step,omitempty"`
+	Stage   string `json:"stage,omitempty"`
+	Elapsed string `json:"elapsed"`
+	Done    bool   `json:"done"`
+}
+
+// EventHub 把事件广播给所有订阅者，订阅者来不及接收时丢弃事件
+type EventHub struct {
+	mu          sync.Mutex
+	subscribers map[chan Event]struct{}
+}
+
+const eventBufferSize = 64
+
+// Events 是任务队列和 lpac 进度共用的事件中心
+var Events = NewEventHub()
+
+func NewEventHub() *EventHub {
+	return &EventHub{subscribers: make(map[chan Event]struct{})}
+}
+
+// Subscribe 返回接收事件的 channel，不再需要时调用 cancel
+func (h *EventHub) Subscribe() (events <-chan Event, cancel func()) {
+	ch := make(chan Event, eventBufferSize)
+	h.mu.Lock()
+	h.subscribers[ch] = struct{}{}
+	h.mu.Unlock()
+	return ch, func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		if _, ok := h.subscribers[ch]; ok {
+			delete(h.subscribers, ch)
+			close(ch)
+		}
+	}
+}
+
+func (h *EventHub) Publish(e Event) {
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for ch := range h.subscribers {
+		select {
+		case ch <- e:
+		default:
+		}
+	}
+}
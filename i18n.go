@@ -49,6 +49,7 @@ func SetLanguage(langTag string) {
 	}
 	LanguageTag = langTag
 	ConfigInstance.Language = langTag
+	ConfigChanged()
 	TR = i18nBundle.Translator(LanguageTag)
 	RefreshAllUI()
 }
@@ -66,9 +67,15 @@ func RefreshAllUI() {
 	
 	// 刷新所有标签和按钮文本
 	StatusLabel.SetText(TR.Trans("label.status_ready"))
+	CancelButton.SetText(TR.Trans("dialog.cancel"))
 	DownloadButton.SetText(TR.Trans("label.download_profile_button"))
 	SetNicknameButton.SetText(TR.Trans("label.set_nickname_button"))
 	DeleteProfileButton.SetText(TR.Trans("label.delete_profile_button"))
+	BulkDeleteButton.SetText(TR.Trans("label.bulk_delete_button"))
+	BulkNicknameButton.SetText(TR.Trans("label.bulk_nickname_button"))
+	BulkExportButton.SetText(TR.Trans("label.bulk_export_button"))
+	BulkClearButton.SetText(TR.Trans("label.bulk_clear_button"))
+	updateBulkActions()
 	SwitchStateButton.SetText(TR.Trans("label.switch_state_button_enable"))
 	ProcessNotificationButton.SetText(TR.Trans("label.process_notification_button"))
 	ProcessAllNotificationButton.SetText(TR.Trans("label.process_all_notification_button"))
@@ -81,11 +88,15 @@ func RefreshAllUI() {
 	CopyEidButton.SetText(TR.Trans("label.copy_eid_button"))
 	ViewCertInfoButton.SetText(TR.Trans("label.view_cert_info_button"))
 	CopyEuiccInfo2Button.SetText(TR.Trans("label.copy_euicc_info2_button"))
+	ExportReportButton.SetText(TR.Trans("label.export_report_button"))
+	CancelJobButton.SetText(TR.Trans("label.cancel_job_button"))
+	ClearActivityButton.SetText(TR.Trans("label.clear_activity_button"))
 	
 	// 刷新标签页标题
 	ProfileTab.Text = TR.Trans("tab_bar.profile")
 	NotificationTab.Text = TR.Trans("tab_bar.notification")
 	ChipInfoTab.Text = TR.Trans("tab_bar.chip_info")
+	ActivityTab.Text = TR.Trans("tab_bar.activity")
 	SettingsTab.Text = TR.Trans("tab_bar.settings")
 	AboutTab.Text = TR.Trans("tab_bar.about")
 	
@@ -126,8 +137,12 @@ func RefreshAllUI() {
 	}
 	
 	// 刷新列表
+	RefreshProfileFilterOptions()
+	RefreshNotificationGroupOptions()
 	ProfileList.Refresh()
 	NotificationList.Refresh()
+	NotificationGroupList.Refresh()
+	ActivityList.Refresh()
 	
 	// 刷新窗口标题
 	WMain.SetTitle("EasyLPAC")
@@ -0,0 +1,81 @@
+package main
+
+import (
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// iin-registry.json 手动维护：按 E.164 国家代码对应国家，只收录发行者识别号公开且确定的运营商
+//
+//go:embed iin-registry.json
+var iinRegistryBundle []byte
+
+// IssuerIdentification 是 ICCID 前缀（发行者识别号，IIN）对应的国家和运营商
+type IssuerIdentification struct {
+	Prefix   string `json:"prefix"`
+	Country  string `json:"country"`
+	Operator string `json:"operator"`
+}
+
+var iinRegistry []*IssuerIdentification
+
+func InitIinRegistry() {
+	// 解码到新的切片，重复调用时不会复用已经排序过的记录
+	var registry []*IssuerIdentification
+	if err := json.Unmarshal(iinRegistryBundle, &registry); err != nil {
+		panic(err)
+	}
+	// 长的前缀优先，运营商的记录覆盖国家的记录
+	sort.SliceStable(registry, func(i, j int) bool {
+		return len(registry[i].Prefix) > len(registry[j].Prefix)
+	})
+	iinRegistry = registry
+}
+
+// GetIIN 返回与 ICCID 最长匹配的记录，未知时返回 nil
+func GetIIN(iccid string) *IssuerIdentification {
+	for _, identifier := range iinRegistry {
+		if strings.HasPrefix(iccid, identifier.Prefix) {
+			return identifier
+		}
+	}
+	return nil
+}
+
+// ValidateICCID 检查 ICCID 是否为 89 开头的 19 或 20 位数字，以及 Luhn 校验位
+func ValidateICCID(iccid string) error {
+	if iccid == "" || strings.Trim(iccid, "0123456789") != "" {
+		return errors.New("ICCID must contain only digits")
+	}
+	if len(iccid) != 19 && len(iccid) != 20 {
+		return fmt.Errorf("ICCID must be 19 or 20 digits, got %d", len(iccid))
+	}
+	if !strings.HasPrefix(iccid, "89") {
+		return errors.New("ICCID must start with 89")
+	}
+	if expected := luhnCheckDigit(iccid[:len(iccid)-1]); iccid[len(iccid)-1] != expected {
+		return fmt.Errorf("ICCID check digit is %c, expected %c", iccid[len(iccid)-1], expected)
+	}
+	return nil
+}
+
+// luhnCheckDigit 计算数字串末尾应追加的 Luhn 校验位
+func luhnCheckDigit(digits string) byte {
+	var sum int
+	for i := len(digits) - 1; i >= 0; i-- {
+		d := int(digits[i] - '0')
+		// 从校验位左边第一位开始，每隔一位乘 2
+		if (len(digits)-1-i)%2 == 0 {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+	}
+	return byte('0' + (10-sum%10)%10)
+}
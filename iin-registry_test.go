@@ -0,0 +1,37 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateICCID(t *testing.T) {
+	assert.NoError(t, ValidateICCID("8988303000000000002"))
+	assert.NoError(t, ValidateICCID("89860012345678901238"))
+	assert.EqualError(t, ValidateICCID("8988303000000000003"), "ICCID check digit is 3, expected 2")
+	// 相邻数字互换也能发现
+	assert.Error(t, ValidateICCID("8988303000000000020"))
+	assert.Error(t, ValidateICCID("898830300000000000"))
+	assert.Error(t, ValidateICCID("8988303O00000000002"))
+	assert.Error(t, ValidateICCID("1288303000000000002"))
+	assert.Error(t, ValidateICCID(""))
+}
+
+func TestGetIIN(t *testing.T) {
+	InitIinRegistry()
+	iin := GetIIN("8901260123456789011")
+	if assert.NotNil(t, iin) {
+		assert.Equal(t, "US", iin.Country)
+		assert.Equal(t, "T-Mobile", iin.Operator)
+	}
+	// 没有运营商记录时只返回国家
+	iin = GetIIN("8949000000000000001")
+	if assert.NotNil(t, iin) {
+		assert.Equal(t, "DE", iin.Country)
+		assert.Empty(t, iin.Operator)
+	}
+	// 89886 是台湾而不是 8988 开头的国际号段
+	assert.Equal(t, "TW", GetIIN("8988600000000000000").Country)
+	assert.Nil(t, GetIIN("8900000000000000000"))
+}
@@ -0,0 +1,315 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+)
+
+// JobState 是卡片操作在队列中的状态
+type JobState int
+
+const (
+	JobQueued JobState = iota
+	JobRunning
+	JobSucceeded
+	JobFailed
+	JobCancelled
+)
+
+func (s JobState) Name() string {
+	switch s {
+	case JobQueued:
+		return TR.Trans("label.job_state_queued")
+	case JobRunning:
+		return TR.Trans("label.job_state_running")
+	case JobSucceeded:
+		return TR.Trans("label.job_state_succeeded")
+	case JobCancelled:
+		return TR.Trans("label.job_state_cancelled")
+	default:
+		return TR.Trans("label.job_state_failed")
+	}
+}
+
+// String 返回 API 中使用的状态名
+func (s JobState) String() string {
+	switch s {
+	case JobQueued:
+		return "queued"
+	case JobRunning:
+		return "running"
+	case JobSucceeded:
+		return "succeeded"
+	case JobCancelled:
+		return "cancelled"
+	default:
+		return "failed"
+	}
+}
+
+// Job 是一次 lpac 调用
+type Job struct {
+	ID   int
+	Args []string
+	// Reader 是 DRIVER_IFID，AID 是 ISD-R AID，为空时在入队时使用当前设置
+	Reader string
+	AID    string
+
+	State    JobState
+	Queued   time.Time
+	Started  time.Time
+	Finished time.Time
+	Err      error
+
+	cancel context.CancelFunc
+}
+
+// Command 返回命令名，如 "profile download"，不包含 Matching ID 等参数
+func (j *Job) Command() string {
+	return strings.Join(j.Args[:min(len(j.Args), 2)], " ")
+}
+
+// Duration 返回排队中的等待时间，或开始执行后的耗时
+func (j *Job) Duration() time.Duration {
+	switch {
+	case j.State == JobQueued:
+		return time.Since(j.Queued)
+	case j.Started.IsZero():
+		return 0
+	case j.State == JobRunning:
+		return time.Since(j.Started)
+	default:
+		return j.Finished.Sub(j.Started)
+	}
+}
+
+// needsCard 判断命令是否会访问读卡器，不访问的命令不需要排队
+func (j *Job) needsCard() bool {
+	return len(j.Args) > 0 && j.Args[0] != "version" && j.Args[0] != "driver"
+}
+
+// JobQueue 按读卡器串行执行 lpac 命令，同一读卡器同时只运行一个命令
+type JobQueue struct {
+	mu      sync.Mutex
+	readers map[string]chan struct{}
+	active  []*Job
+	history []*Job
+	nextID  int
+	// Changed 在任务状态变化时收到通知，只保留一个待处理的信号
+	Changed chan struct{}
+	// held 是正在进行的多步操作数，期间不能更换读卡器
+	held int
+}
+
+const jobHistoryLimit = 100
+
+// CardJobs 是所有 lpac 命令共用的队列
+var CardJobs = NewJobQueue()
+
+func NewJobQueue() *JobQueue {
+	return &JobQueue{
+		readers: make(map[string]chan struct{}),
+		nextID:  1,
+		Changed: make(chan struct{}, 1),
+	}
+}
+
+// Do 把 job 加入队列，等待轮到它执行并返回 lpac 的结果
+func (q *JobQueue) Do(job *Job) (json.RawMessage, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	slot := q.enqueue(job, cancel)
+	if slot != nil {
+		select {
+		case slot <- struct{}{}:
+			defer func() { <-slot }()
+		case <-ctx.Done():
+			err := &LpacError{Function: job.Command(), Class: ErrorClassCancelled, Key: "cancelled", Err: ErrLpacCancelled}
+			q.finish(job, err)
+			return nil, err
+		}
+	}
+	q.start(job)
+	payload, err := executeLpac(ctx, job)
+	q.finish(job, err)
+	return payload, err
+}
+
+func (q *JobQueue) enqueue(job *Job, cancel context.CancelFunc) chan struct{} {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if job.Reader == "" {
+		job.Reader = ConfigInstance.DriverIFID
+	}
+	if job.AID == "" {
+		job.AID = ConfigInstance.LpacAID
+	}
+	job.ID = q.nextID
+	q.nextID++
+	job.State = JobQueued
+	job.Queued = time.Now()
+	job.cancel = cancel
+	q.active = append(q.active, job)
+	q.notify()
+	q.publish(job)
+	if !job.needsCard() {
+		return nil
+	}
+	slot, ok := q.readers[job.Reader]
+	if !ok {
+		slot = make(chan struct{}, 1)
+		q.readers[job.Reader] = slot
+	}
+	return slot
+}
+
+func (q *JobQueue) start(job *Job) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	job.State = JobRunning
+	job.Started = time.Now()
+	q.notify()
+	q.publish(job)
+}
+
+func (q *JobQueue) finish(job *Job, err error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	job.Finished = time.Now()
+	job.Err = err
+	switch {
+	case err == nil:
+		job.State = JobSucceeded
+	case errors.Is(err, ErrLpacCancelled):
+		job.State = JobCancelled
+	default:
+		job.State = JobFailed
+	}
+	for i, j := range q.active {
+		if j == job {
+			q.active = append(q.active[:i], q.active[i+1:]...)
+			break
+		}
+	}
+	q.history = append(q.history, job)
+	if len(q.history) > jobHistoryLimit {
+		q.history = q.history[len(q.history)-jobHistoryLimit:]
+	}
+	q.notify()
+	q.publish(job)
+}
+
+func (q *JobQueue) notify() {
+	select {
+	case q.Changed <- struct{}{}:
+	default:
+	}
+}
+
+// publish 把任务的状态变化发布到 Events
+func (q *JobQueue) publish(job *Job) {
+	Events.Publish(Event{Type: "job", Job: newJobView(job)})
+}
+
+// Cancel 取消排队中或正在执行的任务
+func (q *JobQueue) Cancel(id int) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for _, job := range q.active {
+		if job.ID == id {
+			job.cancel()
+			return true
+		}
+	}
+	return false
+}
+
+// CancelRunning 取消所有正在执行的任务，排队中的任务随后继续执行
+func (q *JobQueue) CancelRunning() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for _, job := range q.active {
+		if job.State == JobRunning {
+			job.cancel()
+		}
+	}
+}
+
+// Counts 返回正在执行和排队中的任务数
+func (q *JobQueue) Counts() (running int, queued int) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for _, job := range q.active {
+		if job.State == JobRunning {
+			running++
+		} else {
+			queued++
+		}
+	}
+	return running, queued
+}
+
+// Hold 标记一个由多个命令组成的操作开始，返回的函数标记操作结束
+// 两个命令之间队列可能是空的，操作期间 Busy 仍然返回 true，读卡器不会被 API 或定时切换更换
+func (q *JobQueue) Hold() (release func()) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.hold()
+}
+
+// TryHold 只在没有任务和其他操作时开始一个多步操作，用于无人值守的操作
+func (q *JobQueue) TryHold() (release func(), ok bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.held > 0 || len(q.active) > 0 {
+		return nil, false
+	}
+	return q.hold(), true
+}
+
+// hold 在持有锁时调用
+func (q *JobQueue) hold() func() {
+	q.held++
+	q.notify()
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			q.mu.Lock()
+			q.held--
+			q.notify()
+			q.mu.Unlock()
+		})
+	}
+}
+
+// Busy 判断是否有任务在执行或排队，或者有多步操作正在进行
+func (q *JobQueue) Busy() bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.held > 0 || len(q.active) > 0
+}
+
+// Snapshot 返回所有任务的副本，未完成的在前，其余按完成时间从新到旧排列
+func (q *JobQueue) Snapshot() []Job {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	jobs := make([]Job, 0, len(q.active)+len(q.history))
+	for _, job := range q.active {
+		jobs = append(jobs, *job)
+	}
+	for i := len(q.history) - 1; i >= 0; i-- {
+		jobs = append(jobs, *q.history[i])
+	}
+	return jobs
+}
+
+func (q *JobQueue) ClearHistory() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.history = nil
+	q.notify()
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// findJobs 按 ID 从 CardJobs 的快照中取出任务
+func findJobs(ids ...int) []Job {
+	var jobs []Job
+	snapshot := CardJobs.Snapshot()
+	for _, id := range ids {
+		for _, job := range snapshot {
+			if job.ID == id {
+				jobs = append(jobs, job)
+			}
+		}
+	}
+	return jobs
+}
+
+func TestJobQueueSerialisesReader(t *testing.T) {
+	fake := useFakeLpac(t)
+	fake.Delays["chip info"] = 50 * time.Millisecond
+
+	jobs := []*Job{
+		{Args: []string{"chip", "info"}, Reader: "0"},
+		{Args: []string{"chip", "info"}, Reader: "0"},
+		{Args: []string{"chip", "info"}, Reader: "1"},
+	}
+	var wg sync.WaitGroup
+	for _, job := range jobs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := CardJobs.Do(job)
+			assert.NoError(t, err)
+		}()
+	}
+	wg.Wait()
+
+	done := findJobs(jobs[0].ID, jobs[1].ID, jobs[2].ID)
+	require.Len(t, done, 3)
+	first, second := done[0], done[1]
+	if second.Started.Before(first.Started) {
+		first, second = second, first
+	}
+	assert.False(t, second.Started.Before(first.Finished), "jobs on the same reader must not overlap")
+	other := done[2]
+	assert.True(t, other.Started.Before(first.Finished) || other.Started.Before(second.Finished),
+		"jobs on another reader should run in parallel")
+	for _, job := range done {
+		assert.Equal(t, JobSucceeded, job.State)
+	}
+}
+
+func TestJobQueueCancelQueued(t *testing.T) {
+	fake := useFakeLpac(t)
+	fake.Delays["chip info"] = 200 * time.Millisecond
+
+	results := make(chan error, 2)
+	for _, args := range [][]string{{"chip", "info"}, {"profile", "list"}} {
+		go func() {
+			_, err := CardJobs.Do(&Job{Args: args, Reader: "0"})
+			results <- err
+		}()
+		time.Sleep(20 * time.Millisecond)
+	}
+	var queued Job
+	require.Eventually(t, func() bool {
+		for _, job := range CardJobs.Snapshot() {
+			if job.State == JobQueued {
+				queued = job
+				return true
+			}
+		}
+		return false
+	}, time.Second, 10*time.Millisecond)
+	assert.Equal(t, "profile list", queued.Command())
+	assert.True(t, CardJobs.Cancel(queued.ID))
+
+	assert.ErrorIs(t, <-results, ErrLpacCancelled)
+	assert.NoError(t, <-results)
+	assert.Equal(t, JobCancelled, findJobs(queued.ID)[0].State)
+	assert.Equal(t, [][]string{{"chip", "info"}}, fake.Calls, "cancelled job must not reach lpac")
+}
+
+func TestAidUsesJobOverride(t *testing.T) {
+	fake := useFakeLpac(t)
+	fake.AID = AID_5BER
+	origin := ConfigInstance.LpacAID
+
+	assert.False(t, TestAid(AID_DEFAULT))
+	assert.True(t, TestAid(AID_5BER))
+	assert.Equal(t, origin, ConfigInstance.LpacAID, "testing an AID must not change the configured one")
+}
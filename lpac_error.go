@@ -0,0 +1,330 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/fullpipe/icu-mf/mf"
+	"github.com/mattn/go-runewidth"
+)
+
+// LpacErrorClass 是 lpac 错误的大致分类，调用方据此决定是否提供重试
+type LpacErrorClass int
+
+const (
+	ErrorClassUnknown   LpacErrorClass = iota
+	ErrorClassNetwork                  // 无法连接 SM-DP+
+	ErrorClassServer                   // SM-DP+ 拒绝了请求
+	ErrorClassCard                     // eUICC 拒绝了请求
+	ErrorClassReader                   // 读卡器或 PC/SC 错误
+	ErrorClassCancelled                // 用户取消
+	ErrorClassTimeout                  // 超时
+)
+
+func (c LpacErrorClass) String() string {
+	switch c {
+	case ErrorClassNetwork:
+		return "network"
+	case ErrorClassServer:
+		return "server"
+	case ErrorClassCard:
+		return "card"
+	case ErrorClassReader:
+		return "reader"
+	case ErrorClassCancelled:
+		return "cancelled"
+	case ErrorClassTimeout:
+		return "timeout"
+	default:
+		return "unknown"
+	}
+}
+
+// LpacError 是 lpac 执行失败时返回的结构化错误
+type LpacError struct {
+	Function    string // lpac 输出的 message，如 es9p_authenticate_client
+	Data        string // lpac 输出的 data
+	SubjectCode string // SGP.22 subjectCode，如 8.2.6
+	ReasonCode  string // SGP.22 reasonCode，如 3.8
+	Reason      string // ES10 返回的结果，如 profileNotInDisabledState
+	PCSC        string // PC/SC 错误，如 SCARD_E_NO_SMARTCARD
+	Class       LpacErrorClass
+	// Key 是 lpac_error 目录中的条目，为空表示未知错误
+	Key       string
+	Retryable bool
+	Err       error
+}
+
+func (e *LpacError) Error() string {
+	if e.Err != nil {
+		return e.Err.Error()
+	}
+	return fmt.Sprintf("Function: %s\nData: %s", e.Function, wrapText(e.Data, 90))
+}
+
+func (e *LpacError) Unwrap() error {
+	return e.Err
+}
+
+// Interface 返回出错的 SGP.22 接口，如 ES9+、ES10b
+func (e *LpacError) Interface() string {
+	prefix, _, found := strings.Cut(e.Function, "_")
+	if !found || !strings.HasPrefix(prefix, "es") {
+		return ""
+	}
+	// es9p -> ES9+
+	name := strings.ToUpper(prefix[:2]) + prefix[2:]
+	if strings.HasSuffix(name, "p") {
+		name = strings.TrimSuffix(name, "p") + "+"
+	}
+	return name
+}
+
+// Stage 返回出错时所处的阶段
+func (e *LpacError) Stage() ProgressStage {
+	return progressStages[e.Function]
+}
+
+// Explanation 返回翻译后的错误说明和建议，未知错误返回空字符串
+func (e *LpacError) Explanation() (explanation string, remedy string) {
+	if e.Key == "" {
+		return "", ""
+	}
+	args := []mf.TranslationArg{mf.Arg("subject", e.SubjectCode), mf.Arg("reason", e.ReasonCode)}
+	return TR.Trans("lpac_error."+e.Key+".explanation", args...), TR.Trans("lpac_error."+e.Key+".remedy", args...)
+}
+
+type lpacErrorEntry struct {
+	key   string
+	class LpacErrorClass
+	retry bool
+}
+
+// sgp22Catalogue 以 "subjectCode/reasonCode" 或 "subjectCode" 为键
+// ref: SGP.22 v2.2.2 Section 5.6 ES9+ Function Status Codes
+var sgp22Catalogue = map[string]lpacErrorEntry{
+	"8.1.1/3.8":  {"eid_refused", ErrorClassServer, false},
+	"8.1/6.1":    {"invalid_signature", ErrorClassServer, false},
+	"8.2/1.2":    {"profile_not_released", ErrorClassServer, false},
+	"8.2.5/4.3":  {"no_eligible_profile", ErrorClassServer, false},
+	"8.2.6":      {"matching_id_refused", ErrorClassServer, false},
+	"8.2.7/2.2":  {"confirmation_code_required", ErrorClassServer, false},
+	"8.2.7/6.4":  {"retries_exceeded", ErrorClassServer, false},
+	"8.2.7":      {"confirmation_code_refused", ErrorClassServer, false},
+	"8.8.5/6.4":  {"retries_exceeded", ErrorClassServer, false},
+	"8.8.5":      {"order_expired", ErrorClassServer, false},
+	"8.10.1/3.9": {"transaction_unknown", ErrorClassServer, true},
+	"8.11.1/3.9": {"ci_not_supported", ErrorClassServer, false},
+}
+
+// es10Reason 是一个 ES10 结果，reason 为去掉空格和下划线的小写文本
+type es10Reason struct {
+	reason string
+	entry  lpacErrorEntry
+}
+
+// es10Catalogue 按 reason 从长到短排列，一个结果包含另一个时（如 iccidoraidnotfound 和 iccidnotfound）先匹配更长的
+// 添加新的结果时需要保持这个顺序
+var es10Catalogue = []es10Reason{
+	{"installfailedduetoinsufficientmemoryforprofile", lpacErrorEntry{"insufficient_memory", ErrorClassCard, false}},
+	{"installfailedduetoiccidalreadyexistsoneuicc", lpacErrorEntry{"profile_already_exists", ErrorClassCard, false}},
+	{"testprofileinstallfailedduetoinvalidnaakey", lpacErrorEntry{"install_failed", ErrorClassCard, false}},
+	{"installfailedduetopeprocessingerror", lpacErrorEntry{"install_failed", ErrorClassCard, false}},
+	{"installfailedduetoiccidmismatch", lpacErrorEntry{"install_failed", ErrorClassCard, false}},
+	{"installfailedduetointerruption", lpacErrorEntry{"install_interrupted", ErrorClassCard, true}},
+	{"installfailedduetounknownerror", lpacErrorEntry{"install_failed", ErrorClassCard, false}},
+	{"profilenotindisabledstate", lpacErrorEntry{"profile_not_disabled", ErrorClassCard, false}},
+	{"profilenotinenabledstate", lpacErrorEntry{"profile_not_enabled", ErrorClassCard, false}},
+	{"unsupportedprofileclass", lpacErrorEntry{"ppr_not_allowed", ErrorClassCard, false}},
+	{"wrongprofilereenabling", lpacErrorEntry{"disallowed_by_policy", ErrorClassCard, false}},
+	{"iccidoraidnotfound", lpacErrorEntry{"iccid_not_found", ErrorClassCard, false}},
+	{"disallowedbypolicy", lpacErrorEntry{"disallowed_by_policy", ErrorClassCard, false}},
+	{"iccidnotfound", lpacErrorEntry{"iccid_not_found", ErrorClassCard, false}},
+	{"pprnotallowed", lpacErrorEntry{"ppr_not_allowed", ErrorClassCard, false}},
+	{"catbusy", lpacErrorEntry{"cat_busy", ErrorClassCard, true}},
+}
+
+var pcscCatalogue = map[string]lpacErrorEntry{
+	"SCARD_E_NO_SMARTCARD":         {"no_card", ErrorClassReader, true},
+	"SCARD_W_REMOVED_CARD":         {"no_card", ErrorClassReader, true},
+	"SCARD_E_SHARING_VIOLATION":    {"reader_busy", ErrorClassReader, true},
+	"SCARD_E_READER_UNAVAILABLE":   {"reader_unavailable", ErrorClassReader, true},
+	"SCARD_E_UNKNOWN_READER":       {"reader_unavailable", ErrorClassReader, true},
+	"SCARD_E_NO_READERS_AVAILABLE": {"reader_unavailable", ErrorClassReader, true},
+	"SCARD_E_NO_SERVICE":           {"pcsc_service", ErrorClassReader, false},
+	"SCARD_W_UNRESPONSIVE_CARD":    {"card_unresponsive", ErrorClassReader, true},
+	"SCARD_W_UNPOWERED_CARD":       {"card_unresponsive", ErrorClassReader, true},
+	"SCARD_W_RESET_CARD":           {"card_unresponsive", ErrorClassReader, true},
+	"SCARD_E_PROTO_MISMATCH":       {"card_unresponsive", ErrorClassReader, true},
+}
+
+var pcscCodes = map[uint32]string{
+	0x80100002: "SCARD_E_CANCELLED",
+	0x80100009: "SCARD_E_UNKNOWN_READER",
+	0x8010000A: "SCARD_E_TIMEOUT",
+	0x8010000B: "SCARD_E_SHARING_VIOLATION",
+	0x8010000C: "SCARD_E_NO_SMARTCARD",
+	0x8010000F: "SCARD_E_PROTO_MISMATCH",
+	0x80100017: "SCARD_E_READER_UNAVAILABLE",
+	0x8010001D: "SCARD_E_NO_SERVICE",
+	0x8010002E: "SCARD_E_NO_READERS_AVAILABLE",
+	0x80100066: "SCARD_W_UNRESPONSIVE_CARD",
+	0x80100067: "SCARD_W_UNPOWERED_CARD",
+	0x80100068: "SCARD_W_RESET_CARD",
+	0x80100069: "SCARD_W_REMOVED_CARD",
+}
+
+var (
+	pcscNamePattern = regexp.MustCompile(`SCARD_[EWF]_[A-Z_]+`)
+	pcscCodePattern = regexp.MustCompile(`\b(?:0x)?(8010[0-9A-Fa-f]{4})\b`)
+	subjectPattern  = regexp.MustCompile(`subjectCode\W+([0-9]+(?:\.[0-9]+)*)`)
+	reasonPattern   = regexp.MustCompile(`reasonCode\W+([0-9]+(?:\.[0-9]+)*)`)
+)
+
+// newLpacError 根据 lpac 输出的 lpa 失败结果创建 LpacError
+func newLpacError(function, data string) *LpacError {
+	e := &LpacError{Function: function, Data: data}
+	e.SubjectCode = findErrorField(data, "subjectCode", subjectPattern)
+	e.ReasonCode = findErrorField(data, "reasonCode", reasonPattern)
+
+	var entry lpacErrorEntry
+	var ok bool
+	switch {
+	case e.SubjectCode != "":
+		if entry, ok = sgp22Catalogue[e.SubjectCode+"/"+e.ReasonCode]; !ok {
+			entry, ok = sgp22Catalogue[e.SubjectCode]
+		}
+		if !ok {
+			entry, ok = lpacErrorEntry{"smdp_refused", ErrorClassServer, false}, true
+		}
+	case function == "euicc_init":
+		entry, ok = lpacErrorEntry{"euicc_init", ErrorClassCard, true}, true
+	default:
+		normalized := normalizeReason(data)
+		for _, candidate := range es10Catalogue {
+			if strings.Contains(normalized, candidate.reason) {
+				e.Reason = strings.TrimSpace(data)
+				entry, ok = candidate.entry, true
+				break
+			}
+		}
+		if !ok && (strings.HasPrefix(function, "es9p_") || strings.HasPrefix(function, "es11_")) {
+			entry, ok = lpacErrorEntry{"network", ErrorClassNetwork, true}, true
+		}
+	}
+	if ok {
+		e.Key, e.Class, e.Retryable = entry.key, entry.class, entry.retry
+	} else if strings.HasPrefix(function, "es10") {
+		e.Class = ErrorClassCard
+	}
+	return e
+}
+
+// newPCSCError 包装 lpac 在 stderr 中输出的 PC/SC 错误
+func newPCSCError(err error) *LpacError {
+	e := &LpacError{Class: ErrorClassReader, Err: err}
+	if name := pcscNamePattern.FindString(err.Error()); name != "" {
+		e.PCSC = name
+	} else if match := pcscCodePattern.FindStringSubmatch(err.Error()); match != nil {
+		code, _ := strconv.ParseUint(match[1], 16, 32)
+		e.PCSC = pcscCodes[uint32(code)]
+	}
+	if entry, ok := pcscCatalogue[e.PCSC]; ok {
+		e.Key, e.Retryable = entry.key, entry.retry
+	}
+	return e
+}
+
+// findErrorField 在 lpac 输出的 data 中查找字段，data 可能是 JSON 也可能是纯文本
+func findErrorField(data, field string, pattern *regexp.Regexp) string {
+	var parsed any
+	if err := json.Unmarshal([]byte(data), &parsed); err == nil {
+		if value := findJSONField(parsed, field); value != "" {
+			return value
+		}
+	}
+	if match := pattern.FindStringSubmatch(data); match != nil {
+		return match[1]
+	}
+	return ""
+}
+
+func findJSONField(value any, field string) string {
+	switch v := value.(type) {
+	case map[string]any:
+		for key, child := range v {
+			if strings.EqualFold(key, field) {
+				if s, ok := child.(string); ok {
+					return s
+				}
+			}
+			if found := findJSONField(child, field); found != "" {
+				return found
+			}
+		}
+	case []any:
+		for _, child := range v {
+			if found := findJSONField(child, field); found != "" {
+				return found
+			}
+		}
+	}
+	return ""
+}
+
+func normalizeReason(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9':
+			return r
+		case r >= 'A' && r <= 'Z':
+			return r + 'a' - 'A'
+		default:
+			return -1
+		}
+	}, s)
+}
+
+// AsLpacError 是 errors.As 的简单封装
+func AsLpacError(err error) (*LpacError, bool) {
+	var lpacErr *LpacError
+	ok := errors.As(err, &lpacErr)
+	return lpacErr, ok
+}
+
+// IsRetryable 判断错误是否值得重试
+func IsRetryable(err error) bool {
+	if lpacErr, ok := AsLpacError(err); ok {
+		return lpacErr.Retryable
+	}
+	return false
+}
+
+func wrapText(text string, maxWidth int) string {
+	var wrappedText strings.Builder
+	lines := strings.Split(text, "\n")
+	for _, line := range lines {
+		var currentWidth int
+		var currentLine strings.Builder
+		for _, runeValue := range line {
+			// fixme 现在貌似没有必要了
+			// 使用字符宽度而不是长度，让包含 CJK 字符的字符串也能正确限制显示长度
+			runeWidth := runewidth.RuneWidth(runeValue)
+			if currentWidth+runeWidth > maxWidth {
+				wrappedText.WriteString(currentLine.String() + "\n")
+				currentLine.Reset()
+				currentWidth = 0
+			}
+			currentLine.WriteRune(runeValue)
+			currentWidth += runeWidth
+		}
+		if currentLine.Len() > 0 {
+			wrappedText.WriteString(currentLine.String() + "\n")
+		}
+	}
+	return wrappedText.String()
+}
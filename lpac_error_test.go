@@ -0,0 +1,76 @@
+package main
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewLpacError(t *testing.T) {
+	tests := []struct {
+		function  string
+		data      string
+		key       string
+		class     LpacErrorClass
+		retryable bool
+	}{
+		{"es9p_authenticate_client", `{"subjectCode":"8.2.6","reasonCode":"3.8","message":"Refused"}`, "matching_id_refused", ErrorClassServer, false},
+		{"es9p_authenticate_client", `{"header":{"functionExecutionStatus":{"statusCodeData":{"subjectCode":"8.1.1","reasonCode":"3.8"}}}}`, "eid_refused", ErrorClassServer, false},
+		{"es9p_authenticate_client", "subjectCode: 8.8.5, reasonCode: 4.10", "order_expired", ErrorClassServer, false},
+		{"es9p_get_bound_profile_package", `{"subjectCode":"8.9","reasonCode":"5.1"}`, "smdp_refused", ErrorClassServer, false},
+		{"es9p_initiate_authentication", "curl: (6) Could not resolve host", "network", ErrorClassNetwork, true},
+		{"es10b_load_bound_profile_package", "installFailedDueToIccidAlreadyExistsOnEuicc", "profile_already_exists", ErrorClassCard, false},
+		{"es10c_disable_profile", "catBusy", "cat_busy", ErrorClassCard, true},
+		{"es10c_enable_profile", "profile not in disabled state", "profile_not_disabled", ErrorClassCard, false},
+		{"es10c_delete_profile", "something new", "", ErrorClassCard, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			err := newLpacError(tt.function, tt.data)
+			assert.Equal(t, tt.key, err.Key)
+			assert.Equal(t, tt.class, err.Class)
+			assert.Equal(t, tt.retryable, err.Retryable)
+			assert.Contains(t, err.Error(), "Function: "+tt.function)
+		})
+	}
+}
+
+func TestES10CatalogueOrder(t *testing.T) {
+	for i := 1; i < len(es10Catalogue); i++ {
+		assert.GreaterOrEqual(t, len(es10Catalogue[i-1].reason), len(es10Catalogue[i].reason), es10Catalogue[i].reason)
+	}
+}
+
+func TestNewPCSCError(t *testing.T) {
+	err := newPCSCError(errors.New("SCardConnect() failed: 8010000C"))
+	assert.Equal(t, "SCARD_E_NO_SMARTCARD", err.PCSC)
+	assert.Equal(t, "no_card", err.Key)
+	assert.True(t, err.Retryable)
+
+	err = newPCSCError(errors.New("SCardEstablishContext() failed: SCARD_E_NO_SERVICE"))
+	assert.Equal(t, "pcsc_service", err.Key)
+	assert.False(t, err.Retryable)
+}
+
+func TestLpacErrorFromFake(t *testing.T) {
+	fake := useFakeLpac(t)
+	fake.Downloadable["MATCHING-ID"] = &Profile{Iccid: "8988303000000000002"}
+
+	lpacErr, ok := AsLpacError(LpacProfileDownload(PullInfo{MatchID: "WRONG"}))
+	require.True(t, ok)
+	assert.Equal(t, "8.2.6", lpacErr.SubjectCode)
+	assert.Equal(t, "ES9+", lpacErr.Interface())
+	assert.Equal(t, StageAuthenticate, lpacErr.Stage())
+
+	lpacErr, ok = AsLpacError(LpacProfileDownload(PullInfo{MatchID: "MATCHING-ID"}))
+	require.True(t, ok)
+	assert.Equal(t, "profile_already_exists", lpacErr.Key)
+	assert.Equal(t, "ES10b", lpacErr.Interface())
+	assert.False(t, IsRetryable(lpacErr))
+
+	fake.Fail("chip info", &FakeFailure{Err: errors.New("SCardConnect() failed: 8010000B")})
+	_, err := LpacChipInfo()
+	assert.True(t, IsRetryable(err))
+}
@@ -1,9 +1,14 @@
 package main
 
 import (
+	"errors"
+	"flag"
 	"fmt"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"strings"
+	"syscall"
 
 	"fyne.io/fyne/v2"
 	"fyne.io/fyne/v2/app"
@@ -18,17 +23,15 @@ var App fyne.App
 func init() {
 	InitCiRegistry()
 	InitEumRegistry()
+	InitIinRegistry()
 	
-	// 先加载配置（因为InitI18n需要读取ConfigInstance.Language）
+	// 先加载默认配置，配置文件和启动参数在 main 中读取
 	if err := LoadConfig(); err != nil {
 		panic(err)
 	}
 	
 	// 然后初始化i18n（会读取ConfigInstance.Language）
 	InitI18n()
-	
-	App = app.New()
-	App.Settings().SetTheme(&MyTheme{})
 
 	if _, err := os.Stat(ConfigInstance.LogDir); os.IsNotExist(err) {
 		err := os.Mkdir(ConfigInstance.LogDir, 0755)
@@ -45,16 +48,39 @@ func init() {
 }
 
 func main() {
-	var err error
+	err := LoadConfigFile()
+	if errors.Is(err, flag.ErrHelp) {
+		os.Exit(0)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(ExitUsage)
+	}
+	// 按配置文件中的语言重新加载翻译
+	InitI18n()
 	ConfigInstance.LogFile, err = os.Create(filepath.Join(ConfigInstance.LogDir, ConfigInstance.LogFilename))
 	if err != nil {
 		panic(err)
 	}
 	defer ConfigInstance.LogFile.Close()
 
+	// easylpac cli ... 以命令行模式运行，easylpac serve 只运行 API 服务，都不创建窗口
+	if len(ConfigInstance.Args) > 0 {
+		switch ConfigInstance.Args[0] {
+		case "cli":
+			os.Exit(runCLIMain(ConfigInstance.Args[1:]))
+		case "serve":
+			os.Exit(runServeMain(ConfigInstance.Args[1:]))
+		}
+	}
+
+	App = app.New()
+	App.Settings().SetTheme(&MyTheme{})
+
+	InitNotificationRetries()
+	InitNotificationLedger()
 	InitWidgets()
 	go UpdateStatusBarListener()
-	go LockButtonListener()
 
 	WMain = InitMainWindow()
 
@@ -73,10 +99,95 @@ func main() {
 		}
 		RefreshApduDriver()
 		if ApduDrivers != nil {
-			ApduDriverSelect.SetSelectedIndex(0)
+			SelectRememberedReader()
 		}
 	}
+	if ConfigInstance.APIEnabled {
+		err = StartAPIServer()
+		UpdateAPIStatus(err)
+		if err != nil {
+			dialog.ShowError(fmt.Errorf("%s %v", TR.Trans("message.api_server_failed"), err), WMain)
+		}
+	}
+	StartNotificationRetries()
+	if len(ConfigInstance.ConfigWarnings) > 0 {
+		dialog.ShowInformation(TR.Trans("dialog.info"),
+			TR.Trans("message.config_warnings")+"\n"+strings.Join(ConfigInstance.ConfigWarnings, "\n"), WMain)
+	}
+
+	StartScheduler()
 
 	WMain.Show()
 	App.Run()
+	StopScheduler()
+	NotificationRetries.Stop()
+	StopAPIServer()
+}
+
+// checkHeadless 输出配置警告并检查 lpac 是否存在
+func checkHeadless() bool {
+	for _, warning := range ConfigInstance.ConfigWarnings {
+		fmt.Fprintln(os.Stderr, "warning:", warning)
+	}
+	if _, err := os.Stat(filepath.Join(ConfigInstance.LpacDir, ConfigInstance.EXEName)); err != nil {
+		fmt.Fprintln(os.Stderr, TR.Trans("message.lpac_not_found"))
+		return false
+	}
+	return true
+}
+
+func runCLIMain(args []string) int {
+	defer ConfigInstance.LogFile.Close()
+	InitNotificationLedger()
+	if !checkHeadless() {
+		return ExitLpacNotFound
+	}
+	return RunCLI(args, os.Stdout, os.Stderr)
+}
+
+// runServeMain 以守护进程方式运行 API 服务、定时切换和通知重试，收到 SIGINT 或 SIGTERM 后退出
+func runServeMain(args []string) int {
+	defer ConfigInstance.LogFile.Close()
+	if len(args) > 0 {
+		fmt.Fprintln(os.Stderr, "Usage: EasyLPAC [-api-listen address] [-api-token token] serve")
+		return ExitUsage
+	}
+	InitNotificationRetries()
+	InitNotificationLedger()
+	if !checkHeadless() {
+		return ExitLpacNotFound
+	}
+	if ConfigInstance.APIToken == "" {
+		ConfigInstance.APIToken = NewAPIToken()
+		ConfigChanged()
+		fmt.Fprintln(os.Stderr, "API token:", ConfigInstance.APIToken)
+	}
+	server := NewAPIServer(ConfigInstance.APIToken)
+	if d, err := FindReader(""); err != nil {
+		fmt.Fprintln(os.Stderr, "warning:", err)
+	} else {
+		server.SelectReader(d)
+	}
+	if err := server.Start(ConfigInstance.APIListen); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return ExitFailure
+	}
+	fmt.Fprintln(os.Stderr, "Listening on", server.Addr())
+	scheduler := NewScheduler(ConfigInstance.Schedules)
+	scheduler.Start()
+	NotificationRetries.Start()
+	if len(ConfigInstance.Schedules) > 0 {
+		fmt.Fprintln(os.Stderr, "Schedules:", len(ConfigInstance.Schedules))
+	}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	<-signals
+	scheduler.Stop()
+	NotificationRetries.Stop()
+	CardJobs.CancelRunning()
+	if err := server.Close(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+	}
+	return ExitOK
 }
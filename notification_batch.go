@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// NotificationBatchItem 是批量处理中的一个通知
+type NotificationBatchItem struct {
+	Notification *Notification
+	Action       NotificationAction
+	Status       BatchStatus
+	Err          error
+}
+
+// NotificationBatch 依次处理多个通知并记录每个通知的结果
+// 取消或读卡器出错后剩下的通知保持等待中，失败的通知可以单独重新处理
+type NotificationBatch struct {
+	mu    sync.Mutex
+	items []*NotificationBatchItem
+}
+
+// NewNotificationBatch 用 action 决定每个通知的处理方式
+func NewNotificationBatch(notifications []*Notification, action func(n *Notification) NotificationAction) *NotificationBatch {
+	b := &NotificationBatch{}
+	for _, n := range notifications {
+		b.items = append(b.items, &NotificationBatchItem{Notification: n, Action: action(n)})
+	}
+	return b
+}
+
+// Items 返回各通知当前状态的副本，可以在 Run 执行时调用
+func (b *NotificationBatch) Items() []NotificationBatchItem {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	items := make([]NotificationBatchItem, len(b.items))
+	for i, item := range b.items {
+		items[i] = *item
+	}
+	return items
+}
+
+// Counts 返回各状态的通知数
+func (b *NotificationBatch) Counts() map[BatchStatus]int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	counts := make(map[BatchStatus]int)
+	for _, item := range b.items {
+		counts[item.Status]++
+	}
+	return counts
+}
+
+// Requeue 把处理失败的通知重新标记为等待中
+func (b *NotificationBatch) Requeue(seq int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, item := range b.items {
+		if item.Notification.SeqNumber == seq && item.Status == BatchFailed {
+			item.Status = BatchPending
+		}
+	}
+}
+
+// Run 依次处理等待中的通知，返回是否处理了所有等待中的通知
+// ctx 取消后不再开始新的通知，读卡器错误或取消时停止，onUpdate 在每个通知状态变化后调用
+func (b *NotificationBatch) Run(ctx context.Context, onUpdate func()) bool {
+	defer CardJobs.Hold()()
+	if onUpdate == nil {
+		onUpdate = func() {}
+	}
+	for _, item := range b.items {
+		if ctx.Err() != nil {
+			return false
+		}
+		b.mu.Lock()
+		runnable := item.Status == BatchPending
+		if runnable {
+			item.Status, item.Err = BatchRunning, nil
+		}
+		b.mu.Unlock()
+		if !runnable {
+			continue
+		}
+		onUpdate()
+
+		outcome := ApplyNotificationAction(item.Notification, item.Action)
+		b.mu.Lock()
+		item.Err = outcome.Err
+		item.Status = BatchSucceeded
+		if outcome.Err != nil {
+			item.Status = BatchFailed
+		}
+		b.mu.Unlock()
+		onUpdate()
+		if lpacErr, ok := AsLpacError(outcome.Err); ok &&
+			(lpacErr.Class == ErrorClassReader || lpacErr.Class == ErrorClassCancelled) {
+			return false
+		}
+	}
+	return true
+}
+
+// Report 以制表符分隔的文本返回每个通知的结果，用于复制
+func (b *NotificationBatch) Report() string {
+	var sb strings.Builder
+	sb.WriteString("seq\ticcid\toperation\taddress\taction\tstatus\terror\n")
+	for _, item := range b.Items() {
+		var errText string
+		if item.Err != nil {
+			errText = strings.Join(strings.Fields(item.Err.Error()), " ")
+		}
+		n := item.Notification
+		fmt.Fprintf(&sb, "%d\t%s\t%s\t%s\t%s\t%s\t%s\n", n.SeqNumber, n.Iccid, n.ProfileManagementOperation,
+			n.NotificationAddress, item.Action, item.Status, errText)
+	}
+	return sb.String()
+}
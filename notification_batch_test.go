@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// useNotificationBatch 切换一次 Profile，为两个新通知创建批量处理
+func useNotificationBatch(t *testing.T, action NotificationAction) (*FakeLpac, *NotificationBatch) {
+	fake := useFakeLpac(t)
+	require.NoError(t, LpacProfileEnable("8988303000000000010"))
+	notifications, err := LpacNotificationList()
+	require.NoError(t, err)
+	require.Len(t, notifications, 2)
+	return fake, NewNotificationBatch(notifications, func(*Notification) NotificationAction { return action })
+}
+
+func TestNotificationBatchRetry(t *testing.T) {
+	fake, batch := useNotificationBatch(t, ActionSendRemove)
+	fake.Fail("notification process", &FakeFailure{Function: "es9p_handle_notification", Data: "curl: (6) Could not resolve host"})
+	assert.True(t, batch.Run(context.Background(), nil))
+	assert.Equal(t, 2, batch.Counts()[BatchFailed])
+	assert.Contains(t, batch.Report(), "\tsend_remove\tfailed\t")
+	assert.Len(t, fake.Notifications, 2)
+
+	fake.ClearFailures()
+	seq := batch.Items()[1].Notification.SeqNumber
+	batch.Requeue(seq)
+	assert.True(t, batch.Run(context.Background(), nil))
+	items := batch.Items()
+	assert.Equal(t, BatchFailed, items[0].Status, "only requeued notifications are retried")
+	assert.Equal(t, BatchSucceeded, items[1].Status)
+	assert.NoError(t, items[1].Err)
+	require.Len(t, fake.Notifications, 1)
+	assert.NotEqual(t, seq, fake.Notifications[0].SeqNumber)
+}
+
+func TestNotificationBatchCancel(t *testing.T) {
+	fake, batch := useNotificationBatch(t, ActionRemove)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	assert.False(t, batch.Run(ctx, func() {
+		if batch.Counts()[BatchSucceeded] == 1 {
+			cancel()
+		}
+	}))
+	counts := batch.Counts()
+	assert.Equal(t, 1, counts[BatchSucceeded])
+	assert.Equal(t, 1, counts[BatchPending])
+	assert.Len(t, fake.Notifications, 1)
+
+	// 读卡器错误后不再处理剩下的通知
+	_, batch = useNotificationBatch(t, ActionRemove)
+	fake = Backend.(*FakeLpac)
+	fake.RemoveCard()
+	assert.False(t, batch.Run(context.Background(), nil))
+	counts = batch.Counts()
+	assert.Equal(t, 1, counts[BatchFailed])
+	assert.Equal(t, 1, counts[BatchPending])
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// NotificationGrouping 是通知列表的分组方式
+type NotificationGrouping string
+
+const (
+	GroupNone    NotificationGrouping = ""
+	GroupAddress NotificationGrouping = "address"
+	GroupIccid   NotificationGrouping = "iccid"
+)
+
+var NotificationGroupings = []NotificationGrouping{GroupNone, GroupAddress, GroupIccid}
+
+// NotificationGroup 是地址或 ICCID 相同的通知
+type NotificationGroup struct {
+	Key           string
+	Notifications []*Notification
+}
+
+// Addresses 返回组内通知的所有 SM-DP+ 地址
+func (g NotificationGroup) Addresses() []string {
+	var addresses []string
+	for _, n := range g.Notifications {
+		if !slices.ContainsFunc(addresses, func(a string) bool { return strings.EqualFold(a, n.NotificationAddress) }) {
+			addresses = append(addresses, n.NotificationAddress)
+		}
+	}
+	return addresses
+}
+
+// GroupNotifications 按 by 分组，组按 Key 排序，组内保持原来的顺序
+// 地址不区分大小写，Key 使用第一个通知中的写法
+func GroupNotifications(notifications []*Notification, by NotificationGrouping) []NotificationGroup {
+	var groups []NotificationGroup
+	index := make(map[string]int)
+	for _, n := range notifications {
+		key := n.Iccid
+		if by == GroupAddress {
+			key = n.NotificationAddress
+		}
+		i, ok := index[strings.ToLower(key)]
+		if !ok {
+			i = len(groups)
+			index[strings.ToLower(key)] = i
+			groups = append(groups, NotificationGroup{Key: key})
+		}
+		groups[i].Notifications = append(groups[i].Notifications, n)
+	}
+	slices.SortStableFunc(groups, func(a, b NotificationGroup) int {
+		return strings.Compare(strings.ToLower(a.Key), strings.ToLower(b.Key))
+	})
+	return groups
+}
+
+// ServerState 是根据最近的发送结果判断的 SM-DP+ 状态
+type ServerState int
+
+const (
+	ServerUnknown ServerState = iota
+	ServerHealthy
+	// ServerFailing 表示最近一次发送失败
+	ServerFailing
+	// ServerDown 表示连续失败了 serverDownAfter 次
+	ServerDown
+)
+
+func (s ServerState) String() string {
+	switch s {
+	case ServerHealthy:
+		return "healthy"
+	case ServerFailing:
+		return "failing"
+	case ServerDown:
+		return "down"
+	default:
+		return "unknown"
+	}
+}
+
+const (
+	serverDownAfter = 3
+	// serverHealthWindow 之前的发送结果不再参考
+	serverHealthWindow = 24 * time.Hour
+	serverMaxAttempts  = 10
+)
+
+// ServerHealth 是一个 SM-DP+ 最近的发送情况
+type ServerHealth struct {
+	State ServerState
+	// Failures 是最近连续失败的次数
+	Failures    int
+	LastAttempt time.Time
+	LastError   string
+}
+
+type sendAttempt struct {
+	time time.Time
+	err  string
+}
+
+// ServerHealthTracker 在内存中记录每个 SM-DP+ 最近的发送结果
+type ServerHealthTracker struct {
+	mu       sync.Mutex
+	attempts map[string][]sendAttempt
+	now      func() time.Time
+}
+
+// ServerHealths 记录本次运行中所有通知的发送结果
+var ServerHealths = NewServerHealthTracker()
+
+func NewServerHealthTracker() *ServerHealthTracker {
+	return &ServerHealthTracker{attempts: make(map[string][]sendAttempt), now: time.Now}
+}
+
+// Record 记录一次发送，卡片、读卡器错误和取消与服务器无关，不记录
+func (t *ServerHealthTracker) Record(address string, err error) {
+	if lpacErr, ok := AsLpacError(err); ok {
+		switch lpacErr.Class {
+		case ErrorClassCard, ErrorClassReader, ErrorClassCancelled:
+			return
+		}
+	}
+	attempt := sendAttempt{time: t.now()}
+	if err != nil {
+		attempt.err = strings.TrimSpace(err.Error())
+	}
+	key := strings.ToLower(address)
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	attempts := append(t.attempts[key], attempt)
+	t.attempts[key] = attempts[max(len(attempts)-serverMaxAttempts, 0):]
+}
+
+// Health 返回 address 最近的发送情况
+func (t *ServerHealthTracker) Health(address string) ServerHealth {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var health ServerHealth
+	since := t.now().Add(-serverHealthWindow)
+	attempts := t.attempts[strings.ToLower(address)]
+	for i := len(attempts) - 1; i >= 0 && attempts[i].time.After(since); i-- {
+		if health.LastAttempt.IsZero() {
+			health.LastAttempt, health.LastError = attempts[i].time, attempts[i].err
+		}
+		if attempts[i].err == "" {
+			break
+		}
+		health.Failures++
+	}
+	switch {
+	case health.LastAttempt.IsZero():
+		health.State = ServerUnknown
+	case health.Failures >= serverDownAfter:
+		health.State = ServerDown
+	case health.Failures > 0:
+		health.State = ServerFailing
+	default:
+		health.State = ServerHealthy
+	}
+	return health
+}
+
+// GroupHealth 返回组内所有地址中最差的状态
+func (t *ServerHealthTracker) GroupHealth(g NotificationGroup) ServerHealth {
+	var worst ServerHealth
+	for _, address := range g.Addresses() {
+		if health := t.Health(address); health.State > worst.State {
+			worst = health
+		}
+	}
+	return worst
+}
+
+// sendNotification 发送通知并记录 SM-DP+ 的状态
+func sendNotification(n *Notification, remove bool) error {
+	err := LpacNotificationProcess(n.SeqNumber, remove)
+	ServerHealths.Record(n.NotificationAddress, err)
+	return err
+}
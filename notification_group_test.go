@@ -0,0 +1,77 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGroupNotifications(t *testing.T) {
+	notifications := []*Notification{
+		{SeqNumber: 1, Iccid: "8988303000000000010", NotificationAddress: "smdp.example.org"},
+		{SeqNumber: 2, Iccid: "8988303000000000002", NotificationAddress: "SMDP.example.com"},
+		{SeqNumber: 3, Iccid: "8988303000000000010", NotificationAddress: "smdp.example.com"},
+		{SeqNumber: 4, NotificationAddress: "smdp.example.org"},
+	}
+	groups := GroupNotifications(notifications, GroupAddress)
+	require.Len(t, groups, 2)
+	assert.Equal(t, "SMDP.example.com", groups[0].Key)
+	assert.Len(t, groups[0].Notifications, 2)
+	assert.Equal(t, []string{"SMDP.example.com"}, groups[0].Addresses())
+	assert.Equal(t, 1, groups[1].Notifications[0].SeqNumber)
+
+	groups = GroupNotifications(notifications, GroupIccid)
+	require.Len(t, groups, 3)
+	assert.Equal(t, "", groups[0].Key)
+	assert.Equal(t, "8988303000000000010", groups[2].Key)
+	assert.Equal(t, []string{"smdp.example.org", "smdp.example.com"}, groups[2].Addresses())
+}
+
+func TestServerHealth(t *testing.T) {
+	now := time.Date(2026, 3, 2, 8, 0, 0, 0, time.UTC)
+	tracker := NewServerHealthTracker()
+	tracker.now = func() time.Time { return now }
+	network := newLpacError("es9p_handle_notification", "curl: (6) Could not resolve host")
+
+	assert.Equal(t, ServerUnknown, tracker.Health("smdp.example.com").State)
+	tracker.Record("smdp.example.com", nil)
+	assert.Equal(t, ServerHealthy, tracker.Health("SMDP.example.com").State)
+	tracker.Record("smdp.example.com", network)
+	tracker.Record("smdp.example.com", newPCSCError(errors.New("SCardConnect() failed: 8010000C")))
+	health := tracker.Health("smdp.example.com")
+	assert.Equal(t, ServerFailing, health.State, "reader errors are not the server's fault")
+	assert.Equal(t, 1, health.Failures)
+	tracker.Record("smdp.example.com", network)
+	tracker.Record("smdp.example.com", network)
+	assert.Equal(t, ServerDown, tracker.Health("smdp.example.com").State)
+
+	tracker.Record("smdp.example.org", nil)
+	group := NotificationGroup{Notifications: []*Notification{
+		{NotificationAddress: "smdp.example.org"}, {NotificationAddress: "smdp.example.com"},
+	}}
+	assert.Equal(t, ServerDown, tracker.GroupHealth(group).State)
+
+	now = now.Add(serverHealthWindow + time.Minute)
+	assert.Equal(t, ServerUnknown, tracker.Health("smdp.example.com").State, "old attempts expire")
+}
+
+func TestSendNotificationRecordsHealth(t *testing.T) {
+	fake := useFakeLpac(t)
+	origin := ServerHealths
+	ServerHealths = NewServerHealthTracker()
+	t.Cleanup(func() { ServerHealths = origin })
+	require.NoError(t, LpacProfileEnable("8988303000000000010"))
+	notifications, err := LpacNotificationList()
+	require.NoError(t, err)
+
+	fake.Fail("notification process", &FakeFailure{Function: "es9p_handle_notification", Data: "curl: (6) Could not resolve host"})
+	outcome := ApplyNotificationAction(notifications[0], ActionSendKeep)
+	require.Error(t, outcome.Err)
+	assert.Equal(t, ServerFailing, ServerHealths.Health(notifications[0].NotificationAddress).State)
+	fake.ClearFailures()
+	require.NoError(t, ApplyNotificationAction(notifications[0], ActionSendKeep).Err)
+	assert.Equal(t, ServerHealthy, ServerHealths.Health(notifications[0].NotificationAddress).State)
+}
@@ -0,0 +1,300 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+const notificationLedgerFilename = "notification-seen.json"
+
+// NotificationOrigin 是产生通知的操作
+type NotificationOrigin string
+
+const (
+	OriginDownload NotificationOrigin = "download"
+	OriginDelete   NotificationOrigin = "delete"
+	OriginEnable   NotificationOrigin = "enable"
+	OriginDisable  NotificationOrigin = "disable"
+	// OriginExternal 表示通知不是由 EasyLPAC 的操作产生的，或者在开始记录之前已经存在
+	OriginExternal NotificationOrigin = "external"
+)
+
+// originOperations 是每种操作会产生的通知类型，启用 Profile 时原来的 Profile 会产生 disable 通知
+var originOperations = map[NotificationOrigin][]string{
+	OriginDownload: {"install"},
+	OriginDelete:   {"delete"},
+	OriginEnable:   {"enable", "disable"},
+	OriginDisable:  {"disable"},
+}
+
+// originWindow 之后才看到的通知不再归属于之前的操作
+const originWindow = 10 * time.Minute
+
+// DefaultNotificationWarnAfter 是默认的未发送通知提醒时间
+const DefaultNotificationWarnAfter = 72 * time.Hour
+
+// SeenNotification 记录一个通知第一次被看到的时间和来源，以 EID 和 seqNumber 区分
+type SeenNotification struct {
+	EID       string             `json:"eid"`
+	SeqNumber int                `json:"seq_number"`
+	Iccid     string             `json:"iccid,omitempty"`
+	Operation string             `json:"operation"`
+	Address   string             `json:"address"`
+	FirstSeen time.Time          `json:"first_seen"`
+	Origin    NotificationOrigin `json:"origin"`
+}
+
+// Age 返回通知已经存在的时间，来源为 external 时是最少存在的时间
+func (s *SeenNotification) Age(now time.Time) time.Duration {
+	return now.Sub(s.FirstSeen)
+}
+
+// expectedOrigin 是刚执行的操作预计会在卡片 eid 上产生的通知
+type expectedOrigin struct {
+	origin NotificationOrigin
+	eid    string
+	// iccid 为空时不检查 ICCID，例如下载前不知道新 Profile 的 ICCID
+	iccid string
+	// operations 是还没有看到的通知类型，全部看到后不再归属新的通知
+	operations []string
+	time       time.Time
+}
+
+// matches 判断通知 n 是否可能由这个操作产生
+func (e *expectedOrigin) matches(eid string, n *Notification) bool {
+	if e.eid != eid || !slices.Contains(e.operations, n.ProfileManagementOperation) {
+		return false
+	}
+	// 启用 Profile 时，原来启用的 Profile 产生的 disable 通知 ICCID 不同
+	if e.origin == OriginEnable && n.ProfileManagementOperation == "disable" {
+		return true
+	}
+	return e.iccid == "" || n.Iccid == "" || e.iccid == n.Iccid
+}
+
+// NotificationLedger 保存在配置目录中，记录每张卡片上仍未处理的通知
+// Cards 记录每张卡片已经看到的最大序号，序号更大的新通知才会归属于刚执行的操作
+type NotificationLedger struct {
+	mu       sync.Mutex
+	path     string // 为空时只保存在内存中
+	cards    map[string]int
+	records  []*SeenNotification
+	expected []*expectedOrigin
+	now      func() time.Time
+}
+
+type notificationLedgerFile struct {
+	Cards         map[string]int      `json:"cards"`
+	Notifications []*SeenNotification `json:"notifications"`
+}
+
+// NotificationsSeen 是配置目录中的通知记录，为 nil 时不记录，例如在测试中
+var NotificationsSeen *NotificationLedger
+
+// LoadNotificationLedger 读取 path 中的记录，文件不存在时返回空记录
+// 文件无法解析时返回只保存在内存中的空记录和错误，不覆盖原文件
+func LoadNotificationLedger(path string) (*NotificationLedger, error) {
+	l := &NotificationLedger{path: path, cards: make(map[string]int), now: time.Now}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return l, nil
+	}
+	var file notificationLedgerFile
+	if err == nil {
+		err = json.Unmarshal(data, &file)
+	}
+	if err != nil {
+		l.path = ""
+		return l, fmt.Errorf("%s: %w", path, err)
+	}
+	for eid, seq := range file.Cards {
+		l.cards[strings.ToUpper(eid)] = seq
+	}
+	l.records = file.Notifications
+	return l, nil
+}
+
+// InitNotificationLedger 加载配置目录中的通知记录
+func InitNotificationLedger() {
+	l, err := LoadNotificationLedger(filepath.Join(filepath.Dir(ConfigInstance.ConfigPath), notificationLedgerFilename))
+	if err != nil {
+		ConfigInstance.ConfigWarnings = append(ConfigInstance.ConfigWarnings, err.Error())
+	}
+	NotificationsSeen = l
+}
+
+// save 在持有锁时调用
+func (l *NotificationLedger) save() {
+	if l.path == "" {
+		return
+	}
+	data, err := json.MarshalIndent(notificationLedgerFile{Cards: l.cards, Notifications: l.records}, "", "  ")
+	if err == nil {
+		err = os.MkdirAll(filepath.Dir(l.path), 0755)
+	}
+	if err == nil {
+		tmp := l.path + ".tmp"
+		if err = os.WriteFile(tmp, append(data, '\n'), 0600); err == nil {
+			err = os.Rename(tmp, l.path)
+		}
+	}
+	if err != nil && ConfigInstance.LogFile != nil {
+		fmt.Fprintln(ConfigInstance.LogFile, "notification ledger:", err)
+	}
+}
+
+// Expect 在 EasyLPAC 对卡片 eid 上的 Profile iccid 的操作成功后调用
+// 之后在这张卡片上看到的对应类型的新通知归属于这个操作，每种类型只归属一个通知
+func (l *NotificationLedger) Expect(eid string, origin NotificationOrigin, iccid string) {
+	if l == nil || eid == "" {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.expected = append(l.expected, &expectedOrigin{
+		origin:     origin,
+		eid:        strings.ToUpper(eid),
+		iccid:      iccid,
+		operations: slices.Clone(originOperations[origin]),
+		time:       l.now(),
+	})
+}
+
+// Known 判断是否已经看到过卡片 eid 上的通知
+func (l *NotificationLedger) Known(eid string) bool {
+	if l == nil {
+		return false
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	_, ok := l.cards[strings.ToUpper(eid)]
+	return ok
+}
+
+// origin 返回最近一个可能产生通知 n 的操作并把这个通知从操作中移除，在持有锁时调用
+func (l *NotificationLedger) origin(eid string, n *Notification) NotificationOrigin {
+	for i := len(l.expected) - 1; i >= 0; i-- {
+		e := l.expected[i]
+		if !e.matches(eid, n) {
+			continue
+		}
+		e.operations = slices.DeleteFunc(e.operations, func(operation string) bool { return operation == n.ProfileManagementOperation })
+		if len(e.operations) == 0 {
+			l.expected = slices.Delete(l.expected, i, i+1)
+		}
+		return e.origin
+	}
+	return OriginExternal
+}
+
+// Observe 记录卡片 eid 上新出现的通知，并删除已经不在卡片上的通知
+func (l *NotificationLedger) Observe(eid string, notifications []*Notification) {
+	if l == nil || eid == "" {
+		return
+	}
+	eid = strings.ToUpper(eid)
+	now := l.now()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.expected = slices.DeleteFunc(l.expected, func(e *expectedOrigin) bool { return now.Sub(e.time) > originWindow })
+
+	lastSeq, known := l.cards[eid]
+	changed := !known
+	maxSeq := lastSeq
+	for _, n := range notifications {
+		maxSeq = max(maxSeq, n.SeqNumber)
+		if slices.ContainsFunc(l.records, func(r *SeenNotification) bool { return r.EID == eid && r.SeqNumber == n.SeqNumber }) {
+			continue
+		}
+		origin := OriginExternal
+		// 第一次看到这张卡片时已经存在的通知，来源未知
+		if known && n.SeqNumber > lastSeq {
+			origin = l.origin(eid, n)
+		}
+		l.records = append(l.records, &SeenNotification{
+			EID:       eid,
+			SeqNumber: n.SeqNumber,
+			Iccid:     n.Iccid,
+			Operation: n.ProfileManagementOperation,
+			Address:   n.NotificationAddress,
+			FirstSeen: now,
+			Origin:    origin,
+		})
+		changed = true
+	}
+	l.cards[eid] = maxSeq
+	before := len(l.records)
+	l.records = slices.DeleteFunc(l.records, func(r *SeenNotification) bool {
+		return r.EID == eid && !slices.ContainsFunc(notifications, func(n *Notification) bool { return n.SeqNumber == r.SeqNumber })
+	})
+	if changed || len(l.records) != before {
+		l.save()
+	}
+}
+
+// expectNotifications 在会产生通知的操作之前读取执行操作的卡片，返回的函数在操作成功后调用
+// 卡片上新出现的通知立即记录为由这个操作产生，命令行和 serve 模式下没有界面刷新也能归属
+func expectNotifications(origin NotificationOrigin, iccid string) (succeeded func()) {
+	if NotificationsSeen == nil {
+		return func() {}
+	}
+	info, err := LpacChipInfo()
+	if err != nil {
+		return func() {}
+	}
+	observe := func() {
+		if notifications, err := LpacNotificationList(); err == nil {
+			NotificationsSeen.Observe(info.EidValue, notifications)
+		}
+	}
+	// 第一次看到的卡片先记录已经存在的通知，之后的新通知才会归属于这个操作
+	if !NotificationsSeen.Known(info.EidValue) {
+		observe()
+	}
+	return func() {
+		NotificationsSeen.Expect(info.EidValue, origin, iccid)
+		observe()
+	}
+}
+
+// Find 查找卡片 eid 上序号为 seq 的通知的记录
+func (l *NotificationLedger) Find(eid string, seq int) (SeenNotification, bool) {
+	if l == nil {
+		return SeenNotification{}, false
+	}
+	eid = strings.ToUpper(eid)
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, r := range l.records {
+		if r.EID == eid && r.SeqNumber == seq {
+			return *r, true
+		}
+	}
+	return SeenNotification{}, false
+}
+
+// Overdue 返回卡片 eid 上存在超过 after 的通知，eid 为空时返回所有卡片的，after 为 0 时不提醒
+func (l *NotificationLedger) Overdue(eid string, after time.Duration) []SeenNotification {
+	if l == nil || after <= 0 {
+		return nil
+	}
+	eid = strings.ToUpper(eid)
+	now := l.now()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	var overdue []SeenNotification
+	for _, r := range l.records {
+		if (eid == "" || r.EID == eid) && r.Age(now) >= after {
+			overdue = append(overdue, *r)
+		}
+	}
+	slices.SortStableFunc(overdue, func(a, b SeenNotification) int { return a.FirstSeen.Compare(b.FirstSeen) })
+	return overdue
+}
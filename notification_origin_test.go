@@ -0,0 +1,152 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// useNotificationLedger 在测试中启用保存在临时目录的通知记录，时间由返回的指针控制
+func useNotificationLedger(t *testing.T) (*NotificationLedger, *time.Time) {
+	path := filepath.Join(t.TempDir(), notificationLedgerFilename)
+	l, err := LoadNotificationLedger(path)
+	require.NoError(t, err)
+	now := time.Date(2026, 10, 18, 8, 0, 0, 0, time.UTC)
+	l.now = func() time.Time { return now }
+	NotificationsSeen = l
+	t.Cleanup(func() {
+		NotificationsSeen = nil
+	})
+	return l, &now
+}
+
+func TestNotificationLedgerOrigin(t *testing.T) {
+	fake := useFakeLpac(t)
+	l, now := useNotificationLedger(t)
+	eid := fake.Chip.EidValue
+
+	// 第一次看到卡片时已经存在的通知来源未知
+	fake.addNotification("install", "8988303000000000002")
+	l.Observe(eid, fake.Notifications)
+	seen, ok := l.Find(eid, fake.Notifications[0].SeqNumber)
+	require.True(t, ok)
+	assert.Equal(t, OriginExternal, seen.Origin)
+
+	// 启用 Profile 产生的 enable 和 disable 通知都归属于启用操作，不需要界面刷新
+	*now = now.Add(time.Minute)
+	require.NoError(t, LpacProfileEnable("8988303000000000010"))
+	require.Len(t, fake.Notifications, 3)
+	for _, n := range fake.Notifications[1:] {
+		seen, ok = l.Find(eid, n.SeqNumber)
+		require.True(t, ok)
+		assert.Equal(t, OriginEnable, seen.Origin, n.ProfileManagementOperation)
+		assert.Equal(t, *now, seen.FirstSeen)
+	}
+
+	// 超过 originWindow 之后出现的通知不再归属于之前的操作
+	*now = now.Add(originWindow + time.Minute)
+	fake.addNotification("disable", "8988303000000000010")
+	l.Observe(eid, fake.Notifications)
+	seen, ok = l.Find(eid, fake.Notifications[3].SeqNumber)
+	require.True(t, ok)
+	assert.Equal(t, OriginExternal, seen.Origin)
+
+	// 已发送的通知从记录中删除
+	require.NoError(t, LpacNotificationProcess(fake.Notifications[1].SeqNumber, true))
+	removed := fake.Notifications[0].SeqNumber
+	require.NoError(t, LpacNotificationRemove(removed))
+	l.Observe(eid, fake.Notifications)
+	_, ok = l.Find(eid, removed)
+	assert.False(t, ok)
+
+	saved, err := LoadNotificationLedger(l.path)
+	require.NoError(t, err)
+	assert.Len(t, saved.records, 2)
+	seen, ok = saved.Find(eid, fake.Notifications[1].SeqNumber)
+	require.True(t, ok)
+	assert.Equal(t, OriginExternal, seen.Origin)
+}
+
+func TestNotificationLedgerNewCard(t *testing.T) {
+	fake := useFakeLpac(t)
+	l, _ := useNotificationLedger(t)
+
+	// 第一次操作的卡片先记录已经存在的通知，EID 从执行操作的卡片读取
+	fake.addNotification("disable", "8988303000000000002")
+	require.NoError(t, LpacProfileDisable("8988303000000000002"))
+	require.Len(t, fake.Notifications, 2)
+	assert.True(t, l.Known(fake.Chip.EidValue))
+	for seq, origin := range map[int]NotificationOrigin{
+		fake.Notifications[0].SeqNumber: OriginExternal,
+		fake.Notifications[1].SeqNumber: OriginDisable,
+	} {
+		seen, ok := l.Find(fake.Chip.EidValue, seq)
+		require.True(t, ok)
+		assert.Equal(t, origin, seen.Origin, seq)
+	}
+
+	// 操作失败时不记录
+	fake.RemoveCard()
+	assert.Error(t, LpacProfileDelete("8988303000000000010"))
+	assert.Empty(t, l.expected)
+}
+
+func TestNotificationLedgerExpect(t *testing.T) {
+	l, _ := useNotificationLedger(t)
+	const eid, other = "89049032000000000000000000000001", "89049032000000000000000000000002"
+	l.Observe(eid, nil)
+	l.Observe(other, nil)
+
+	// 操作只归属于同一张卡片和同一个 Profile 的通知
+	l.Expect(eid, OriginDisable, "8988303000000000010")
+	notifications := []*Notification{
+		{SeqNumber: 1, ProfileManagementOperation: "disable", Iccid: "8988303000000000002"},
+		{SeqNumber: 2, ProfileManagementOperation: "disable", Iccid: "8988303000000000010"},
+		{SeqNumber: 3, ProfileManagementOperation: "disable", Iccid: "8988303000000000010"},
+	}
+	l.Observe(other, notifications[1:2])
+	l.Observe(eid, notifications)
+	for seq, origin := range map[int]NotificationOrigin{1: OriginExternal, 2: OriginDisable, 3: OriginExternal} {
+		seen, ok := l.Find(eid, seq)
+		require.True(t, ok)
+		assert.Equal(t, origin, seen.Origin, seq)
+	}
+	seen, ok := l.Find(other, 2)
+	require.True(t, ok)
+	assert.Equal(t, OriginExternal, seen.Origin)
+	assert.Empty(t, l.expected, "the expectation is consumed")
+}
+
+func TestNotificationLedgerOverdue(t *testing.T) {
+	l, now := useNotificationLedger(t)
+	l.Observe("89049032000000000000000000000001", []*Notification{{SeqNumber: 1, ProfileManagementOperation: "install"}})
+	*now = now.Add(time.Hour)
+	l.Observe("89049032000000000000000000000002", []*Notification{{SeqNumber: 5, ProfileManagementOperation: "delete"}})
+
+	*now = now.Add(DefaultNotificationWarnAfter)
+	overdue := l.Overdue("", DefaultNotificationWarnAfter)
+	require.Len(t, overdue, 2)
+	assert.Equal(t, 1, overdue[0].SeqNumber)
+	assert.Len(t, l.Overdue("89049032000000000000000000000002", DefaultNotificationWarnAfter), 1)
+	assert.Len(t, l.Overdue("", DefaultNotificationWarnAfter+time.Minute), 1)
+	assert.Empty(t, l.Overdue("", 0))
+
+	var none *NotificationLedger
+	none.Expect("89049032000000000000000000000001", OriginDownload, "")
+	assert.Empty(t, none.Overdue("", time.Hour))
+}
+
+func TestNotificationLedgerCorrupt(t *testing.T) {
+	path := filepath.Join(t.TempDir(), notificationLedgerFilename)
+	require.NoError(t, os.WriteFile(path, []byte("{"), 0600))
+	l, err := LoadNotificationLedger(path)
+	assert.Error(t, err)
+	l.Observe("89049032000000000000000000000001", []*Notification{{SeqNumber: 1}})
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "{", string(data))
+}
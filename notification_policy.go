@@ -0,0 +1,160 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// NotificationAction 是通知策略对一个通知的处理方式
+type NotificationAction string
+
+const (
+	ActionSendRemove NotificationAction = "send_remove"
+	ActionSendKeep   NotificationAction = "send_keep"
+	ActionKeep       NotificationAction = "keep"
+	// ActionRemove 不发送直接从卡片删除
+	ActionRemove NotificationAction = "remove"
+	// ActionAsk 由用户决定，命令行、API 等无法询问时保留在卡片上
+	ActionAsk NotificationAction = "ask"
+)
+
+var NotificationActions = []NotificationAction{ActionSendRemove, ActionSendKeep, ActionKeep, ActionRemove, ActionAsk}
+
+// NotificationOperations 是可以设置策略的通知类型
+var NotificationOperations = []string{"install", "enable", "disable", "delete"}
+
+// Sends 判断是否需要发送给 SM-DP+
+func (a NotificationAction) Sends() bool {
+	return a == ActionSendRemove || a == ActionSendKeep
+}
+
+// NotificationPolicy 按通知类型决定处理方式，Overrides 可以为指定的 SM-DP+ 单独设置
+type NotificationPolicy struct {
+	Operations map[string]NotificationAction `json:"operations"`
+	Overrides  []*NotificationOverride       `json:"overrides,omitempty"`
+}
+
+// NotificationOverride 覆盖发往 Address 的通知的处理方式
+// Address 不区分大小写，*.example.com 匹配所有子域名，Operations 中没有的类型使用默认策略
+type NotificationOverride struct {
+	Address    string                        `json:"address"`
+	Operations map[string]NotificationAction `json:"operations"`
+}
+
+// DefaultNotificationPolicy 与原来的自动处理相同：发送后删除，delete 通知发送后保留
+func DefaultNotificationPolicy() *NotificationPolicy {
+	return &NotificationPolicy{Operations: map[string]NotificationAction{
+		"install": ActionSendRemove,
+		"enable":  ActionSendRemove,
+		"disable": ActionSendRemove,
+		"delete":  ActionSendKeep,
+	}}
+}
+
+// AskNotificationPolicy 对应关闭自动处理：所有通知都询问
+func AskNotificationPolicy() *NotificationPolicy {
+	p := &NotificationPolicy{Operations: make(map[string]NotificationAction)}
+	for _, operation := range NotificationOperations {
+		p.Operations[operation] = ActionAsk
+	}
+	return p
+}
+
+// currentNotificationPolicy 返回配置中的通知策略，没有设置时使用默认策略
+func currentNotificationPolicy() *NotificationPolicy {
+	if ConfigInstance.NotificationPolicy == nil {
+		return DefaultNotificationPolicy()
+	}
+	return ConfigInstance.NotificationPolicy
+}
+
+// processAction 是手动发送时对应的处理方式
+func processAction(remove bool) NotificationAction {
+	if remove {
+		return ActionSendRemove
+	}
+	return ActionSendKeep
+}
+
+// Action 返回通知 n 的处理方式，匹配的 Override 优先，未知类型询问用户
+func (p *NotificationPolicy) Action(n *Notification) NotificationAction {
+	if o := p.Override(n.NotificationAddress); o != nil {
+		if action, ok := o.Operations[n.ProfileManagementOperation]; ok {
+			return action
+		}
+	}
+	if action, ok := p.Operations[n.ProfileManagementOperation]; ok {
+		return action
+	}
+	return ActionAsk
+}
+
+// Override 返回匹配 address 的设置，精确匹配优先于通配符
+func (p *NotificationPolicy) Override(address string) *NotificationOverride {
+	var wildcard *NotificationOverride
+	for _, o := range p.Overrides {
+		switch {
+		case strings.EqualFold(o.Address, address):
+			return o
+		case wildcard == nil && matchAddressPattern(o.Address, address):
+			wildcard = o
+		}
+	}
+	return wildcard
+}
+
+func matchAddressPattern(pattern, address string) bool {
+	suffix, ok := strings.CutPrefix(strings.ToLower(pattern), "*.")
+	return ok && strings.HasSuffix(strings.ToLower(address), "."+suffix)
+}
+
+// Clone 返回深拷贝，界面修改副本后再保存
+func (p *NotificationPolicy) Clone() *NotificationPolicy {
+	clone := &NotificationPolicy{Operations: make(map[string]NotificationAction)}
+	for operation, action := range p.Operations {
+		clone.Operations[operation] = action
+	}
+	for _, o := range p.Overrides {
+		override := &NotificationOverride{Address: o.Address, Operations: make(map[string]NotificationAction)}
+		for operation, action := range o.Operations {
+			override.Operations[operation] = action
+		}
+		clone.Overrides = append(clone.Overrides, override)
+	}
+	return clone
+}
+
+// validate 把不合法的设置恢复为默认值，返回说明
+func (p *NotificationPolicy) validate() []string {
+	var warnings []string
+	defaults := DefaultNotificationPolicy()
+	if p.Operations == nil {
+		p.Operations = make(map[string]NotificationAction)
+	}
+	for _, operation := range NotificationOperations {
+		if action, ok := p.Operations[operation]; !ok || !slices.Contains(NotificationActions, action) {
+			if ok {
+				warnings = append(warnings, fmt.Sprintf("notification_policy.operations.%s: invalid value %q", operation, action))
+			}
+			p.Operations[operation] = defaults.Operations[operation]
+		}
+	}
+	p.Overrides = slices.DeleteFunc(p.Overrides, func(o *NotificationOverride) bool {
+		if o == nil || strings.TrimSpace(o.Address) == "" {
+			warnings = append(warnings, "notification_policy.overrides: address is required")
+			return true
+		}
+		if o.Operations == nil {
+			o.Operations = make(map[string]NotificationAction)
+		}
+		for operation, action := range o.Operations {
+			if !slices.Contains(NotificationOperations, operation) || !slices.Contains(NotificationActions, action) {
+				warnings = append(warnings, fmt.Sprintf("notification_policy.overrides.%s.%s: invalid value %q", o.Address, operation, action))
+				delete(o.Operations, operation)
+			}
+		}
+		return false
+	})
+	return warnings
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNotificationPolicyAction(t *testing.T) {
+	policy := DefaultNotificationPolicy()
+	policy.Overrides = []*NotificationOverride{
+		{Address: "*.example.com", Operations: map[string]NotificationAction{"install": ActionKeep}},
+		{Address: "SMDP.example.com", Operations: map[string]NotificationAction{"install": ActionAsk}},
+	}
+	notification := func(operation, address string) *Notification {
+		return &Notification{ProfileManagementOperation: operation, NotificationAddress: address}
+	}
+	assert.Equal(t, ActionSendRemove, policy.Action(notification("install", "smdp.example.org")))
+	assert.Equal(t, ActionSendKeep, policy.Action(notification("delete", "smdp.example.org")))
+	assert.Equal(t, ActionAsk, policy.Action(notification("install", "smdp.example.com")), "an exact match wins over a wildcard")
+	assert.Equal(t, ActionKeep, policy.Action(notification("install", "rsp.example.com")))
+	assert.Equal(t, ActionSendRemove, policy.Action(notification("enable", "rsp.example.com")), "missing operations use the default")
+	assert.Equal(t, ActionSendRemove, policy.Action(notification("install", "example.com")))
+	assert.Equal(t, ActionAsk, policy.Action(notification("unknown", "smdp.example.org")))
+}
+
+func TestNotificationPolicyValidate(t *testing.T) {
+	policy := &NotificationPolicy{
+		Operations: map[string]NotificationAction{"install": "send", "delete": ActionRemove},
+		Overrides: []*NotificationOverride{
+			{Address: " "},
+			{Address: "smdp.example.com", Operations: map[string]NotificationAction{"enable": ActionKeep, "switch": ActionKeep}},
+			{Address: "rsp.example.com"},
+		},
+	}
+	assert.Len(t, policy.validate(), 3)
+	assert.Equal(t, ActionSendRemove, policy.Operations["install"])
+	assert.Equal(t, ActionRemove, policy.Operations["delete"])
+	assert.Equal(t, ActionSendRemove, policy.Operations["enable"])
+	require.Len(t, policy.Overrides, 2)
+	assert.Equal(t, map[string]NotificationAction{"enable": ActionKeep}, policy.Overrides[0].Operations)
+	assert.NotNil(t, policy.Overrides[1].Operations)
+
+	clone := policy.Clone()
+	clone.Overrides[0].Operations["enable"] = ActionAsk
+	assert.Equal(t, ActionKeep, policy.Overrides[0].Operations["enable"])
+}
+
+func TestSendNotificationsPolicy(t *testing.T) {
+	useConfigFile(t, "")
+	fake := useFakeLpac(t)
+	ConfigInstance.NotificationPolicy = DefaultNotificationPolicy()
+	ConfigInstance.NotificationPolicy.Operations["enable"] = ActionRemove
+	ConfigInstance.NotificationPolicy.Overrides = []*NotificationOverride{
+		{Address: "*.example.com", Operations: map[string]NotificationAction{"disable": ActionAsk}},
+	}
+
+	outcomes, err := RunWithNotifications(true, func() error { return LpacProfileEnable("8988303000000000010") })
+	require.NoError(t, err)
+	require.Len(t, outcomes, 2)
+	actions := map[string]NotificationAction{}
+	for _, outcome := range outcomes {
+		require.NoError(t, outcome.Err)
+		actions[outcome.Notification.ProfileManagementOperation] = outcome.Action
+	}
+	assert.Equal(t, map[string]NotificationAction{"enable": ActionRemove, "disable": ActionAsk}, actions)
+	pending := PendingNotifications(outcomes)
+	require.Len(t, pending, 1)
+	require.Len(t, fake.Notifications, 1, "only the notification waiting for a decision is left")
+	assert.Equal(t, pending[0].SeqNumber, fake.Notifications[0].SeqNumber)
+}
@@ -0,0 +1,387 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+const retryQueueFilename = "notification-queue.json"
+
+const (
+	retryBaseDelay     = time.Minute
+	retryMaxDelay      = 6 * time.Hour
+	retryCheckInterval = 30 * time.Second
+	// RetryStaleAfter 之后仍未发送成功的通知在界面中提示
+	RetryStaleAfter = 24 * time.Hour
+)
+
+// RetryItem 是一个自动发送失败、等待重试的通知，以 EID 和 seqNumber 区分
+type RetryItem struct {
+	EID       string `json:"eid"`
+	SeqNumber int    `json:"seq_number"`
+	Iccid     string `json:"iccid,omitempty"`
+	Operation string `json:"operation"`
+	Address   string `json:"address"`
+	// Remove 为 true 时发送成功后从卡片删除，与失败时的通知策略一致
+	Remove      bool      `json:"remove"`
+	Attempts    int       `json:"attempts"`
+	FirstFailed time.Time `json:"first_failed"`
+	LastAttempt time.Time `json:"last_attempt"`
+	NextAttempt time.Time `json:"next_attempt"`
+	ErrorClass  string    `json:"error_class"`
+	Error       string    `json:"error"`
+}
+
+// Stale 判断通知是否已经很久没有发送成功
+func (i *RetryItem) Stale(now time.Time) bool {
+	return now.Sub(i.FirstFailed) >= RetryStaleAfter
+}
+
+// retryBackoff 返回第 attempts 次失败后的等待时间，从 1 分钟开始每次翻倍，最多 6 小时
+func retryBackoff(attempts int) time.Duration {
+	d := retryBaseDelay
+	for i := 1; i < attempts && d < retryMaxDelay; i++ {
+		d *= 2
+	}
+	return min(d, retryMaxDelay)
+}
+
+// RetryQueue 保存在配置目录中，卡片在读卡器中时按退避时间重新发送
+// 通知只会通过 notification process -r 在发送成功后删除
+type RetryQueue struct {
+	mu    sync.Mutex
+	path  string // 为空时只保存在内存中
+	items []*RetryItem
+	now   func() time.Time
+
+	// OnChange 在队列变化后调用
+	OnChange func()
+	// CardChanged 在重新发送成功、卡片上的通知改变后调用
+	CardChanged func()
+
+	// card 是最近读取到的 EID，读取失败时为空
+	card string
+	// 读卡器中没有需要重试的卡片时，按退避时间检查是否插入了其他卡片
+	probeFailures int
+	probeAfter    time.Time
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NotificationRetries 是界面和 serve 模式中的重试队列，命令行模式下为 nil，不记录失败
+var NotificationRetries *RetryQueue
+
+// LoadRetryQueue 读取 path 中的重试队列，文件不存在时返回空队列
+// 文件无法解析时返回只保存在内存中的空队列和错误，不覆盖原文件
+func LoadRetryQueue(path string) (*RetryQueue, error) {
+	q := &RetryQueue{path: path, now: time.Now, OnChange: func() {}, CardChanged: func() {}}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return q, nil
+	}
+	if err == nil {
+		err = json.Unmarshal(data, &q.items)
+	}
+	if err != nil {
+		q.path, q.items = "", nil
+		return q, fmt.Errorf("%s: %w", path, err)
+	}
+	return q, nil
+}
+
+// InitNotificationRetries 加载配置目录中的重试队列
+func InitNotificationRetries() {
+	q, err := LoadRetryQueue(filepath.Join(filepath.Dir(ConfigInstance.ConfigPath), retryQueueFilename))
+	if err != nil {
+		ConfigInstance.ConfigWarnings = append(ConfigInstance.ConfigWarnings, err.Error())
+	}
+	NotificationRetries = q
+}
+
+// save 在持有锁时调用
+func (q *RetryQueue) save() {
+	if q.path == "" {
+		return
+	}
+	data, err := json.MarshalIndent(q.items, "", "  ")
+	if err == nil {
+		err = os.MkdirAll(filepath.Dir(q.path), 0755)
+	}
+	if err == nil {
+		tmp := q.path + ".tmp"
+		if err = os.WriteFile(tmp, append(data, '\n'), 0600); err == nil {
+			err = os.Rename(tmp, q.path)
+		}
+	}
+	if err != nil && ConfigInstance.LogFile != nil {
+		fmt.Fprintln(ConfigInstance.LogFile, "notification retry:", err)
+	}
+}
+
+// Items 返回所有等待重试的通知，最早失败的在前
+func (q *RetryQueue) Items() []RetryItem {
+	if q == nil {
+		return nil
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	items := make([]RetryItem, 0, len(q.items))
+	for _, item := range q.items {
+		items = append(items, *item)
+	}
+	slices.SortStableFunc(items, func(a, b RetryItem) int { return a.FirstFailed.Compare(b.FirstFailed) })
+	return items
+}
+
+// Find 查找卡片 eid 上序号为 seq 的通知
+func (q *RetryQueue) Find(eid string, seq int) (RetryItem, bool) {
+	if q == nil {
+		return RetryItem{}, false
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if i := q.index(eid, seq); i >= 0 {
+		return *q.items[i], true
+	}
+	return RetryItem{}, false
+}
+
+func (q *RetryQueue) index(eid string, seq int) int {
+	return slices.IndexFunc(q.items, func(item *RetryItem) bool {
+		return strings.EqualFold(item.EID, eid) && item.SeqNumber == seq
+	})
+}
+
+// Record 记录一次发送失败，并按失败次数安排下一次重试
+func (q *RetryQueue) Record(eid string, n *Notification, remove bool, err error) {
+	if q == nil {
+		return
+	}
+	now := q.now()
+	q.mu.Lock()
+	var item *RetryItem
+	if i := q.index(eid, n.SeqNumber); i >= 0 {
+		item = q.items[i]
+	} else {
+		item = &RetryItem{EID: eid, SeqNumber: n.SeqNumber, FirstFailed: now}
+		q.items = append(q.items, item)
+	}
+	item.Iccid = n.Iccid
+	item.Operation = n.ProfileManagementOperation
+	item.Address = n.NotificationAddress
+	item.Remove = remove
+	item.Attempts++
+	item.LastAttempt = now
+	item.NextAttempt = now.Add(retryBackoff(item.Attempts))
+	item.ErrorClass = ErrorClassUnknown.String()
+	if lpacErr, ok := AsLpacError(err); ok {
+		item.ErrorClass = lpacErr.Class.String()
+	}
+	item.Error = strings.TrimSpace(err.Error())
+	q.save()
+	q.mu.Unlock()
+	q.OnChange()
+}
+
+// Resolve 从队列中移除已经发送或不在卡片上的通知
+func (q *RetryQueue) Resolve(eid string, seq int) {
+	if q == nil {
+		return
+	}
+	q.mu.Lock()
+	i := q.index(eid, seq)
+	if i < 0 {
+		q.mu.Unlock()
+		return
+	}
+	q.items = slices.Delete(q.items, i, i+1)
+	q.save()
+	q.mu.Unlock()
+	q.OnChange()
+}
+
+// Handled 在卡片 eid 上的通知被手动发送或删除后调用
+// eid 为空时不处理，RetryDue 发现通知已经不在卡片上后会移除
+func (q *RetryQueue) Handled(eid string, seq int) {
+	if q == nil || eid == "" {
+		return
+	}
+	q.Resolve(eid, seq)
+}
+
+// Queued 判断任意卡片上序号为 seq 的通知是否在等待重试
+func (q *RetryQueue) Queued(seq int) bool {
+	if q == nil {
+		return false
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return slices.ContainsFunc(q.items, func(item *RetryItem) bool { return item.SeqNumber == seq })
+}
+
+// notificationHandled 把手动发送或删除的通知移出重试队列，EID 从执行操作的卡片读取
+func notificationHandled(seq int) {
+	if !NotificationRetries.Queued(seq) {
+		return
+	}
+	if info, err := LpacChipInfo(); err == nil {
+		NotificationRetries.Handled(info.EidValue, seq)
+	}
+}
+
+// SetCard 记录读卡器中的卡片，界面读取 EID 后调用，避免重试时再次读取
+func (q *RetryQueue) SetCard(eid string) {
+	if q == nil {
+		return
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.card = eid
+}
+
+// backoffProbe 在读卡器中没有卡片或卡片上没有到期的通知后推迟下一次检查
+func (q *RetryQueue) backoffProbe(now time.Time) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.probeFailures++
+	q.probeAfter = now.Add(retryBackoff(q.probeFailures))
+}
+
+// recordNotificationFailures 把按策略发送失败的通知加入重试队列，删除失败的不重试
+func recordNotificationFailures(outcomes []NotificationOutcome) {
+	failed := func(o NotificationOutcome) bool { return o.Err != nil && o.Action.Sends() }
+	if NotificationRetries == nil || !slices.ContainsFunc(outcomes, failed) {
+		return
+	}
+	info, err := LpacChipInfo()
+	if err != nil {
+		if ConfigInstance.LogFile != nil {
+			fmt.Fprintln(ConfigInstance.LogFile, "notification retry:", err)
+		}
+		return
+	}
+	NotificationRetries.SetCard(info.EidValue)
+	for _, outcome := range outcomes {
+		if failed(outcome) {
+			NotificationRetries.Record(info.EidValue, outcome.Notification, outcome.Action == ActionSendRemove, outcome.Err)
+		}
+	}
+}
+
+// RetryDue 重新发送到期的通知，卡片不在读卡器中时不计入失败次数，返回尝试发送的数量
+// 读卡器正在执行其他操作时跳过这一次检查
+// 只有最近读取到的卡片上有到期的通知时才立即读取卡片，其他卡片的通知按退避时间检查是否已经插入
+func (q *RetryQueue) RetryDue(ctx context.Context) int {
+	now := q.now()
+	q.mu.Lock()
+	var dueHere, dueElsewhere bool
+	for _, item := range q.items {
+		switch {
+		case item.NextAttempt.After(now):
+		case q.card != "" && strings.EqualFold(item.EID, q.card):
+			dueHere = true
+		default:
+			dueElsewhere = true
+		}
+	}
+	probe := dueHere || dueElsewhere && !now.Before(q.probeAfter)
+	q.mu.Unlock()
+	if !probe {
+		return 0
+	}
+	// 不在其他操作中途读取卡片，否则可能按序号在切换后的卡片上发送或删除通知
+	release, ok := CardJobs.TryHold()
+	if !ok {
+		return 0
+	}
+	defer release()
+	info, err := LpacChipInfo()
+	if err != nil {
+		q.SetCard("")
+		q.backoffProbe(now)
+		return 0
+	}
+	q.SetCard(info.EidValue)
+	var items []RetryItem
+	for _, item := range q.Items() {
+		if strings.EqualFold(item.EID, info.EidValue) && !item.NextAttempt.After(now) {
+			items = append(items, item)
+		}
+	}
+	if len(items) == 0 {
+		q.backoffProbe(now)
+		return 0
+	}
+	q.mu.Lock()
+	q.probeFailures, q.probeAfter = 0, time.Time{}
+	q.mu.Unlock()
+	notifications, err := LpacNotificationList()
+	if err != nil {
+		return 0
+	}
+
+	var attempted, sent int
+	for _, item := range items {
+		if ctx.Err() != nil {
+			break
+		}
+		i := slices.IndexFunc(notifications, func(n *Notification) bool { return n.SeqNumber == item.SeqNumber })
+		if i < 0 {
+			// 已经在其他地方发送或删除
+			q.Resolve(item.EID, item.SeqNumber)
+			continue
+		}
+		attempted++
+		err := sendNotification(notifications[i], item.Remove)
+		if ConfigInstance.LogFile != nil {
+			fmt.Fprintf(ConfigInstance.LogFile, "notification retry: %s seq %d attempt %d: %v\n",
+				item.EID, item.SeqNumber, item.Attempts+1, err)
+		}
+		if err != nil {
+			q.Record(item.EID, notifications[i], item.Remove, err)
+			continue
+		}
+		q.Resolve(item.EID, item.SeqNumber)
+		sent++
+	}
+	if sent > 0 {
+		q.CardChanged()
+	}
+	return attempted
+}
+
+// Start 定期检查到期的通知，直到调用 Stop
+func (q *RetryQueue) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	q.cancel = cancel
+	q.wg.Add(1)
+	go func() {
+		defer q.wg.Done()
+		ticker := time.NewTicker(retryCheckInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				q.RetryDue(ctx)
+			}
+		}
+	}()
+}
+
+func (q *RetryQueue) Stop() {
+	if q != nil && q.cancel != nil {
+		q.cancel()
+		q.wg.Wait()
+	}
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// useRetryQueue 在测试中启用保存在临时目录的重试队列，时间由返回的指针控制
+func useRetryQueue(t *testing.T) (*RetryQueue, *time.Time) {
+	path := filepath.Join(t.TempDir(), retryQueueFilename)
+	q, err := LoadRetryQueue(path)
+	require.NoError(t, err)
+	now := time.Date(2026, 10, 18, 8, 0, 0, 0, time.UTC)
+	q.now = func() time.Time { return now }
+	NotificationRetries = q
+	t.Cleanup(func() {
+		NotificationRetries = nil
+	})
+	return q, &now
+}
+
+func TestRetryBackoff(t *testing.T) {
+	assert.Equal(t, time.Minute, retryBackoff(1))
+	assert.Equal(t, 4*time.Minute, retryBackoff(3))
+	assert.Equal(t, 6*time.Hour, retryBackoff(20))
+}
+
+func TestNotificationRetryQueue(t *testing.T) {
+	fake := useFakeLpac(t)
+	q, now := useRetryQueue(t)
+	var cardChanged int
+	q.CardChanged = func() { cardChanged++ }
+
+	// 离线时切换 Profile，两个通知都留在卡片上等待重试
+	fake.Fail("notification process", &FakeFailure{Function: "es9p_handle_notification", Data: "curl: (6) Could not resolve host"})
+	outcomes, err := RunWithNotifications(true, func() error { return LpacProfileEnable("8988303000000000010") })
+	require.NoError(t, err)
+	require.Len(t, outcomes, 2)
+	items := q.Items()
+	require.Len(t, items, 2)
+	assert.Equal(t, fake.Chip.EidValue, items[0].EID)
+	assert.Equal(t, "network", items[0].ErrorClass)
+	assert.Equal(t, now.Add(time.Minute), items[0].NextAttempt)
+	assert.Len(t, fake.Notifications, 2)
+
+	saved, err := LoadRetryQueue(q.path)
+	require.NoError(t, err)
+	assert.Len(t, saved.Items(), 2)
+
+	// 未到重试时间不发送，失败后等待时间翻倍
+	assert.Zero(t, q.RetryDue(context.Background()))
+	*now = now.Add(time.Minute)
+	// 读卡器正在执行其他操作时跳过
+	calls := len(fake.Calls)
+	release := CardJobs.Hold()
+	assert.Zero(t, q.RetryDue(context.Background()))
+	release()
+	assert.Len(t, fake.Calls, calls)
+	assert.Equal(t, 2, q.RetryDue(context.Background()))
+	assert.False(t, CardJobs.Busy())
+	items = q.Items()
+	assert.Equal(t, 2, items[0].Attempts)
+	assert.Equal(t, now.Add(2*time.Minute), items[0].NextAttempt)
+	assert.Len(t, fake.Notifications, 2, "failed notifications must stay on the card")
+
+	// 卡片不在读卡器中时不计入失败次数
+	*now = now.Add(2 * time.Minute)
+	fake.RemoveCard()
+	assert.Zero(t, q.RetryDue(context.Background()))
+	assert.Equal(t, 2, q.Items()[0].Attempts)
+
+	// 没有卡片时按退避时间检查，不再每次都读取卡片
+	calls = len(fake.Calls)
+	fake.Removed = false
+	fake.ClearFailures()
+	assert.Zero(t, q.RetryDue(context.Background()))
+	assert.Len(t, fake.Calls, calls)
+	*now = now.Add(time.Minute)
+	assert.Equal(t, 2, q.RetryDue(context.Background()))
+	assert.Empty(t, q.Items())
+	assert.Empty(t, fake.Notifications)
+	assert.Equal(t, 1, cardChanged)
+	assert.True(t, items[0].Stale(now.Add(RetryStaleAfter)))
+}
+
+func TestNotificationRetryResolve(t *testing.T) {
+	fake := useFakeLpac(t)
+	q, now := useRetryQueue(t)
+
+	fake.Fail("notification process", &FakeFailure{Function: "es9p_handle_notification", Data: "curl: (7) Failed to connect"})
+	outcomes, err := RunWithNotifications(true, func() error { return LpacProfileDelete("8988303000000000010") })
+	require.NoError(t, err)
+	require.Len(t, outcomes, 1)
+	item, ok := q.Find(fake.Chip.EidValue, outcomes[0].Notification.SeqNumber)
+	require.True(t, ok)
+	// delete 通知发送成功后也保留在卡片上
+	assert.False(t, item.Remove)
+	fake.ClearFailures()
+	*now = now.Add(time.Minute)
+	assert.Equal(t, 1, q.RetryDue(context.Background()))
+	assert.Empty(t, q.Items())
+	assert.Len(t, fake.Notifications, 1)
+
+	// 手动发送或删除后不再重试
+	fake.Fail("notification process", &FakeFailure{Function: "es9p_handle_notification", Data: "curl: (7) Failed to connect"})
+	outcomes, err = RunWithNotifications(true, func() error { return LpacProfileDisable("8988303000000000002") })
+	require.NoError(t, err)
+	require.Len(t, outcomes, 1)
+	require.Len(t, q.Items(), 1)
+	fake.ClearFailures()
+	require.NoError(t, LpacNotificationProcess(outcomes[0].Notification.SeqNumber, false))
+	assert.Empty(t, q.Items())
+}
+
+func TestNotificationRetryOtherCard(t *testing.T) {
+	fake := useFakeLpac(t)
+	q, now := useRetryQueue(t)
+	q.Record("89049032000000000000000000000001", &Notification{SeqNumber: 1, ProfileManagementOperation: "install"},
+		true, errors.New("curl: (6) Could not resolve host"))
+
+	// 其他卡片的通知到期后只检查一次读卡器，之后按退避时间检查
+	*now = now.Add(time.Minute)
+	q.SetCard(fake.Chip.EidValue)
+	assert.Zero(t, q.RetryDue(context.Background()))
+	calls := len(fake.Calls)
+	require.NotZero(t, calls)
+	*now = now.Add(30 * time.Second)
+	assert.Zero(t, q.RetryDue(context.Background()))
+	assert.Len(t, fake.Calls, calls)
+
+	// 换上那张卡片后通知已经不在卡片上，从队列中移除
+	fake.InsertCard("89049032000000000000000000000001")
+	*now = now.Add(2 * time.Minute)
+	assert.Zero(t, q.RetryDue(context.Background()))
+	assert.Empty(t, q.Items())
+}
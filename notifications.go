@@ -0,0 +1,130 @@
+package main
+
+import "strings"
+
+// NotificationOutcome 是按通知策略处理一个通知的结果
+// Action 为 ActionAsk 时通知没有被处理，由调用者询问用户
+type NotificationOutcome struct {
+	Notification *Notification
+	Action       NotificationAction
+	Removed      bool
+	Err          error
+}
+
+// OperationResult 是会产生通知的操作的结果，命令行和 API 输出的 JSON
+type OperationResult struct {
+	Command       string               `json:"command"`
+	Iccid         string               `json:"iccid,omitempty"`
+	Notifications []NotificationResult `json:"notifications"`
+}
+
+type NotificationResult struct {
+	*Notification
+	Action  NotificationAction `json:"action,omitempty"`
+	Removed bool               `json:"removed"`
+	Error   string             `json:"error,omitempty"`
+}
+
+// NewOperationResult 汇总通知的发送结果，同时返回第一个发送失败的错误
+func NewOperationResult(command, iccid string, outcomes []NotificationOutcome) (OperationResult, error) {
+	result := OperationResult{Command: command, Iccid: iccid, Notifications: []NotificationResult{}}
+	var firstErr error
+	for _, outcome := range outcomes {
+		r := NotificationResult{Notification: outcome.Notification, Action: outcome.Action, Removed: outcome.Removed}
+		if outcome.Err != nil {
+			r.Error = strings.TrimSpace(outcome.Err.Error())
+			if firstErr == nil {
+				firstErr = outcome.Err
+			}
+		}
+		result.Notifications = append(result.Notifications, r)
+	}
+	return result, firstErr
+}
+
+// SendNotifications 按通知策略处理操作产生的通知，不涉及界面
+func SendNotifications(notifications []*Notification) []NotificationOutcome {
+	policy := currentNotificationPolicy()
+	outcomes := make([]NotificationOutcome, 0, len(notifications))
+	for _, notification := range notifications {
+		outcomes = append(outcomes, ApplyNotificationAction(notification, policy.Action(notification)))
+	}
+	// 发送失败的通知留在卡片上，由重试队列稍后重新发送
+	recordNotificationFailures(outcomes)
+	return outcomes
+}
+
+// ApplyNotificationAction 按 action 处理一个通知，ActionKeep 和 ActionAsk 不做任何操作
+func ApplyNotificationAction(n *Notification, action NotificationAction) NotificationOutcome {
+	outcome := NotificationOutcome{Notification: n, Action: action}
+	switch action {
+	case ActionSendRemove, ActionSendKeep:
+		outcome.Err = sendNotification(n, action == ActionSendRemove)
+		outcome.Removed = action == ActionSendRemove && outcome.Err == nil
+	case ActionRemove:
+		outcome.Err = LpacNotificationRemove(n.SeqNumber)
+		outcome.Removed = outcome.Err == nil
+	}
+	return outcome
+}
+
+// PendingNotifications 返回策略为询问、还没有处理的通知
+func PendingNotifications(outcomes []NotificationOutcome) []*Notification {
+	var pending []*Notification
+	for _, outcome := range outcomes {
+		if outcome.Action == ActionAsk {
+			pending = append(pending, outcome.Notification)
+		}
+	}
+	return pending
+}
+
+// RunWithNotifications 执行会产生通知的操作，send 为 true 时按通知策略处理新通知
+func RunWithNotifications(send bool, operation func() error) ([]NotificationOutcome, error) {
+	defer CardJobs.Hold()()
+	var origin []*Notification
+	if send {
+		var err error
+		if origin, err = LpacNotificationList(); err != nil {
+			return nil, err
+		}
+	}
+	if err := operation(); err != nil {
+		return nil, err
+	}
+	if !send {
+		return nil, nil
+	}
+	current, err := LpacNotificationList()
+	if err != nil {
+		return nil, err
+	}
+	return SendNotifications(findNewNotifications(origin, current)), nil
+}
+
+func findNewNotification(origin, new []*Notification) *Notification {
+	exists := make(map[int]bool)
+	for _, notification := range origin {
+		exists[notification.SeqNumber] = true
+	}
+	for _, notification := range new {
+		if !exists[notification.SeqNumber] {
+			return notification
+		}
+	}
+	return nil
+}
+
+func findNewNotifications(origin, new []*Notification) []*Notification {
+	exists := make(map[int]bool)
+	var foundNotifications []*Notification
+	for _, notification := range origin {
+		exists[notification.SeqNumber] = true
+	}
+	for _, notification := range new {
+		if !exists[notification.SeqNumber] {
+			foundNotifications = append(foundNotifications, notification)
+		}
+	}
+	return foundNotifications
+}
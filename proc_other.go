@@ -3,9 +3,17 @@
 package main
 
 import (
+	"os"
 	"os/exec"
 )
 
 func HideCmdWindow(cmd *exec.Cmd) {
 	// Do nothing on non-Windows systems.
 }
+
+// InterruptCmdOnCancel 取消时发送 SIGINT 而不是直接 kill
+func InterruptCmdOnCancel(cmd *exec.Cmd) {
+	cmd.Cancel = func() error {
+		return cmd.Process.Signal(os.Interrupt)
+	}
+}
@@ -13,3 +13,6 @@ func HideCmdWindow(cmd *exec.Cmd) {
 		HideWindow: true,
 	}
 }
+
+// InterruptCmdOnCancel Windows has no SIGINT for child processes, keep the default kill
+func InterruptCmdOnCancel(cmd *exec.Cmd) {}
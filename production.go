@@ -0,0 +1,191 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"os"
+	"time"
+)
+
+// ProductionState 是产线模式当前所处的阶段
+type ProductionState int
+
+const (
+	ProductionWaitingCard    ProductionState = iota // 等待插入卡片
+	ProductionProvisioning                          // 正在下载
+	ProductionWaitingRemoval                        // 等待取出卡片
+	ProductionFinished                              // 激活码已用完
+)
+
+func (s ProductionState) String() string {
+	switch s {
+	case ProductionProvisioning:
+		return "provisioning"
+	case ProductionWaitingRemoval:
+		return "waiting_removal"
+	case ProductionFinished:
+		return "finished"
+	default:
+		return "waiting_card"
+	}
+}
+
+// ProductionEvent 描述产线模式的状态变化
+type ProductionEvent struct {
+	State ProductionState
+	EID   string
+	// Result 在一张卡片处理完成后不为 nil
+	Result *ProductionResult
+	// Err 是读取卡片失败的原因，例如插入的不是 eUICC
+	Err error
+	// Duplicate 表示这张卡片本次已经写入过
+	Duplicate bool
+	Remaining int
+}
+
+// ProductionResult 是一张卡片的写入结果
+type ProductionResult struct {
+	Time time.Time
+	EID  string
+	Row  BatchRow
+}
+
+// ProductionLine 在读卡器上每插入一张卡片就下载一个激活码
+type ProductionLine struct {
+	Batch *Batch
+	// ResultsFile 不为空时把每张卡片的 EID 和 ICCID 追加到该 CSV 文件
+	ResultsFile  string
+	PollInterval time.Duration
+	// OnEvent 在状态变化时调用
+	OnEvent func(ProductionEvent)
+
+	// provisioned 以 EID 为键记录本次已经写入成功的卡片
+	provisioned map[string]string
+}
+
+const defaultProductionPollInterval = 2 * time.Second
+
+// Run 轮询读卡器直到激活码用完或 ctx 被取消
+func (p *ProductionLine) Run(ctx context.Context) error {
+	defer CardJobs.Hold()()
+	if p.PollInterval <= 0 {
+		p.PollInterval = defaultProductionPollInterval
+	}
+	if p.OnEvent == nil {
+		p.OnEvent = func(ProductionEvent) {}
+	}
+	if p.provisioned == nil {
+		p.provisioned = make(map[string]string)
+	}
+	if p.Remaining() == 0 {
+		p.emit(ProductionEvent{State: ProductionFinished})
+		return nil
+	}
+	state, eid := ProductionWaitingCard, ""
+	p.emit(ProductionEvent{State: state})
+	for {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		current, present, err := p.readEID()
+		switch {
+		case state == ProductionWaitingCard && err != nil:
+			state, eid = ProductionWaitingRemoval, ""
+			p.emit(ProductionEvent{State: state, Err: err})
+		case state == ProductionWaitingCard && present:
+			state, eid = ProductionWaitingRemoval, current
+			p.provision(eid)
+		case state == ProductionWaitingRemoval && (!present && err == nil || present && current != eid):
+			// 取出卡片，或者直接换上了另一张卡片
+			if p.Remaining() == 0 {
+				p.emit(ProductionEvent{State: ProductionFinished})
+				return nil
+			}
+			state, eid = ProductionWaitingCard, ""
+			p.emit(ProductionEvent{State: state})
+			if present {
+				continue
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(p.PollInterval):
+		}
+	}
+}
+
+// Remaining 返回尚未使用的激活码数量
+func (p *ProductionLine) Remaining() int {
+	return p.Batch.Counts()[BatchPending]
+}
+
+func (p *ProductionLine) emit(e ProductionEvent) {
+	e.Remaining = p.Remaining()
+	p.OnEvent(e)
+}
+
+// readEID 读取读卡器中卡片的 EID，读卡器中没有卡片时 present 为 false
+func (p *ProductionLine) readEID() (eid string, present bool, err error) {
+	info, err := LpacChipInfo()
+	if err != nil {
+		if lpacErr, ok := AsLpacError(err); ok && lpacErr.Class == ErrorClassReader {
+			return "", false, nil
+		}
+		return "", false, err
+	}
+	if info == nil || info.EidValue == "" {
+		return "", false, errors.New("EID not found")
+	}
+	return info.EidValue, true, nil
+}
+
+// provision 为新插入的卡片下载下一个激活码
+func (p *ProductionLine) provision(eid string) {
+	if _, ok := p.provisioned[eid]; ok {
+		p.emit(ProductionEvent{State: ProductionWaitingRemoval, EID: eid, Duplicate: true})
+		return
+	}
+	p.emit(ProductionEvent{State: ProductionProvisioning, EID: eid})
+	row, ok := p.Batch.RunNext()
+	if !ok {
+		p.emit(ProductionEvent{State: ProductionFinished, EID: eid})
+		return
+	}
+	result := &ProductionResult{Time: time.Now(), EID: eid, Row: row}
+	if row.Status == BatchSucceeded {
+		p.provisioned[eid] = row.Iccid
+	} else if lpacErr, ok := AsLpacError(row.Err); !ok || lpacErr.Class != ErrorClassServer {
+		// 不是 SM-DP+ 拒绝的激活码，留给下一张卡片使用
+		p.Batch.Requeue(row.Line)
+	}
+	event := ProductionEvent{State: ProductionWaitingRemoval, EID: eid, Result: result}
+	if err := p.appendResult(result); err != nil {
+		event.Err = err
+	}
+	p.emit(event)
+}
+
+// appendResult 把结果追加到 ResultsFile，新文件先写入表头
+func (p *ProductionLine) appendResult(r *ProductionResult) error {
+	if p.ResultsFile == "" {
+		return nil
+	}
+	f, err := os.OpenFile(p.ResultsFile, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	w := csv.NewWriter(f)
+	if stat, err := f.Stat(); err == nil && stat.Size() == 0 {
+		_ = w.Write([]string{"time", "eid", "iccid", "smdp", "matching_id", "status", "error"})
+	}
+	errText := ""
+	if r.Row.Err != nil {
+		errText = r.Row.Err.Error()
+	}
+	_ = w.Write([]string{r.Time.Format(time.RFC3339), r.EID, r.Row.Iccid, r.Row.Info.SMDP, r.Row.Info.MatchID, r.Row.Status.String(), errText})
+	w.Flush()
+	return w.Error()
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProductionLine(t *testing.T) {
+	fake := useFakeLpac(t)
+	fake.InsertCard("89049032123451234512345678900001")
+	fake.Downloadable["AAA"] = &Profile{Iccid: "8988303000000000028"}
+	fake.Downloadable["CCC"] = &Profile{Iccid: "8901260123456789011"}
+	rows, err := ParseBatchFile("codes.txt", []byte("LPA:1$smdp.example.com$AAA\nLPA:1$smdp.example.com$BBB\nLPA:1$smdp.example.com$CCC\n"))
+	require.NoError(t, err)
+	results := filepath.Join(t.TempDir(), "results.csv")
+
+	var events []ProductionEvent
+	waiting := 0
+	line := &ProductionLine{
+		Batch:        NewBatch(rows),
+		ResultsFile:  results,
+		PollInterval: time.Millisecond,
+		OnEvent: func(e ProductionEvent) {
+			events = append(events, e)
+			switch e.State {
+			case ProductionWaitingRemoval:
+				fake.RemoveCard()
+			case ProductionWaitingCard:
+				// 模拟操作员依次换卡：重复插入同一张卡、写入失败的卡、激活码被拒绝的卡
+				waiting++
+				switch waiting {
+				case 2:
+					fake.InsertCard("89049032123451234512345678900001")
+				case 3:
+					fake.Fail("profile download", &FakeFailure{Function: "es10b_load_bound_profile_package", Data: "installFailedDueToInsufficientMemoryForProfile"})
+					fake.InsertCard("89049032123451234512345678900002")
+				case 4:
+					fake.ClearFailures()
+					fake.InsertCard("89049032123451234512345678900003")
+				case 5:
+					fake.InsertCard("89049032123451234512345678900004")
+				}
+			}
+		},
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(t, line.Run(ctx))
+
+	var duplicates, finished int
+	for _, e := range events {
+		if e.Duplicate {
+			duplicates++
+		}
+		if e.State == ProductionFinished {
+			finished++
+		}
+	}
+	assert.Equal(t, 1, duplicates)
+	assert.Equal(t, 1, finished)
+	assert.Equal(t, ProductionFinished, events[len(events)-1].State)
+
+	f, err := os.Open(results)
+	require.NoError(t, err)
+	defer f.Close()
+	records, err := csv.NewReader(f).ReadAll()
+	require.NoError(t, err)
+	require.Len(t, records, 5)
+	assert.Equal(t, []string{"89049032123451234512345678900001", "8988303000000000028", "smdp.example.com", "AAA", "succeeded"}, records[1][1:6])
+	// 卡片写入失败时激活码留给下一张卡片，被 SM-DP+ 拒绝的激活码不再使用
+	assert.Equal(t, []string{"89049032123451234512345678900002", "BBB", "failed"}, []string{records[2][1], records[2][4], records[2][5]})
+	assert.Equal(t, []string{"89049032123451234512345678900003", "BBB", "failed"}, []string{records[3][1], records[3][4], records[3][5]})
+	assert.Equal(t, []string{"89049032123451234512345678900004", "8901260123456789011", "CCC", "succeeded"}, []string{records[4][1], records[4][2], records[4][4], records[4][5]})
+}
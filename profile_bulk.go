@@ -0,0 +1,169 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// BulkStep 是批量 Profile 操作中的一步
+type BulkStep struct {
+	// Action 为 "disable"、"delete" 或 "nickname"
+	Action   string
+	Profile  *Profile
+	Nickname string
+	Status   BatchStatus
+	Err      error
+}
+
+// ErrBulkEnabledProfile 表示要删除的 Profile 中有已启用的 Profile
+var ErrBulkEnabledProfile = errors.New("selection contains an enabled profile")
+
+// ErrBulkSkipped 表示同一 Profile 的前一步失败，这一步没有执行
+var ErrBulkSkipped = errors.New("skipped because a previous step for this profile failed")
+
+// PlanBulkDelete 生成批量删除的步骤
+// 已启用的 Profile 在 disableEnabled 为 true 时先禁用，否则返回 ErrBulkEnabledProfile
+func PlanBulkDelete(selected []*Profile, disableEnabled bool) ([]*BulkStep, error) {
+	var steps []*BulkStep
+	for _, p := range selected {
+		if p.ProfileState == "enabled" {
+			if !disableEnabled {
+				return nil, ErrBulkEnabledProfile
+			}
+			steps = append(steps, &BulkStep{Action: "disable", Profile: p})
+		}
+	}
+	for _, p := range selected {
+		steps = append(steps, &BulkStep{Action: "delete", Profile: p})
+	}
+	return steps, nil
+}
+
+// PlanBulkNickname 按 ExpandNicknameTemplate 的模板为每个 Profile 生成设置昵称的步骤
+func PlanBulkNickname(selected []*Profile, template string) []*BulkStep {
+	var steps []*BulkStep
+	for _, p := range selected {
+		steps = append(steps, &BulkStep{Action: "nickname", Profile: p, Nickname: ExpandNicknameTemplate(template, p)})
+	}
+	return steps
+}
+
+// BulkOperation 依次执行批量操作的步骤，结束后统一处理一次通知
+type BulkOperation struct {
+	mu    sync.Mutex
+	steps []*BulkStep
+	// Notify 为 true 时按通知策略处理所有步骤产生的新通知
+	Notify        bool
+	notifications []NotificationOutcome
+	notifyErr     error
+}
+
+func NewBulkOperation(steps []*BulkStep) *BulkOperation {
+	return &BulkOperation{steps: steps}
+}
+
+// Steps 返回所有步骤的副本
+func (o *BulkOperation) Steps() []BulkStep {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	steps := make([]BulkStep, len(o.steps))
+	for i, step := range o.steps {
+		steps[i] = *step
+	}
+	return steps
+}
+
+// Progress 返回已结束的步骤数和总步骤数
+func (o *BulkOperation) Progress() (done, total int) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	for _, step := range o.steps {
+		if step.Status == BatchSucceeded || step.Status == BatchFailed {
+			done++
+		}
+	}
+	return done, len(o.steps)
+}
+
+// Notifications 返回结束后发送通知的结果，读取通知列表失败时返回错误
+func (o *BulkOperation) Notifications() ([]NotificationOutcome, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.notifications, o.notifyErr
+}
+
+// Run 依次执行等待中的步骤，同一 Profile 的前一步失败时跳过后续步骤
+// ctx 取消后不再开始新的步骤，但已完成步骤产生的通知仍会发送
+func (o *BulkOperation) Run(ctx context.Context, onUpdate func()) {
+	defer CardJobs.Hold()()
+	if onUpdate == nil {
+		onUpdate = func() {}
+	}
+	var origin []*Notification
+	notify := o.Notify
+	if notify {
+		var err error
+		if origin, err = LpacNotificationList(); err != nil {
+			o.mu.Lock()
+			o.notifyErr = err
+			o.mu.Unlock()
+			notify = false
+		}
+	}
+	failed := make(map[string]bool)
+	for _, step := range o.steps {
+		if ctx.Err() != nil {
+			break
+		}
+		o.mu.Lock()
+		runnable := step.Status == BatchPending
+		if runnable {
+			step.Status = BatchRunning
+		}
+		o.mu.Unlock()
+		if !runnable {
+			continue
+		}
+		onUpdate()
+
+		err := ErrBulkSkipped
+		if !failed[step.Profile.Iccid] {
+			err = runBulkStep(step)
+		}
+		if err != nil {
+			failed[step.Profile.Iccid] = true
+		}
+		o.mu.Lock()
+		step.Err = err
+		step.Status = BatchSucceeded
+		if err != nil {
+			step.Status = BatchFailed
+		}
+		o.mu.Unlock()
+		onUpdate()
+	}
+	if notify {
+		current, err := LpacNotificationList()
+		var outcomes []NotificationOutcome
+		if err == nil {
+			outcomes = SendNotifications(findNewNotifications(origin, current))
+		}
+		o.mu.Lock()
+		o.notifications, o.notifyErr = outcomes, err
+		o.mu.Unlock()
+		onUpdate()
+	}
+}
+
+func runBulkStep(step *BulkStep) error {
+	switch step.Action {
+	case "disable":
+		return LpacProfileDisable(step.Profile.Iccid)
+	case "delete":
+		return LpacProfileDelete(step.Profile.Iccid)
+	case "nickname":
+		return LpacProfileNickname(step.Profile.Iccid, step.Nickname)
+	}
+	return errors.New("unknown bulk action: " + step.Action)
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBulkDelete(t *testing.T) {
+	fake := useFakeLpac(t)
+	fake.AddProfile(&Profile{Iccid: "8988303000000000028", ProfileState: "disabled", ServiceProviderName: "Lab", ProfileClass: "test"})
+	selected, err := LpacProfileList()
+	require.NoError(t, err)
+
+	_, err = PlanBulkDelete(selected, false)
+	assert.ErrorIs(t, err, ErrBulkEnabledProfile)
+	steps, err := PlanBulkDelete(selected, true)
+	require.NoError(t, err)
+	require.Len(t, steps, 4)
+	assert.Equal(t, "disable", steps[0].Action)
+
+	// 禁用失败时跳过这个 Profile 的删除，其他 Profile 照常删除
+	fake.Fail("profile disable", &FakeFailure{Function: "es10c_disable_profile", Data: "undefinedError"})
+	operation := NewBulkOperation(steps)
+	operation.Notify = true
+	operation.Run(context.Background(), nil)
+	result := operation.Steps()
+	assert.Equal(t, BatchFailed, result[0].Status)
+	assert.Equal(t, BatchFailed, result[1].Status)
+	assert.ErrorIs(t, result[1].Err, ErrBulkSkipped)
+	assert.Equal(t, BatchSucceeded, result[2].Status)
+	assert.Equal(t, BatchSucceeded, result[3].Status)
+	done, total := operation.Progress()
+	assert.Equal(t, 4, done)
+	assert.Equal(t, 4, total)
+
+	// 所有步骤结束后统一发送一次通知，delete 通知默认保留
+	outcomes, err := operation.Notifications()
+	require.NoError(t, err)
+	require.Len(t, outcomes, 2)
+	for _, outcome := range outcomes {
+		assert.Equal(t, "delete", outcome.Notification.ProfileManagementOperation)
+		assert.NoError(t, outcome.Err)
+		assert.False(t, outcome.Removed)
+	}
+	profiles, err := LpacProfileList()
+	require.NoError(t, err)
+	require.Len(t, profiles, 1)
+	assert.Equal(t, "8988303000000000002", profiles[0].Iccid)
+}
+
+func TestBulkNickname(t *testing.T) {
+	useFakeLpac(t)
+	selected, err := LpacProfileList()
+	require.NoError(t, err)
+	operation := NewBulkOperation(PlanBulkNickname(selected, "{provider} {iccid4}"))
+
+	// 取消后不再开始新的步骤
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	operation.Run(ctx, nil)
+	done, _ := operation.Progress()
+	assert.Zero(t, done)
+
+	operation.Run(context.Background(), nil)
+	profiles, err := LpacProfileList()
+	require.NoError(t, err)
+	require.NotNil(t, profiles[1].ProfileNickname)
+	assert.Equal(t, "Roaming 0010", *profiles[1].ProfileNickname)
+	outcomes, err := operation.Notifications()
+	assert.NoError(t, err)
+	assert.Empty(t, outcomes)
+}
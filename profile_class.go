@@ -0,0 +1,103 @@
+package main
+
+import "strconv"
+
+// SGP.22 中的 Profile 类型
+const (
+	ProfileClassOperational  = "operational"
+	ProfileClassTest         = "test"
+	ProfileClassProvisioning = "provisioning"
+)
+
+// ProfileGuard 是启用或删除 Profile 前需要向用户显示的警告
+type ProfileGuard struct {
+	// Warnings 是警告的翻译键
+	Warnings []string
+	// Strong 为 true 时需要输入 ConfirmCode 才能继续，ConfirmPrompt 是输入提示的翻译键
+	Strong        bool
+	ConfirmCode   string
+	ConfirmPrompt string
+}
+
+// isOperationalProfile 判断是否为普通 Profile，lpac 未输出类型时视为 operational
+func isOperationalProfile(p *Profile) bool {
+	return p.ProfileClass == ProfileClassOperational || p.ProfileClass == ""
+}
+
+// GuardProfileOperation 检查对 target 执行 operation（"enable" 或 "delete"）的风险
+// 测试 Profile 只显示警告；配置 Profile 和卡片上最后一个普通 Profile 需要输入 ICCID 末 4 位确认
+func GuardProfileOperation(operation string, target *Profile, profiles []*Profile) ProfileGuard {
+	var guard ProfileGuard
+	switch target.ProfileClass {
+	case ProfileClassTest:
+		guard.Warnings = append(guard.Warnings, "message.profile_guard_test_"+operation)
+	case ProfileClassProvisioning:
+		guard.Warnings = append(guard.Warnings, "message.profile_guard_provisioning_"+operation)
+		guard.Strong = true
+	}
+	if operation == "delete" && isOperationalProfile(target) {
+		last := true
+		for _, p := range profiles {
+			if p.Iccid != target.Iccid && isOperationalProfile(p) {
+				last = false
+				break
+			}
+		}
+		if last {
+			guard.Warnings = append(guard.Warnings, "message.profile_guard_last_operational")
+			guard.Strong = true
+		}
+	}
+	if guard.Strong {
+		guard.ConfirmCode = target.Iccid
+		if len(guard.ConfirmCode) > 4 {
+			guard.ConfirmCode = guard.ConfirmCode[len(guard.ConfirmCode)-4:]
+		}
+		guard.ConfirmPrompt = "message.profile_guard_type_code"
+	}
+	return guard
+}
+
+// GuardBulkDelete 检查一次删除 selected 中所有 Profile 的风险
+// 规则与 GuardProfileOperation 相同，需要确认时要求输入删除的数量
+func GuardBulkDelete(selected []*Profile, profiles []*Profile) ProfileGuard {
+	var guard ProfileGuard
+	var test, provisioning, operational bool
+	deleted := make(map[string]bool)
+	for _, p := range selected {
+		deleted[p.Iccid] = true
+		switch {
+		case p.ProfileClass == ProfileClassTest:
+			test = true
+		case p.ProfileClass == ProfileClassProvisioning:
+			provisioning = true
+		case isOperationalProfile(p):
+			operational = true
+		}
+	}
+	if test {
+		guard.Warnings = append(guard.Warnings, "message.profile_guard_bulk_test_delete")
+	}
+	if provisioning {
+		guard.Warnings = append(guard.Warnings, "message.profile_guard_bulk_provisioning_delete")
+		guard.Strong = true
+	}
+	if operational {
+		last := true
+		for _, p := range profiles {
+			if !deleted[p.Iccid] && isOperationalProfile(p) {
+				last = false
+				break
+			}
+		}
+		if last {
+			guard.Warnings = append(guard.Warnings, "message.profile_guard_bulk_last_operational")
+			guard.Strong = true
+		}
+	}
+	if guard.Strong {
+		guard.ConfirmCode = strconv.Itoa(len(selected))
+		guard.ConfirmPrompt = "message.profile_guard_type_count"
+	}
+	return guard
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGuardProfileOperation(t *testing.T) {
+	home := &Profile{Iccid: "8988303000000000002", ProfileClass: ProfileClassOperational}
+	roaming := &Profile{Iccid: "8988303000000000010", ProfileClass: ProfileClassOperational}
+	test := &Profile{Iccid: "8988303000000000028", ProfileClass: ProfileClassTest}
+	provisioning := &Profile{Iccid: "8944000000000000019", ProfileClass: ProfileClassProvisioning}
+	profiles := []*Profile{home, roaming, test, provisioning}
+
+	assert.Equal(t, ProfileGuard{}, GuardProfileOperation("enable", home, profiles))
+	assert.Equal(t, ProfileGuard{}, GuardProfileOperation("delete", home, profiles))
+
+	guard := GuardProfileOperation("enable", test, profiles)
+	assert.Equal(t, []string{"message.profile_guard_test_enable"}, guard.Warnings)
+	assert.False(t, guard.Strong)
+
+	guard = GuardProfileOperation("delete", provisioning, profiles)
+	assert.Equal(t, []string{"message.profile_guard_provisioning_delete"}, guard.Warnings)
+	assert.True(t, guard.Strong)
+	assert.Equal(t, "0019", guard.ConfirmCode)
+
+	// 测试和配置 Profile 不算普通 Profile
+	guard = GuardProfileOperation("delete", home, []*Profile{home, test, provisioning})
+	assert.Equal(t, []string{"message.profile_guard_last_operational"}, guard.Warnings)
+	assert.True(t, guard.Strong)
+	assert.Equal(t, "0002", guard.ConfirmCode)
+	// 没有类型的 Profile 视为普通 Profile
+	guard = GuardProfileOperation("delete", home, []*Profile{home, {Iccid: "8901260123456789011"}})
+	assert.False(t, guard.Strong)
+}
+
+func TestGuardBulkDelete(t *testing.T) {
+	home := &Profile{Iccid: "8988303000000000002", ProfileClass: ProfileClassOperational}
+	roaming := &Profile{Iccid: "8988303000000000010", ProfileClass: ProfileClassOperational}
+	test := &Profile{Iccid: "8988303000000000028", ProfileClass: ProfileClassTest}
+	profiles := []*Profile{home, roaming, test}
+
+	guard := GuardBulkDelete([]*Profile{roaming, test}, profiles)
+	assert.Equal(t, []string{"message.profile_guard_bulk_test_delete"}, guard.Warnings)
+	assert.False(t, guard.Strong)
+
+	guard = GuardBulkDelete([]*Profile{home, roaming}, profiles)
+	assert.Equal(t, []string{"message.profile_guard_bulk_last_operational"}, guard.Warnings)
+	assert.True(t, guard.Strong)
+	assert.Equal(t, "2", guard.ConfirmCode)
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"cmp"
+	"slices"
+	"strings"
+)
+
+// ProfileSortKey 是 Profile 列表的排序方式，空字符串表示卡片上的顺序
+type ProfileSortKey string
+
+const (
+	ProfileSortCard     ProfileSortKey = ""
+	ProfileSortProvider ProfileSortKey = "provider"
+	ProfileSortNickname ProfileSortKey = "nickname"
+	ProfileSortIccid    ProfileSortKey = "iccid"
+)
+
+// ProfileFilter 按关键字、状态和类型筛选 Profile 并排序
+type ProfileFilter struct {
+	// Query 不区分大小写，匹配 ICCID、运营商、Profile 名称和昵称
+	Query string
+	// State 和 Class 为空时不筛选
+	State  string
+	Class  string
+	SortBy ProfileSortKey
+	// HideTest 隐藏测试 Profile，Class 选择了 test 时不生效
+	HideTest bool
+}
+
+// Apply 返回筛选和排序后的 Profile，不修改 profiles
+func (f ProfileFilter) Apply(profiles []*Profile) []*Profile {
+	query := strings.ToLower(strings.TrimSpace(f.Query))
+	var visible []*Profile
+	for _, p := range profiles {
+		if f.State != "" && p.ProfileState != f.State {
+			continue
+		}
+		if f.Class != "" && p.ProfileClass != f.Class {
+			continue
+		}
+		if f.HideTest && f.Class != ProfileClassTest && p.ProfileClass == ProfileClassTest {
+			continue
+		}
+		if query != "" && !profileMatches(p, query) {
+			continue
+		}
+		visible = append(visible, p)
+	}
+	if f.SortBy != ProfileSortCard {
+		slices.SortStableFunc(visible, func(a, b *Profile) int {
+			return cmp.Compare(f.sortValue(a), f.sortValue(b))
+		})
+	}
+	return visible
+}
+
+// Active 判断是否设置了任何筛选条件
+func (f ProfileFilter) Active() bool {
+	return strings.TrimSpace(f.Query) != "" || f.State != "" || f.Class != ""
+}
+
+func (f ProfileFilter) sortValue(p *Profile) string {
+	switch f.SortBy {
+	case ProfileSortProvider:
+		return strings.ToLower(p.ServiceProviderName)
+	case ProfileSortNickname:
+		// 没有昵称时按 Profile 名称排序
+		if p.ProfileNickname != nil && *p.ProfileNickname != "" {
+			return strings.ToLower(*p.ProfileNickname)
+		}
+		return strings.ToLower(p.ProfileName)
+	default:
+		return p.Iccid
+	}
+}
+
+func profileMatches(p *Profile, query string) bool {
+	fields := []string{p.Iccid, p.ServiceProviderName, p.ProfileName}
+	if p.ProfileNickname != nil {
+		fields = append(fields, *p.ProfileNickname)
+	}
+	for _, field := range fields {
+		if strings.Contains(strings.ToLower(field), query) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProfileFilter(t *testing.T) {
+	work, travel := "Work", "travel data"
+	profiles := []*Profile{
+		{Iccid: "8988303000000000028", ProfileState: "disabled", ServiceProviderName: "Zeta", ProfileName: "Zeta Test", ProfileClass: "test"},
+		{Iccid: "8988303000000000002", ProfileState: "enabled", ServiceProviderName: "Home", ProfileName: "Home", ProfileClass: "operational", ProfileNickname: &work},
+		{Iccid: "8988303000000000010", ProfileState: "disabled", ServiceProviderName: "Alpha", ProfileName: "Alpha Roam", ProfileClass: "operational", ProfileNickname: &travel},
+	}
+	iccids := func(ps []*Profile) []string {
+		var result []string
+		for _, p := range ps {
+			result = append(result, p.Iccid)
+		}
+		return result
+	}
+
+	assert.Equal(t, iccids(profiles), iccids(ProfileFilter{}.Apply(profiles)))
+	assert.False(t, ProfileFilter{SortBy: ProfileSortIccid}.Active())
+
+	assert.Equal(t, []string{"8988303000000000010"}, iccids(ProfileFilter{Query: "TRAVEL"}.Apply(profiles)))
+	assert.Equal(t, []string{"8988303000000000028"}, iccids(ProfileFilter{Query: "0028"}.Apply(profiles)))
+	assert.Equal(t, []string{"8988303000000000028"}, iccids(ProfileFilter{Query: "zeta t"}.Apply(profiles)))
+	assert.Empty(t, ProfileFilter{Query: "missing"}.Apply(profiles))
+
+	assert.Equal(t, []string{"8988303000000000028", "8988303000000000010"},
+		iccids(ProfileFilter{State: "disabled"}.Apply(profiles)))
+	assert.Equal(t, []string{"8988303000000000010"},
+		iccids(ProfileFilter{State: "disabled", Class: "operational"}.Apply(profiles)))
+
+	assert.Equal(t, []string{"8988303000000000010", "8988303000000000002", "8988303000000000028"},
+		iccids(ProfileFilter{SortBy: ProfileSortProvider}.Apply(profiles)))
+	assert.Equal(t, []string{"8988303000000000010", "8988303000000000002", "8988303000000000028"},
+		iccids(ProfileFilter{SortBy: ProfileSortNickname}.Apply(profiles)))
+	assert.Equal(t, []string{"8988303000000000002", "8988303000000000010", "8988303000000000028"},
+		iccids(ProfileFilter{SortBy: ProfileSortIccid}.Apply(profiles)))
+	assert.Equal(t, []string{"8988303000000000002", "8988303000000000010"},
+		iccids(ProfileFilter{HideTest: true}.Apply(profiles)))
+	assert.Equal(t, []string{"8988303000000000028"},
+		iccids(ProfileFilter{HideTest: true, Class: "test"}.Apply(profiles)))
+	// 原列表不受排序影响
+	assert.Equal(t, "8988303000000000028", profiles[0].Iccid)
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/jpeg"
+	_ "image/png"
+	"strings"
+)
+
+// SGP.22 限制图标不超过 1024 字节，部分卡片超出，这里放宽但仍然限制大小
+const (
+	maxProfileIconBytes     = 16 * 1024
+	maxProfileIconDimension = 256
+)
+
+// DecodeProfileIcon 检查并解码 Profile 的 PNG 或 JPEG 图标
+// 类型与内容不符、尺寸过大或无法解码时返回错误，调用方应显示默认图标
+func DecodeProfileIcon(iconType string, data []byte) (image.Image, error) {
+	if len(data) == 0 {
+		return nil, errors.New("no icon")
+	}
+	if len(data) > maxProfileIconBytes {
+		return nil, fmt.Errorf("icon too large: %d bytes", len(data))
+	}
+	config, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	switch strings.ToLower(iconType) {
+	case "", "unknown", format:
+	case "jpg":
+		if format != "jpeg" {
+			return nil, fmt.Errorf("icon type %s does not match %s data", iconType, format)
+		}
+	default:
+		return nil, fmt.Errorf("icon type %s does not match %s data", iconType, format)
+	}
+	if config.Width <= 0 || config.Height <= 0 ||
+		config.Width > maxProfileIconDimension || config.Height > maxProfileIconDimension {
+		return nil, fmt.Errorf("invalid icon size: %dx%d", config.Width, config.Height)
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	return img, nil
+}
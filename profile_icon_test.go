@@ -0,0 +1,51 @@
+package main
+
+import (
+	"bytes"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func encodeTestIcon(t *testing.T, format string, width, height int) []byte {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	var buf bytes.Buffer
+	if format == "png" {
+		require.NoError(t, png.Encode(&buf, img))
+	} else {
+		require.NoError(t, jpeg.Encode(&buf, img, nil))
+	}
+	return buf.Bytes()
+}
+
+func TestDecodeProfileIcon(t *testing.T) {
+	pngIcon := encodeTestIcon(t, "png", 32, 32)
+	jpegIcon := encodeTestIcon(t, "jpeg", 16, 16)
+
+	img, err := DecodeProfileIcon("png", pngIcon)
+	require.NoError(t, err)
+	assert.Equal(t, 32, img.Bounds().Dx())
+	_, err = DecodeProfileIcon("jpg", jpegIcon)
+	assert.NoError(t, err)
+	_, err = DecodeProfileIcon("", jpegIcon)
+	assert.NoError(t, err)
+
+	for name, c := range map[string]struct {
+		iconType string
+		data     []byte
+	}{
+		"empty":      {"png", nil},
+		"garbage":    {"png", []byte("not an image")},
+		"truncated":  {"png", pngIcon[:len(pngIcon)/2]},
+		"mismatch":   {"jpg", pngIcon},
+		"too large":  {"png", append(bytes.Clone(pngIcon), make([]byte, maxProfileIconBytes)...)},
+		"dimensions": {"png", encodeTestIcon(t, "png", 4096, 1)},
+	} {
+		_, err := DecodeProfileIcon(c.iconType, c.data)
+		assert.Error(t, err, name)
+	}
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// SwitchStage 是安全切换所处的阶段
+type SwitchStage int
+
+const (
+	SwitchEnabling SwitchStage = iota
+	SwitchVerifying
+	SwitchWaitingConfirm
+	SwitchRollingBack
+	SwitchFinished
+)
+
+// ErrSwitchVerification 表示切换后卡片上启用的 Profile 与预期不符
+var ErrSwitchVerification = errors.New("enabled profile does not match")
+
+// ErrSwitchNotConfirmed 表示在等待时间内没有确认切换
+var ErrSwitchNotConfirmed = errors.New("switch was not confirmed in time")
+
+// SafeSwitch 启用 Target 后通过 profile list 验证卡片状态
+// 切换失败、验证失败或没有在 GracePeriod 内确认时，恢复切换前启用的 Profile
+type SafeSwitch struct {
+	Target string
+	// Previous 是切换前启用的 Profile，为空时回滚会禁用 Target
+	Previous string
+	// GracePeriod 大于 0 时，验证通过后等待 Confirm，超时或 ctx 取消都会回滚
+	GracePeriod time.Duration
+	// Notify 为 true 时在结束后按通知策略处理切换和回滚产生的所有通知
+	Notify bool
+	// OnStage 在进入每个阶段时调用
+	OnStage func(SwitchStage)
+
+	confirmed chan struct{}
+	once      sync.Once
+}
+
+// SwitchResult 是安全切换的结果
+type SwitchResult struct {
+	// Err 是切换失败的原因，为 nil 时 Target 已启用
+	Err error
+	// RolledBack 表示切换失败后卡片已恢复到切换前的状态
+	RolledBack    bool
+	RollbackErr   error
+	Notifications []NotificationOutcome
+}
+
+// NewSafeSwitch 记录 profiles 中当前启用的 Profile 作为回滚目标
+func NewSafeSwitch(target string, profiles []*Profile) *SafeSwitch {
+	s := &SafeSwitch{Target: target, confirmed: make(chan struct{})}
+	for _, p := range profiles {
+		if p.ProfileState == "enabled" {
+			s.Previous = p.Iccid
+		}
+	}
+	return s
+}
+
+// Confirm 确认切换成功，可以在任意 goroutine 中调用
+func (s *SafeSwitch) Confirm() {
+	s.once.Do(func() { close(s.confirmed) })
+}
+
+func (s *SafeSwitch) Run(ctx context.Context) SwitchResult {
+	defer CardJobs.Hold()()
+	stage := func(stage SwitchStage) {
+		if s.OnStage != nil {
+			s.OnStage(stage)
+		}
+	}
+	var result SwitchResult
+	// 通知在最终状态确定后统一发送，回滚时 SM-DP+ 也能收到完整的记录
+	origin, notifyErr := []*Notification(nil), error(nil)
+	if s.Notify {
+		origin, notifyErr = LpacNotificationList()
+	}
+
+	stage(SwitchEnabling)
+	err := LpacProfileEnable(s.Target)
+	stage(SwitchVerifying)
+	// lpac 报错时卡片状态也可能已经改变，同样需要验证
+	if verifyErr := verifyEnabledProfile(s.Target); err == nil {
+		err = verifyErr
+	}
+	if err == nil && s.GracePeriod > 0 {
+		stage(SwitchWaitingConfirm)
+		err = s.waitConfirm(ctx)
+	}
+	if err != nil {
+		result.Err = err
+		stage(SwitchRollingBack)
+		result.RollbackErr = s.rollback()
+		result.RolledBack = result.RollbackErr == nil
+	}
+
+	if s.Notify && notifyErr == nil {
+		if current, err := LpacNotificationList(); err == nil {
+			result.Notifications = SendNotifications(findNewNotifications(origin, current))
+		}
+	}
+	stage(SwitchFinished)
+	return result
+}
+
+func (s *SafeSwitch) waitConfirm(ctx context.Context) error {
+	timer := time.NewTimer(s.GracePeriod)
+	defer timer.Stop()
+	select {
+	case <-s.confirmed:
+		return nil
+	case <-timer.C:
+		return ErrSwitchNotConfirmed
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// rollback 恢复切换前的状态，已经是切换前的状态时不做任何操作
+func (s *SafeSwitch) rollback() error {
+	if verifyEnabledProfile(s.Previous) == nil {
+		return nil
+	}
+	var err error
+	if s.Previous != "" {
+		err = LpacProfileEnable(s.Previous)
+	} else {
+		err = LpacProfileDisable(s.Target)
+	}
+	if err != nil {
+		return err
+	}
+	return verifyEnabledProfile(s.Previous)
+}
+
+// verifyEnabledProfile 检查卡片上只有 iccid 处于启用状态，iccid 为空时检查没有启用的 Profile
+func verifyEnabledProfile(iccid string) error {
+	profiles, err := LpacProfileList()
+	if err != nil {
+		return err
+	}
+	var enabled []string
+	for _, p := range profiles {
+		if p.ProfileState == "enabled" {
+			enabled = append(enabled, p.Iccid)
+		}
+	}
+	if (iccid == "" && len(enabled) == 0) || (len(enabled) == 1 && enabled[0] == iccid) {
+		return nil
+	}
+	return fmt.Errorf("%w: expected %q, enabled %q", ErrSwitchVerification, iccid, enabled)
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func enabledIccids(t *testing.T) []string {
+	profiles, err := LpacProfileList()
+	require.NoError(t, err)
+	var enabled []string
+	for _, p := range profiles {
+		if p.ProfileState == "enabled" {
+			enabled = append(enabled, p.Iccid)
+		}
+	}
+	return enabled
+}
+
+func TestSafeSwitch(t *testing.T) {
+	fake := useFakeLpac(t)
+	profiles, err := LpacProfileList()
+	require.NoError(t, err)
+
+	s := NewSafeSwitch("8988303000000000010", profiles)
+	assert.Equal(t, "8988303000000000002", s.Previous)
+	s.Notify = true
+	var stages []SwitchStage
+	s.OnStage = func(stage SwitchStage) { stages = append(stages, stage) }
+	result := s.Run(context.Background())
+	require.NoError(t, result.Err)
+	assert.False(t, result.RolledBack)
+	assert.Equal(t, []SwitchStage{SwitchEnabling, SwitchVerifying, SwitchFinished}, stages)
+	assert.Equal(t, []string{"8988303000000000010"}, enabledIccids(t))
+	assert.Len(t, result.Notifications, 2)
+	assert.Empty(t, fake.Notifications)
+
+	// 确认后不回滚
+	profiles, _ = LpacProfileList()
+	s = NewSafeSwitch("8988303000000000002", profiles)
+	s.GracePeriod = time.Minute
+	s.OnStage = func(stage SwitchStage) {
+		if stage == SwitchWaitingConfirm {
+			go s.Confirm()
+		}
+	}
+	result = s.Run(context.Background())
+	require.NoError(t, result.Err)
+	assert.Equal(t, []string{"8988303000000000002"}, enabledIccids(t))
+}
+
+func TestSafeSwitchRollback(t *testing.T) {
+	fake := useFakeLpac(t)
+	profiles, err := LpacProfileList()
+	require.NoError(t, err)
+
+	// 等待时间内没有确认，重新启用原来的 Profile
+	s := NewSafeSwitch("8988303000000000010", profiles)
+	s.GracePeriod = 10 * time.Millisecond
+	result := s.Run(context.Background())
+	assert.ErrorIs(t, result.Err, ErrSwitchNotConfirmed)
+	assert.True(t, result.RolledBack)
+	assert.Equal(t, []string{"8988303000000000002"}, enabledIccids(t))
+
+	// 切换失败时卡片状态没有改变，不需要额外操作
+	fake.Fail("profile enable", &FakeFailure{Function: "es10c_enable_profile", Data: "catBusy"})
+	calls := len(fake.Calls)
+	result = s.Run(context.Background())
+	_, ok := AsLpacError(result.Err)
+	assert.True(t, ok)
+	assert.True(t, result.RolledBack)
+	assert.Equal(t, []string{"8988303000000000002"}, enabledIccids(t))
+	var enables int
+	for _, call := range fake.Calls[calls:] {
+		if strings.HasPrefix(strings.Join(call, " "), "profile enable") {
+			enables++
+		}
+	}
+	assert.Equal(t, 1, enables)
+	fake.ClearFailures()
+
+	// 切换前没有启用的 Profile 时，回滚会禁用新启用的 Profile
+	require.NoError(t, LpacProfileDisable("8988303000000000002"))
+	profiles, _ = LpacProfileList()
+	s = NewSafeSwitch("8988303000000000010", profiles)
+	assert.Empty(t, s.Previous)
+	s.GracePeriod = time.Minute
+	ctx, cancel := context.WithCancel(context.Background())
+	s.OnStage = func(stage SwitchStage) {
+		if stage == SwitchWaitingConfirm {
+			cancel()
+		}
+	}
+	result = s.Run(ctx)
+	assert.ErrorIs(t, result.Err, context.Canceled)
+	assert.True(t, result.RolledBack)
+	assert.Empty(t, enabledIccids(t))
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ProgressStage 是 lpac 进度步骤所属的阶段
+type ProgressStage int
+
+const (
+	StageUnknown ProgressStage = iota
+	StageAuthenticate
+	StageDownload
+	StageInstall
+	StageCancel
+	StageNotification
+)
+
+// DownloadStages 是下载 Profile 时依次经过的阶段
+var DownloadStages = []ProgressStage{StageAuthenticate, StageDownload, StageInstall}
+
+var progressStages = map[string]ProgressStage{
+	"es10b_get_euicc_challenge_and_info":  StageAuthenticate,
+	"es9p_initiate_authentication":        StageAuthenticate,
+	"es10b_authenticate_server":           StageAuthenticate,
+	"es9p_authenticate_client":            StageAuthenticate,
+	"es11_authenticate_client":            StageAuthenticate,
+	"es10b_prepare_download":              StageDownload,
+	"es9p_get_bound_profile_package":      StageDownload,
+	"es8p_meatadata_parse":                StageInstall,
+	"es10b_load_bound_profile_package":    StageInstall,
+	"es10b_cancel_session":                StageCancel,
+	"es9p_cancel_session":                 StageCancel,
+	"es10b_retrieve_notifications_list":   StageNotification,
+	"es9p_handle_notification":            StageNotification,
+	"es10b_remove_notification_from_list": StageNotification,
+}
+
+// ID 返回 API 中使用的阶段名
+func (s ProgressStage) ID() string {
+	switch s {
+	case StageAuthenticate:
+		return "authenticate"
+	case StageDownload:
+		return "download"
+	case StageInstall:
+		return "install"
+	case StageCancel:
+		return "cancel"
+	case StageNotification:
+		return "notification"
+	default:
+		return ""
+	}
+}
+
+func (s ProgressStage) Name() string {
+	switch s {
+	case StageAuthenticate:
+		return TR.Trans("label.progress_stage_authenticate")
+	case StageDownload:
+		return TR.Trans("label.progress_stage_download")
+	case StageInstall:
+		return TR.Trans("label.progress_stage_install")
+	case StageCancel:
+		return TR.Trans("label.progress_stage_cancel")
+	case StageNotification:
+		return TR.Trans("label.progress_stage_notification")
+	default:
+		return TR.Trans("label.status_processing")
+	}
+}
+
+// LpacProgress 是 lpac 输出的一条 progress 消息
+type LpacProgress struct {
+	JobID   int
+	Command string // 如 "profile download"
+	Step    string // lpac 输出的步骤名，如 es9p_initiate_authentication
+	Stage   ProgressStage
+	Elapsed time.Duration // 自命令开始计时
+	Done    bool          // 命令已结束
+}
+
+// Party 返回当前步骤的通信对象，用于区分网络问题和卡片问题
+func (p LpacProgress) Party() string {
+	switch {
+	case strings.HasPrefix(p.Step, "es9p_"), strings.HasPrefix(p.Step, "es11_"):
+		return TR.Trans("label.progress_party_smdp")
+	case strings.HasPrefix(p.Step, "es10"):
+		return TR.Trans("label.progress_party_euicc")
+	default:
+		return ""
+	}
+}
+
+func (p LpacProgress) String() string {
+	text := p.Stage.Name()
+	if party := p.Party(); party != "" {
+		text += " · " + party
+	}
+	return fmt.Sprintf("%s %s", text, formatElapsed(p.Elapsed))
+}
+
+// ProgressChan 接收 runLpac 发布的进度，没有监听者时丢弃
+var ProgressChan = make(chan LpacProgress, 32)
+
+func publishProgress(p LpacProgress) {
+	select {
+	case ProgressChan <- p:
+	default:
+	}
+	Events.Publish(Event{Type: "progress", Progress: p.View()})
+}
+
+// View 返回发布给 API 客户端的进度
+func (p LpacProgress) View() *ProgressView {
+	v := &ProgressView{
+		JobID:   p.JobID,
+		Command: p.Command,
+		Step:    p.Step,
+		Elapsed: p.Elapsed.Round(time.Millisecond).String(),
+		Done:    p.Done,
+	}
+	if p.Stage != StageUnknown {
+		v.Stage = p.Stage.ID()
+	}
+	return v
+}
+
+// progressWriter 在 lpac 输出的同时逐行解析 progress 消息
+// 所有输出都会保留下来，供 parseLpacOutput 解析最终结果
+type progressWriter struct {
+	jobID   int
+	command string
+	start   time.Time
+	output  bytes.Buffer
+	line    []byte
+	// reported 表示已经发布过进度，命令结束时需要发布 Done
+	reported bool
+}
+
+func newProgressWriter(job *Job) *progressWriter {
+	return &progressWriter{
+		jobID:   job.ID,
+		command: job.Command(),
+		start:   time.Now(),
+	}
+}
+
+func (w *progressWriter) Write(p []byte) (int, error) {
+	w.output.Write(p)
+	for _, b := range p {
+		if b != '\n' {
+			w.line = append(w.line, b)
+			continue
+		}
+		w.handleLine(w.line)
+		w.line = w.line[:0]
+	}
+	return len(p), nil
+}
+
+func (w *progressWriter) handleLine(line []byte) {
+	var resp LpacReturnValue
+	if err := json.Unmarshal(line, &resp); err != nil || resp.Type != "progress" {
+		return
+	}
+	w.reported = true
+	publishProgress(LpacProgress{
+		JobID:   w.jobID,
+		Command: w.command,
+		Step:    resp.Payload.Message,
+		Stage:   progressStages[resp.Payload.Message],
+		Elapsed: time.Since(w.start),
+	})
+}
+
+func (w *progressWriter) Close() {
+	if w.reported {
+		publishProgress(LpacProgress{JobID: w.jobID, Command: w.command, Elapsed: time.Since(w.start), Done: true})
+	}
+}
+
+func formatElapsed(d time.Duration) string {
+	d = d.Round(time.Second)
+	return fmt.Sprintf("%02d:%02d", int(d.Minutes()), int(d.Seconds())%60)
+}
@@ -0,0 +1,283 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ReportFormats 是 Report.Write 支持的格式，同时也是导出文件的扩展名
+var ReportFormats = []string{"json", "csv", "md", "html"}
+
+// Report 是芯片、Profile 和通知的清单，用于附在问题报告中
+type Report struct {
+	Generated          time.Time            `json:"generated"`
+	Version            string               `json:"easylpac_version"`
+	Reader             string               `json:"reader,omitempty"`
+	Masked             bool                 `json:"masked"`
+	EID                string               `json:"eid"`
+	Manufacturer       string               `json:"manufacturer,omitempty"`
+	Product            string               `json:"product,omitempty"`
+	Country            string               `json:"country,omitempty"`
+	DefaultSmdp        string               `json:"default_smdp,omitempty"`
+	RootSmds           string               `json:"root_smds,omitempty"`
+	SGP22Version       string               `json:"sgp22_version,omitempty"`
+	FirmwareVersion    string               `json:"firmware_version,omitempty"`
+	FreeMemory         int                  `json:"free_nonvolatile_memory"`
+	CertificateIssuers []ReportIssuer       `json:"certificate_issuers"`
+	Profiles           []ReportProfile      `json:"profiles"`
+	Notifications      []ReportNotification `json:"notifications"`
+}
+
+type ReportIssuer struct {
+	KeyID   string `json:"key_id"`
+	Name    string `json:"name,omitempty"`
+	Country string `json:"country,omitempty"`
+}
+
+type ReportProfile struct {
+	Iccid    string `json:"iccid"`
+	State    string `json:"state"`
+	Class    string `json:"class"`
+	Provider string `json:"provider"`
+	Name     string `json:"name"`
+	Nickname string `json:"nickname,omitempty"`
+}
+
+type ReportNotification struct {
+	SeqNumber int    `json:"seq"`
+	Operation string `json:"operation"`
+	Address   string `json:"address"`
+	Iccid     string `json:"iccid"`
+}
+
+// CollectReport 从当前卡片读取生成报告所需的信息
+func CollectReport() (*Report, error) {
+	chip, err := LpacChipInfo()
+	if err != nil {
+		return nil, err
+	}
+	profiles, err := LpacProfileList()
+	if err != nil {
+		return nil, err
+	}
+	notifications, err := LpacNotificationList()
+	if err != nil {
+		return nil, err
+	}
+	return NewReport(chip, profiles, notifications), nil
+}
+
+func NewReport(chip *EuiccInfo, profiles []*Profile, notifications []*Notification) *Report {
+	r := &Report{
+		Generated:     time.Now(),
+		Version:       Version,
+		Reader:        ConfigInstance.ReaderName,
+		Profiles:      []ReportProfile{},
+		Notifications: []ReportNotification{},
+	}
+	if chip != nil {
+		r.EID = chip.EidValue
+		if eum := GetEUM(chip.EidValue); eum != nil {
+			r.Manufacturer, r.Country, r.Product = eum.Manufacturer, eum.Country, eum.ProductName(chip.EidValue)
+		}
+		if chip.EuiccConfiguredAddresses.DefaultDpAddress != nil {
+			r.DefaultSmdp = fmt.Sprint(chip.EuiccConfiguredAddresses.DefaultDpAddress)
+		}
+		r.RootSmds = chip.EuiccConfiguredAddresses.RootDsAddress
+		r.SGP22Version = chip.EUICCInfo2.ProfileVersion
+		r.FirmwareVersion = chip.EUICCInfo2.EuiccFirmwareVer
+		r.FreeMemory = chip.EUICCInfo2.ExtCardResource.FreeNonVolatileMemory
+		// 与证书信息窗口一致，signing 和 verification 同时存在的 CI 才有效
+		for _, keyID := range chip.EUICCInfo2.EuiccCiPKIDListForSigning {
+			if !slices.Contains(chip.EUICCInfo2.EuiccCiPKIDListForVerification, keyID) {
+				continue
+			}
+			issuer := ReportIssuer{KeyID: keyID}
+			if ci := GetIssuer(keyID); ci != nil {
+				issuer.Name, issuer.Country = ci.Name, ci.Country
+			}
+			r.CertificateIssuers = append(r.CertificateIssuers, issuer)
+		}
+	}
+	for _, p := range profiles {
+		profile := ReportProfile{Iccid: p.Iccid, State: p.ProfileState, Class: p.ProfileClass,
+			Provider: p.ServiceProviderName, Name: p.ProfileName}
+		if p.ProfileNickname != nil {
+			profile.Nickname = *p.ProfileNickname
+		}
+		r.Profiles = append(r.Profiles, profile)
+	}
+	for _, n := range notifications {
+		r.Notifications = append(r.Notifications, ReportNotification{SeqNumber: n.SeqNumber,
+			Operation: n.ProfileManagementOperation, Address: n.NotificationAddress, Iccid: n.Iccid})
+	}
+	slices.SortFunc(r.Notifications, func(a, b ReportNotification) int { return a.SeqNumber - b.SeqNumber })
+	return r
+}
+
+// Mask 按 MaskedICCID 的规则隐藏 EID 和 ICCID
+func (r *Report) Mask() {
+	r.Masked = true
+	r.EID = maskIdentifier(r.EID)
+	for i := range r.Profiles {
+		r.Profiles[i].Iccid = maskIdentifier(r.Profiles[i].Iccid)
+	}
+	for i := range r.Notifications {
+		r.Notifications[i].Iccid = maskIdentifier(r.Notifications[i].Iccid)
+	}
+}
+
+// SelectProfiles 只保留指定 ICCID 的 Profile 和通知，需要在 Mask 之前调用
+func (r *Report) SelectProfiles(iccids []string) {
+	r.Profiles = slices.DeleteFunc(r.Profiles, func(p ReportProfile) bool {
+		return !slices.Contains(iccids, p.Iccid)
+	})
+	r.Notifications = slices.DeleteFunc(r.Notifications, func(n ReportNotification) bool {
+		return !slices.Contains(iccids, n.Iccid)
+	})
+}
+
+// Write 以 ReportFormats 中的格式输出报告
+// CSV 每行是一个 Profile 或通知，芯片信息只保留 EID 以便合并多张卡片的报告
+func (r *Report) Write(w io.Writer, format string) error {
+	switch format {
+	case "json":
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(r)
+	case "csv":
+		return r.writeCSV(w)
+	case "md":
+		return r.writeMarkdown(w)
+	case "html":
+		return reportTemplate.Execute(w, r)
+	default:
+		return fmt.Errorf("unsupported report format: %s", format)
+	}
+}
+
+func (r *Report) writeCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	_ = cw.Write([]string{"type", "eid", "iccid", "state", "class", "provider", "name", "nickname", "seq", "operation", "address"})
+	for _, p := range r.Profiles {
+		_ = cw.Write([]string{"profile", r.EID, p.Iccid, p.State, p.Class, p.Provider, p.Name, p.Nickname, "", "", ""})
+	}
+	for _, n := range r.Notifications {
+		_ = cw.Write([]string{"notification", r.EID, n.Iccid, "", "", "", "", "", strconv.Itoa(n.SeqNumber), n.Operation, n.Address})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func (r *Report) writeMarkdown(w io.Writer) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# eUICC Report\n\n")
+	fmt.Fprintf(&b, "Generated by EasyLPAC %s at %s", r.Version, r.Generated.Format(time.RFC3339))
+	if r.Masked {
+		b.WriteString(" (identifiers masked)")
+	}
+	b.WriteString("\n\n## Chip\n\n| Field | Value |\n| --- | --- |\n")
+	for _, row := range r.ChipRows() {
+		fmt.Fprintf(&b, "| %s | %s |\n", row[0], markdownCell(row[1]))
+	}
+	b.WriteString("\n## Certificate Issuers\n\n")
+	if len(r.CertificateIssuers) == 0 {
+		b.WriteString("None\n")
+	} else {
+		b.WriteString("| Key ID | Name | Country |\n| --- | --- | --- |\n")
+		for _, ci := range r.CertificateIssuers {
+			fmt.Fprintf(&b, "| %s | %s | %s |\n", ci.KeyID, markdownCell(ci.Name), ci.Country)
+		}
+	}
+	b.WriteString("\n## Profiles\n\n")
+	if len(r.Profiles) == 0 {
+		b.WriteString("None\n")
+	} else {
+		b.WriteString("| ICCID | State | Class | Provider | Name | Nickname |\n| --- | --- | --- | --- | --- | --- |\n")
+		for _, p := range r.Profiles {
+			fmt.Fprintf(&b, "| %s | %s | %s | %s | %s | %s |\n", p.Iccid, p.State, p.Class,
+				markdownCell(p.Provider), markdownCell(p.Name), markdownCell(p.Nickname))
+		}
+	}
+	b.WriteString("\n## Pending Notifications\n\n")
+	if len(r.Notifications) == 0 {
+		b.WriteString("None\n")
+	} else {
+		b.WriteString("| Seq | Operation | Address | ICCID |\n| --- | --- | --- | --- |\n")
+		for _, n := range r.Notifications {
+			fmt.Fprintf(&b, "| %d | %s | %s | %s |\n", n.SeqNumber, n.Operation, markdownCell(n.Address), n.Iccid)
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// ChipRows 返回 Markdown 和 HTML 报告中芯片信息表格的内容
+func (r *Report) ChipRows() [][2]string {
+	manufacturer := r.Manufacturer
+	if r.Product != "" {
+		manufacturer = strings.TrimSpace(manufacturer + " " + r.Product)
+	}
+	if r.Country != "" {
+		manufacturer += " (" + r.Country + ")"
+	}
+	return [][2]string{
+		{"Reader", r.Reader},
+		{"EID", r.EID},
+		{"Manufacturer", manufacturer},
+		{"Default SM-DP+", r.DefaultSmdp},
+		{"Root SM-DS", r.RootSmds},
+		{"SGP.22 version", r.SGP22Version},
+		{"Firmware version", r.FirmwareVersion},
+		{"Free memory", fmt.Sprintf("%.2f KiB", float64(r.FreeMemory)/1024)},
+	}
+}
+
+func markdownCell(s string) string {
+	return strings.NewReplacer("|", `\|`, "\n", " ").Replace(s)
+}
+
+var reportTemplate = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>eUICC Report {{.EID}}</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; margin-bottom: 1.5em; }
+th, td { border: 1px solid #ccc; padding: 4px 8px; text-align: left; }
+th { background: #f0f0f0; }
+</style>
+</head>
+<body>
+<h1>eUICC Report</h1>
+<p>Generated by EasyLPAC {{.Version}} at {{.Generated.Format "2006-01-02T15:04:05Z07:00"}}{{if .Masked}} (identifiers masked){{end}}</p>
+<h2>Chip</h2>
+<table>
+{{range .ChipRows}}<tr><th>{{index . 0}}</th><td>{{index . 1}}</td></tr>
+{{end}}</table>
+<h2>Certificate Issuers</h2>
+{{if .CertificateIssuers}}<table>
+<tr><th>Key ID</th><th>Name</th><th>Country</th></tr>
+{{range .CertificateIssuers}}<tr><td>{{.KeyID}}</td><td>{{.Name}}</td><td>{{.Country}}</td></tr>
+{{end}}</table>{{else}}<p>None</p>{{end}}
+<h2>Profiles</h2>
+{{if .Profiles}}<table>
+<tr><th>ICCID</th><th>State</th><th>Class</th><th>Provider</th><th>Name</th><th>Nickname</th></tr>
+{{range .Profiles}}<tr><td>{{.Iccid}}</td><td>{{.State}}</td><td>{{.Class}}</td><td>{{.Provider}}</td><td>{{.Name}}</td><td>{{.Nickname}}</td></tr>
+{{end}}</table>{{else}}<p>None</p>{{end}}
+<h2>Pending Notifications</h2>
+{{if .Notifications}}<table>
+<tr><th>Seq</th><th>Operation</th><th>Address</th><th>ICCID</th></tr>
+{{range .Notifications}}<tr><td>{{.SeqNumber}}</td><td>{{.Operation}}</td><td>{{.Address}}</td><td>{{.Iccid}}</td></tr>
+{{end}}</table>{{else}}<p>None</p>{{end}}
+</body>
+</html>
+`))
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReport(t *testing.T) {
+	fake := useFakeLpac(t)
+	nickname := "Work | <b>SIM</b>"
+	fake.Profiles[0].ProfileNickname = &nickname
+	fake.Chip.EUICCInfo2.EuiccCiPKIDListForSigning = []string{"81370f5125d0b1d408d4c3b232e6d25e795bebfb", "f54172bdf98a95d65cbeb88a38a1c11d800a85c3"}
+	fake.Chip.EUICCInfo2.EuiccCiPKIDListForVerification = []string{"81370f5125d0b1d408d4c3b232e6d25e795bebfb"}
+	require.NoError(t, LpacProfileEnable("8988303000000000010"))
+
+	report, err := CollectReport()
+	require.NoError(t, err)
+	assert.Equal(t, "89049032123451234512345678901235", report.EID)
+	assert.Equal(t, 300*1024, report.FreeMemory)
+	require.Len(t, report.CertificateIssuers, 1)
+	assert.Len(t, report.Profiles, 2)
+	require.Len(t, report.Notifications, 2)
+	assert.Less(t, report.Notifications[0].SeqNumber, report.Notifications[1].SeqNumber)
+
+	var buf bytes.Buffer
+	require.NoError(t, report.Write(&buf, "csv"))
+	records, err := csv.NewReader(&buf).ReadAll()
+	require.NoError(t, err)
+	require.Len(t, records, 5)
+	assert.Equal(t, []string{"profile", "89049032123451234512345678901235", "8988303000000000002"}, records[1][:3])
+	assert.Equal(t, "notification", records[4][0])
+
+	report.Mask()
+	buf.Reset()
+	require.NoError(t, report.Write(&buf, "md"))
+	md := buf.String()
+	assert.Contains(t, md, "| EID | 8904903*************************")
+	assert.Contains(t, md, `Work \| <b>SIM</b>`)
+	assert.NotContains(t, md, "8988303000000000010")
+
+	buf.Reset()
+	require.NoError(t, report.Write(&buf, "html"))
+	assert.Contains(t, buf.String(), "&lt;b&gt;SIM&lt;/b&gt;")
+	assert.Contains(t, buf.String(), "8988303************")
+
+	buf.Reset()
+	require.NoError(t, report.Write(&buf, "json"))
+	var decoded Report
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &decoded))
+	assert.True(t, decoded.Masked)
+	assert.Equal(t, "8988303************", decoded.Profiles[1].Iccid)
+
+	assert.Error(t, report.Write(&buf, "pdf"))
+
+	// 只导出选中的 Profile 和相关通知
+	report, err = CollectReport()
+	require.NoError(t, err)
+	report.SelectProfiles([]string{"8988303000000000010"})
+	require.Len(t, report.Profiles, 1)
+	require.Len(t, report.Notifications, 1)
+	assert.Equal(t, "enable", report.Notifications[0].Operation)
+}
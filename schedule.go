@@ -0,0 +1,395 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ScheduleTimeLayout 是时间窗口使用的本地时间格式，也接受 RFC 3339
+const ScheduleTimeLayout = "2006-01-02 15:04"
+
+// ScheduleEntry 在指定时间启用卡片上的 Profile
+// Cron 和 From/Until 二选一：Cron 在每个匹配的分钟执行，时间窗口在 From 到 Until 之间执行一次
+type ScheduleEntry struct {
+	Name  string `json:"name"`
+	Iccid string `json:"iccid"`
+	// EID 不为空时只在这张卡片上执行，Reader 不为空时执行前切换到这个读卡器
+	EID    string `json:"eid,omitempty"`
+	Reader string `json:"reader,omitempty"`
+	Cron   string `json:"cron,omitempty"`
+	From   string `json:"from,omitempty"`
+	// Until 为空时窗口只有 From 所在的一分钟
+	Until    string `json:"until,omitempty"`
+	Disabled bool   `json:"disabled,omitempty"`
+
+	cron        *CronSpec
+	from, until time.Time
+}
+
+// Validate 检查并解析时间设置
+func (e *ScheduleEntry) Validate() error {
+	if e.Name == "" {
+		return errors.New("name is required")
+	}
+	if e.Iccid == "" || strings.Trim(e.Iccid, "0123456789") != "" {
+		return fmt.Errorf("invalid ICCID %q", e.Iccid)
+	}
+	if (e.Cron == "") == (e.From == "") {
+		return errors.New("exactly one of cron and from is required")
+	}
+	var err error
+	if e.Cron != "" {
+		if e.Until != "" {
+			return errors.New("until can only be used with from")
+		}
+		e.cron, err = ParseCronSpec(e.Cron)
+		return err
+	}
+	if e.from, err = parseScheduleTime(e.From); err != nil {
+		return err
+	}
+	e.until = e.from.Add(time.Minute)
+	if e.Until != "" {
+		if e.until, err = parseScheduleTime(e.Until); err != nil {
+			return err
+		}
+		if !e.until.After(e.from) {
+			return fmt.Errorf("until %q is not after from %q", e.Until, e.From)
+		}
+	}
+	return nil
+}
+
+func parseScheduleTime(value string) (time.Time, error) {
+	if t, err := time.ParseInLocation(ScheduleTimeLayout, value, time.Local); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid time %q, expected %q", value, ScheduleTimeLayout)
+	}
+	return t, nil
+}
+
+// When 返回用于显示的执行时间
+func (e *ScheduleEntry) When() string {
+	if e.Cron != "" {
+		return e.Cron
+	}
+	if e.Until == "" {
+		return e.From
+	}
+	return e.From + " ~ " + e.Until
+}
+
+// Next 返回 now 之后（包括 now 所在的分钟）下一次执行的时间，不会再执行时返回零值
+func (e *ScheduleEntry) Next(now time.Time) time.Time {
+	switch {
+	case e.Disabled:
+		return time.Time{}
+	case e.cron != nil:
+		return e.cron.Next(now.Add(-time.Minute))
+	case now.Before(e.from):
+		return e.from
+	case now.Before(e.until):
+		return now.Truncate(time.Minute)
+	}
+	return time.Time{}
+}
+
+func (e *ScheduleEntry) due(now time.Time) bool {
+	switch {
+	case e.Disabled:
+		return false
+	case e.cron != nil:
+		return e.cron.Matches(now)
+	}
+	return !now.Before(e.from) && now.Before(e.until)
+}
+
+// ScheduleStatus 是一次计划执行的结果
+type ScheduleStatus string
+
+const (
+	ScheduleSwitched  ScheduleStatus = "switched"
+	ScheduleUnchanged ScheduleStatus = "unchanged"
+	// ScheduleNoCard 表示读卡器或卡片不存在，或者不是指定 EID 的卡片
+	ScheduleNoCard ScheduleStatus = "no_card"
+	// ScheduleBusy 表示读卡器正在执行其他操作，时间窗口在下一分钟重试
+	ScheduleBusy   ScheduleStatus = "busy"
+	ScheduleFailed ScheduleStatus = "failed"
+)
+
+var (
+	ErrScheduleWrongCard       = errors.New("card EID does not match")
+	ErrScheduleProfileNotFound = errors.New("profile not found on card")
+	ErrScheduleBusy            = errors.New("card reader is busy")
+	// ErrScheduleDuplicateName 表示计划名称重复，名称用于查找计划
+	ErrScheduleDuplicateName = errors.New("duplicate name")
+)
+
+// ScheduleRun 记录一次计划执行
+type ScheduleRun struct {
+	Entry   string
+	Iccid   string
+	Time    time.Time
+	Status  ScheduleStatus
+	Err     error
+	Outcome SwitchResult
+}
+
+func (r *ScheduleRun) String() string {
+	s := fmt.Sprintf("schedule %q: enable %s: %s", r.Entry, r.Iccid, r.Status)
+	if r.Err != nil {
+		s += ": " + r.Err.Error()
+	}
+	if r.Outcome.Err != nil {
+		if r.Outcome.RolledBack {
+			s += " (rolled back)"
+		} else if r.Outcome.RollbackErr != nil {
+			s += " (rollback failed: " + r.Outcome.RollbackErr.Error() + ")"
+		}
+	}
+	for _, outcome := range r.Outcome.Notifications {
+		if outcome.Err != nil {
+			s += fmt.Sprintf("; notification %d: %v", outcome.Notification.SeqNumber, outcome.Err)
+		}
+	}
+	return s
+}
+
+// scheduleHistorySize 是保留的执行记录数量
+const scheduleHistorySize = 100
+
+// Scheduler 在 EasyLPAC 运行期间按计划切换 Profile，界面和 serve 模式共用
+type Scheduler struct {
+	mu      sync.Mutex
+	entries []*ScheduleEntry
+	// done 记录本次运行中已经执行过的时间窗口，以 windowKey 为键
+	done     map[string]bool
+	history  []*ScheduleRun
+	lastTick time.Time
+
+	// SelectReader 在计划指定的读卡器不是当前读卡器时调用，执行结束后再次调用以恢复原来的读卡器
+	SelectReader func(d *ApduDriver)
+	// CardChanged 在切换 Profile 后调用
+	CardChanged func()
+	// Notify 返回是否按通知策略处理切换产生的通知，默认为 true
+	Notify func() bool
+	// OnRun 在每次执行结束后调用
+	OnRun func(*ScheduleRun)
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// SchedulerInstance 是正在运行的计划任务，未启动时为 nil
+var SchedulerInstance *Scheduler
+
+func NewScheduler(entries []*ScheduleEntry) *Scheduler {
+	s := &Scheduler{
+		done: make(map[string]bool),
+		SelectReader: func(d *ApduDriver) {
+			selectReader(d)
+			Events.Publish(Event{Type: "status", Status: currentStatus()})
+		},
+		CardChanged: func() {},
+		Notify:      func() bool { return true },
+		OnRun:       func(*ScheduleRun) {},
+	}
+	s.SetEntries(entries)
+	return s
+}
+
+// SetEntries 替换计划列表，不合法或名称重复的计划会被忽略
+func (s *Scheduler) SetEntries(entries []*ScheduleEntry) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries = nil
+	names := make(map[string]bool)
+	for _, e := range entries {
+		// 保存副本，之后修改设置不会影响正在执行的计划
+		entry := *e
+		if entry.Validate() == nil && !names[entry.Name] {
+			names[entry.Name] = true
+			s.entries = append(s.entries, &entry)
+		}
+	}
+}
+
+// windowKey 区分时间窗口，修改了时间的同名计划会重新执行
+func (e *ScheduleEntry) windowKey() string {
+	return e.Name + "\x00" + e.From + "\x00" + e.Until
+}
+
+// History 返回最近的执行记录，最新的在前
+func (s *Scheduler) History() []*ScheduleRun {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	history := make([]*ScheduleRun, len(s.history))
+	for i, run := range s.history {
+		history[len(history)-1-i] = run
+	}
+	return history
+}
+
+// Start 在每分钟开始时检查计划，直到调用 Stop
+func (s *Scheduler) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	s.cancel = cancel
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		for {
+			now := time.Now()
+			timer := time.NewTimer(now.Truncate(time.Minute).Add(time.Minute).Sub(now))
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return
+			case now = <-timer.C:
+				s.Tick(ctx, now)
+			}
+		}
+	}()
+}
+
+func (s *Scheduler) Stop() {
+	if s.cancel != nil {
+		s.cancel()
+		s.wg.Wait()
+	}
+}
+
+// Tick 依次执行 now 所在分钟应执行的计划，同一分钟只检查一次
+func (s *Scheduler) Tick(ctx context.Context, now time.Time) []*ScheduleRun {
+	minute := now.Truncate(time.Minute)
+	s.mu.Lock()
+	if !minute.After(s.lastTick) {
+		s.mu.Unlock()
+		return nil
+	}
+	s.lastTick = minute
+	var due []*ScheduleEntry
+	for _, e := range s.entries {
+		if e.due(minute) && !s.done[e.windowKey()] {
+			due = append(due, e)
+		}
+	}
+	s.mu.Unlock()
+
+	var runs []*ScheduleRun
+	for _, e := range due {
+		if ctx.Err() != nil {
+			break
+		}
+		run := s.Run(ctx, e)
+		// 没有卡片或读卡器忙时时间窗口在下一分钟重试，其他结果不再重复执行
+		if e.cron == nil && run.Status != ScheduleNoCard && run.Status != ScheduleBusy {
+			s.mu.Lock()
+			s.done[e.windowKey()] = true
+			s.mu.Unlock()
+		}
+		runs = append(runs, run)
+	}
+	return runs
+}
+
+// Run 立即执行一个计划：确认读卡器和卡片存在后，按照界面中启用 Profile 的流程切换并发送通知
+func (s *Scheduler) Run(ctx context.Context, e *ScheduleEntry) *ScheduleRun {
+	run := &ScheduleRun{Entry: e.Name, Iccid: e.Iccid, Time: time.Now()}
+	run.Status, run.Err = s.run(ctx, e, run)
+	if ConfigInstance.LogFile != nil {
+		fmt.Fprintln(ConfigInstance.LogFile, run.String())
+	}
+	s.mu.Lock()
+	s.history = append(s.history, run)
+	if len(s.history) > scheduleHistorySize {
+		s.history = s.history[len(s.history)-scheduleHistorySize:]
+	}
+	s.mu.Unlock()
+	s.OnRun(run)
+	return run
+}
+
+func (s *Scheduler) run(ctx context.Context, e *ScheduleEntry, run *ScheduleRun) (ScheduleStatus, error) {
+	// 不在用户的操作中途切换读卡器或 Profile，执行期间也不允许其他操作更换读卡器
+	release, ok := CardJobs.TryHold()
+	if !ok {
+		return ScheduleBusy, ErrScheduleBusy
+	}
+	defer release()
+	restore := func() {}
+	if e.Reader != "" {
+		d, err := FindReader(e.Reader)
+		if err != nil {
+			return ScheduleNoCard, err
+		}
+		if d.Env != ConfigInstance.DriverIFID {
+			previous := &ApduDriver{Env: ConfigInstance.DriverIFID, Name: ConfigInstance.ReaderName}
+			s.SelectReader(d)
+			var once sync.Once
+			restore = func() {
+				once.Do(func() {
+					if previous.Env != "" {
+						s.SelectReader(previous)
+					}
+				})
+			}
+			defer restore()
+		}
+	}
+	info, err := LpacChipInfo()
+	if err != nil {
+		if lpacErr, ok := AsLpacError(err); ok && lpacErr.Class == ErrorClassReader {
+			return ScheduleNoCard, err
+		}
+		return ScheduleFailed, err
+	}
+	if e.EID != "" && !strings.EqualFold(info.EidValue, e.EID) {
+		return ScheduleNoCard, fmt.Errorf("%w: %s", ErrScheduleWrongCard, info.EidValue)
+	}
+	profiles, err := LpacProfileList()
+	if err != nil {
+		return ScheduleFailed, err
+	}
+	var target *Profile
+	for _, p := range profiles {
+		if p.Iccid == e.Iccid {
+			target = p
+		}
+	}
+	switch {
+	case target == nil:
+		return ScheduleFailed, ErrScheduleProfileNotFound
+	case target.ProfileState == "enabled":
+		return ScheduleUnchanged, nil
+	}
+
+	// 无人值守，不等待确认
+	switchProfile := NewSafeSwitch(e.Iccid, profiles)
+	switchProfile.Notify = s.Notify()
+	run.Outcome = switchProfile.Run(ctx)
+	restore()
+	s.CardChanged()
+	if run.Outcome.Err != nil {
+		return ScheduleFailed, run.Outcome.Err
+	}
+	return ScheduleSwitched, nil
+}
+
+// Find 按名称查找计划
+func (s *Scheduler) Find(name string) *ScheduleEntry {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, e := range s.entries {
+		if e.Name == name {
+			return e
+		}
+	}
+	return nil
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScheduleEntryValidate(t *testing.T) {
+	for _, e := range []*ScheduleEntry{
+		{Iccid: "8988303000000000010", Cron: "* * * * *"},
+		{Name: "roaming", Iccid: "8988303000000000010"},
+		{Name: "roaming", Iccid: "8988303000000000010", Cron: "* * * * *", From: "2026-10-18 08:00"},
+		{Name: "roaming", Iccid: "8988303000000000010", From: "2026-10-18 08:00", Until: "2026-10-18 07:00"},
+		{Name: "roaming", Iccid: "8988303000000000010", From: "tomorrow"},
+		{Name: "roaming", Iccid: "ICCID", Cron: "* * * * *"},
+	} {
+		assert.Error(t, e.Validate(), e.Name+e.Cron+e.From)
+	}
+
+	e := &ScheduleEntry{Name: "roaming", Iccid: "8988303000000000010", From: "2026-10-18 08:00"}
+	require.NoError(t, e.Validate())
+	from := time.Date(2026, 10, 18, 8, 0, 0, 0, time.Local)
+	assert.Equal(t, from, e.Next(from.Add(-time.Hour)))
+	assert.True(t, e.due(from))
+	assert.False(t, e.due(from.Add(time.Minute)), "a window without until lasts one minute")
+	assert.True(t, e.Next(from.Add(time.Minute)).IsZero())
+}
+
+func TestSchedulerTick(t *testing.T) {
+	fake := useFakeLpac(t)
+	start := time.Date(2026, 10, 18, 8, 0, 0, 0, time.Local)
+	scheduler := NewScheduler([]*ScheduleEntry{
+		{Name: "roaming", Iccid: "8988303000000000010", EID: fake.Chip.EidValue, Cron: "0 8 * * *"},
+		{Name: "home", Iccid: "8988303000000000002", From: "2026-10-18 08:01", Until: "2026-10-18 08:10"},
+		{Name: "other card", Iccid: "8988303000000000002", EID: "89049032000000000000000000000000", Cron: "*/5 * * * *"},
+	})
+	scheduler.Notify = func() bool { return true }
+	var changed int
+	scheduler.CardChanged = func() { changed++ }
+
+	runs := scheduler.Tick(context.Background(), start)
+	require.Len(t, runs, 2)
+	assert.Equal(t, ScheduleSwitched, runs[0].Status)
+	assert.Len(t, runs[0].Outcome.Notifications, 2)
+	assert.Equal(t, ScheduleNoCard, runs[1].Status)
+	assert.ErrorIs(t, runs[1].Err, ErrScheduleWrongCard)
+	assert.Equal(t, []string{"8988303000000000010"}, enabledIccids(t))
+	assert.Equal(t, 1, changed)
+	// 同一分钟不会重复执行
+	assert.Empty(t, scheduler.Tick(context.Background(), start.Add(30*time.Second)))
+
+	// 没有卡片时时间窗口在下一分钟重试，成功后不再执行
+	fake.RemoveCard()
+	runs = scheduler.Tick(context.Background(), start.Add(time.Minute))
+	require.Len(t, runs, 1)
+	assert.Equal(t, ScheduleNoCard, runs[0].Status)
+	fake.Removed = false
+	runs = scheduler.Tick(context.Background(), start.Add(2*time.Minute))
+	require.Len(t, runs, 1)
+	assert.Equal(t, ScheduleSwitched, runs[0].Status)
+	assert.Empty(t, scheduler.Tick(context.Background(), start.Add(3*time.Minute)))
+	assert.Equal(t, []string{"8988303000000000002"}, enabledIccids(t))
+
+	history := scheduler.History()
+	require.Len(t, history, 4)
+	assert.Equal(t, "home", history[0].Entry)
+
+	// 修改了时间的同名时间窗口重新执行，重复的名称被忽略
+	scheduler.SetEntries([]*ScheduleEntry{
+		{Name: "home", Iccid: "8988303000000000010", From: "2026-10-18 08:01", Until: "2026-10-18 08:20"},
+		{Name: "home", Iccid: "8988303000000000002", Cron: "* * * * *"},
+	})
+	runs = scheduler.Tick(context.Background(), start.Add(4*time.Minute))
+	require.Len(t, runs, 1)
+	assert.Equal(t, "8988303000000000010", runs[0].Iccid)
+	assert.Equal(t, ScheduleSwitched, runs[0].Status)
+}
+
+func TestSchedulerReader(t *testing.T) {
+	fake := useFakeLpac(t)
+	useConfigFile(t, "")
+	fake.Drivers = append(fake.Drivers, &ApduDriver{Env: "1", Name: "Other Reader"})
+	ConfigInstance.DriverIFID, ConfigInstance.ReaderName = "0", "Fake Card Reader 00 00"
+	scheduler := NewScheduler([]*ScheduleEntry{
+		{Name: "roaming", Iccid: "8988303000000000010", Reader: "Other Reader", Cron: "* * * * *"},
+	})
+	var selected []string
+	scheduler.SelectReader = func(d *ApduDriver) {
+		selected = append(selected, d.Name)
+		selectReader(d)
+	}
+
+	// 读卡器正在执行其他操作时不执行
+	release := CardJobs.Hold()
+	run := scheduler.Run(context.Background(), scheduler.Find("roaming"))
+	release()
+	assert.Equal(t, ScheduleBusy, run.Status)
+	assert.Empty(t, selected)
+
+	// 执行后恢复原来的读卡器
+	run = scheduler.Run(context.Background(), scheduler.Find("roaming"))
+	assert.Equal(t, ScheduleSwitched, run.Status)
+	assert.Equal(t, []string{"Other Reader", "Fake Card Reader 00 00"}, selected)
+	assert.Equal(t, "0", ConfigInstance.DriverIFID)
+	assert.False(t, CardJobs.Busy())
+}
+
+func TestSchedulerRunFailure(t *testing.T) {
+	fake := useFakeLpac(t)
+	scheduler := NewScheduler([]*ScheduleEntry{
+		{Name: "home", Iccid: "8988303000000000002", Cron: "* * * * *"},
+		{Name: "roaming", Iccid: "8988303000000000010", Cron: "* * * * *"},
+		{Name: "missing", Iccid: "8988303000000000036", Cron: "* * * * *"},
+	})
+	scheduler.Notify = func() bool { return false }
+
+	run := scheduler.Run(context.Background(), scheduler.Find("home"))
+	assert.Equal(t, ScheduleUnchanged, run.Status)
+	run = scheduler.Run(context.Background(), scheduler.Find("missing"))
+	assert.ErrorIs(t, run.Err, ErrScheduleProfileNotFound)
+
+	// 与界面相同，切换失败时恢复原来的 Profile
+	fake.Fail("profile enable", &FakeFailure{Function: "es10c_enable_profile", Data: "catBusy"})
+	run = scheduler.Run(context.Background(), scheduler.Find("roaming"))
+	assert.Equal(t, ScheduleFailed, run.Status)
+	assert.True(t, run.Outcome.RolledBack)
+	assert.Contains(t, run.String(), "(rolled back)")
+	assert.Equal(t, []string{"8988303000000000002"}, enabledIccids(t))
+}
@@ -68,7 +68,7 @@ type Profile struct {
 }
 
 func (p *Profile) MaskedICCID() string {
-	return p.Iccid[0:7] + strings.Repeat("*", len(p.Iccid)-7)
+	return maskIdentifier(p.Iccid)
 }
 
 type Notification struct {
@@ -79,7 +79,15 @@ type Notification struct {
 }
 
 func (n *Notification) MaskedICCID() string {
-	return n.Iccid[0:7] + strings.Repeat("*", len(n.Iccid)-7)
+	return maskIdentifier(n.Iccid)
+}
+
+// maskIdentifier 只保留 ICCID、EID 等标识的前 7 位
+func maskIdentifier(s string) string {
+	if len(s) <= 7 {
+		return s
+	}
+	return s[0:7] + strings.Repeat("*", len(s)-7)
 }
 
 type ApduDriver struct {
@@ -1,9 +1,16 @@
 package main
 
 import (
+	"bytes"
+	"context"
 	"errors"
 	"fmt"
+	"image/png"
+	"slices"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"fyne.io/fyne/v2"
@@ -18,6 +25,7 @@ import (
 
 var StatusProcessBar *widget.ProgressBarInfinite
 var StatusLabel *widget.Label
+var CancelButton *widget.Button
 var SetNicknameButton *widget.Button
 var DownloadButton *widget.Button
 var DeleteProfileButton *widget.Button
@@ -27,13 +35,47 @@ var ProcessAllNotificationButton *widget.Button
 var RemoveNotificationButton *widget.Button
 var BatchRemoveNotificationButton *widget.Button
 
+// NotificationRetryLabel 提示等待重试的通知，没有时隐藏
+var NotificationRetryLabel *widget.Label
+
 var ProfileList *widget.List
 var NotificationList *widget.List
 
+// NotificationGroupList 在选择分组方式后代替 NotificationList 显示
+var NotificationGroupList *widget.List
+var NotificationGroupSelect *widget.Select
+var NotificationGroups []NotificationGroup
+var NotificationGroupBy NotificationGrouping
+var ActivityList *widget.List
+
+// ScheduleHistoryList 是计划对话框中的执行记录，对话框关闭时为 nil
+var ScheduleHistoryList *widget.List
+
+var CancelJobButton *widget.Button
+var ClearActivityButton *widget.Button
+
+// activityJobs 是 ActivityList 显示的任务快照
+var activityJobs []Job
+var selectedJobID int
+
 var FreeSpaceLabel *widget.Label
 var OpenLogButton *widget.Button
 var RefreshButton *widget.Button
 var ProfileMaskCheck *widget.Check
+var ProfileSearchEntry *widget.Entry
+var ProfileStateSelect *widget.Select
+var ProfileClassSelect *widget.Select
+var ProfileSortSelect *widget.Select
+var ProfileCheckAllButton *widget.Button
+
+// ProfileFilterLabel 在设置了筛选条件时显示筛选后的 Profile 数量
+var ProfileFilterLabel *widget.Label
+var BulkSelectionLabel *widget.Label
+var BulkDeleteButton *widget.Button
+var BulkNicknameButton *widget.Button
+var BulkExportButton *widget.Button
+var BulkClearButton *widget.Button
+var BulkActionBar *fyne.Container
 var NotificationMaskCheck *widget.Check
 
 var EidLabel *widget.Label
@@ -45,8 +87,10 @@ var SetDefaultSmdpButton *widget.Button
 var ViewCertInfoButton *widget.Button
 var EUICCManufacturerLabel *widget.Label
 var CopyEuiccInfo2Button *widget.Button
+var ExportReportButton *widget.Button
 
 var ApduDriverSelect *widget.Select
+var AidEntry *widget.Entry
 var ApduDriverRefreshButton *widget.Button
 
 var Tabs *container.AppTabs
@@ -55,10 +99,15 @@ var NotificationTab *container.TabItem
 var ChipInfoTab *container.TabItem
 var SettingsTab *container.TabItem
 var AboutTab *container.TabItem
+var ActivityTab *container.TabItem
 
 var LpacVersionLabel *widget.Label
+var APIStatusLabel *widget.Label
 var LanguageSelect *widget.Select
 
+// ActiveStageProgress 是当前打开的阶段进度视图，由状态栏监听器更新
+var ActiveStageProgress atomic.Pointer[StageProgress]
+
 type ReadOnlyEntry struct{ widget.Entry }
 
 func (entry *ReadOnlyEntry) TypedRune(_ rune)          {}
@@ -79,6 +128,66 @@ func (entry *ReadOnlyEntry) TappedSecondary(ev *fyne.PointEvent) {
 	widget.ShowPopUpMenuAtPosition(menu, fyne.CurrentApp().Driver().CanvasForObject(entry), ev.AbsolutePosition)
 }
 
+// StageProgress 以阶段列表的形式显示 lpac 进度和每个阶段的用时
+type StageProgress struct {
+	Content *fyne.Container
+
+	stages  []ProgressStage
+	icons   []*widget.Icon
+	labels  []*widget.Label
+	step    *widget.Label
+	started []time.Duration
+	current int
+}
+
+func NewStageProgress(stages []ProgressStage) *StageProgress {
+	v := &StageProgress{
+		stages:  stages,
+		step:    &widget.Label{TextStyle: fyne.TextStyle{Monospace: true}},
+		started: make([]time.Duration, len(stages)),
+		current: -1,
+	}
+	rows := container.NewVBox()
+	for _, stage := range stages {
+		icon := widget.NewIcon(theme.MoreHorizontalIcon())
+		label := widget.NewLabel(stage.Name())
+		v.icons = append(v.icons, icon)
+		v.labels = append(v.labels, label)
+		rows.Add(container.NewHBox(icon, label))
+	}
+	v.Content = container.NewVBox(rows, v.step, widget.NewProgressBarInfinite())
+	return v
+}
+
+func (v *StageProgress) Update(p LpacProgress) {
+	for i, stage := range v.stages {
+		if stage == p.Stage && i > v.current {
+			for j := v.current + 1; j <= i; j++ {
+				v.started[j] = p.Elapsed
+			}
+			v.current = i
+		}
+	}
+	for i, stage := range v.stages {
+		switch {
+		case i < v.current:
+			v.icons[i].SetResource(theme.ConfirmIcon())
+			v.labels[i].SetText(fmt.Sprintf("%s %s", stage.Name(), formatElapsed(v.started[i+1]-v.started[i])))
+		case i == v.current:
+			v.icons[i].SetResource(theme.MediaPlayIcon())
+			v.labels[i].SetText(fmt.Sprintf("%s %s", stage.Name(), formatElapsed(p.Elapsed-v.started[i])))
+		default:
+			v.icons[i].SetResource(theme.MoreHorizontalIcon())
+			v.labels[i].SetText(stage.Name())
+		}
+	}
+	step := p.Step
+	if party := p.Party(); party != "" {
+		step = fmt.Sprintf("%s (%s)", step, party)
+	}
+	v.step.SetText(fmt.Sprintf("%s  %s", formatElapsed(p.Elapsed), step))
+}
+
 func NewReadOnlyEntry() *ReadOnlyEntry {
 	entry := &ReadOnlyEntry{}
 	entry.ExtendBaseWidget(entry) // 确保自定义的 widget 被正确地初始化
@@ -95,6 +204,11 @@ func InitWidgets() {
 
 	StatusLabel = widget.NewLabel(TR.Trans("label.status_ready"))
 
+	CancelButton = &widget.Button{Text: TR.Trans("dialog.cancel"),
+		OnTapped: func() { go CardJobs.CancelRunning() },
+		Icon:     theme.CancelIcon()}
+	CancelButton.Hide()
+
 	DownloadButton = &widget.Button{Text: TR.Trans("label.download_profile_button"),
 		OnTapped: func() { go downloadButtonFunc() },
 		Icon:     theme.DownloadIcon()}
@@ -113,6 +227,23 @@ func InitWidgets() {
 
 	ProfileList = initProfileList()
 	NotificationList = initNotificationList()
+	NotificationGroupList = initNotificationGroupList()
+	NotificationGroupList.Hide()
+	NotificationGroupSelect = widget.NewSelect(nil, func(string) {
+		NotificationGroupBy = NotificationGroupings[NotificationGroupSelect.SelectedIndex()]
+		RefreshNotificationGroups()
+	})
+	RefreshNotificationGroupOptions()
+	ActivityList = initActivityList()
+
+	CancelJobButton = &widget.Button{Text: TR.Trans("label.cancel_job_button"),
+		OnTapped: func() { go CardJobs.Cancel(selectedJobID) },
+		Icon:     theme.CancelIcon()}
+	CancelJobButton.Disable()
+
+	ClearActivityButton = &widget.Button{Text: TR.Trans("label.clear_activity_button"),
+		OnTapped: func() { go CardJobs.ClearHistory() },
+		Icon:     theme.ContentClearIcon()}
 
 	ProcessNotificationButton = &widget.Button{Text: TR.Trans("label.process_notification_button"),
 		OnTapped: func() { go processNotificationButtonFunc() },
@@ -130,6 +261,9 @@ func InitWidgets() {
 		OnTapped: func() { go batchRemoveNotificationButtonFunc() },
 		Icon:     theme.DeleteIcon()}
 
+	NotificationRetryLabel = &widget.Label{Wrapping: fyne.TextWrapWord, TextStyle: fyne.TextStyle{Bold: true}}
+	NotificationRetryLabel.Hide()
+
 	FreeSpaceLabel = widget.NewLabel("")
 
 	OpenLogButton = &widget.Button{Text: TR.Trans("label.open_log_button"),
@@ -149,6 +283,48 @@ func InitWidgets() {
 			ProfileList.Refresh()
 		}
 	})
+	ProfileFilterLabel = &widget.Label{Importance: widget.WarningImportance}
+	ProfileFilterLabel.Hide()
+	ProfileSearchEntry = &widget.Entry{PlaceHolder: TR.Trans("label.profile_search_placeholder"),
+		OnChanged: func(s string) {
+			CurrentProfileFilter.Query = s
+			ApplyProfileFilter()
+		}}
+	ProfileStateSelect = widget.NewSelect(nil, func(string) {
+		CurrentProfileFilter.State = profileStateFilters[ProfileStateSelect.SelectedIndex()]
+		ApplyProfileFilter()
+	})
+	ProfileClassSelect = widget.NewSelect(nil, func(string) {
+		CurrentProfileFilter.Class = profileClassFilters[ProfileClassSelect.SelectedIndex()]
+		ApplyProfileFilter()
+	})
+	ProfileSortSelect = widget.NewSelect(nil, func(string) {
+		CurrentProfileFilter.SortBy = profileSortKeys[ProfileSortSelect.SelectedIndex()]
+		ApplyProfileFilter()
+	})
+	RefreshProfileFilterOptions()
+	ProfileCheckAllButton = &widget.Button{OnTapped: func() { checkAllProfiles() },
+		Icon: theme.CheckButtonCheckedIcon()}
+	BulkSelectionLabel = &widget.Label{TextStyle: fyne.TextStyle{Bold: true}}
+	BulkDeleteButton = &widget.Button{Text: TR.Trans("label.bulk_delete_button"),
+		OnTapped: func() { go bulkDeleteButtonFunc() },
+		Icon:     theme.DeleteIcon()}
+	BulkNicknameButton = &widget.Button{Text: TR.Trans("label.bulk_nickname_button"),
+		OnTapped: func() { go bulkNicknameButtonFunc() },
+		Icon:     theme.DocumentCreateIcon()}
+	BulkExportButton = &widget.Button{Text: TR.Trans("label.bulk_export_button"),
+		OnTapped: func() { go bulkExportButtonFunc() },
+		Icon:     theme.DocumentSaveIcon()}
+	BulkClearButton = &widget.Button{Text: TR.Trans("label.bulk_clear_button"),
+		OnTapped: func() {
+			clear(CheckedProfileIccids)
+			updateBulkActions()
+			ProfileList.Refresh()
+		},
+		Icon: theme.ContentClearIcon()}
+	BulkActionBar = container.NewHBox(BulkSelectionLabel, layout.NewSpacer(),
+		BulkClearButton, BulkExportButton, BulkNicknameButton, BulkDeleteButton)
+	BulkActionBar.Hide()
 	NotificationMaskCheck = widget.NewCheck(TR.Trans("label.notification_mask_check"), func(b bool) {
 		if b {
 			NotificationMaskNeeded = true
@@ -157,6 +333,7 @@ func InitWidgets() {
 			NotificationMaskNeeded = false
 			NotificationList.Refresh()
 		}
+		NotificationGroupList.Refresh()
 	})
 
 	EidLabel = widget.NewLabel("")
@@ -181,6 +358,10 @@ func InitWidgets() {
 		OnTapped: func() { go copyEuiccInfo2ButtonFunc() },
 		Icon:     theme.ContentCopyIcon()}
 	CopyEuiccInfo2Button.Hide()
+	ExportReportButton = &widget.Button{Text: TR.Trans("label.export_report_button"),
+		OnTapped: func() { go exportReportButtonFunc() },
+		Icon:     theme.DocumentSaveIcon()}
+	ExportReportButton.Hide()
 	ApduDriverSelect = widget.NewSelect([]string{}, func(s string) { SetDriverIFID(s) })
 	ApduDriverRefreshButton = &widget.Button{OnTapped: func() { go RefreshApduDriver() },
 		Icon: theme.SearchReplaceIcon()}
@@ -199,6 +380,64 @@ func downloadButtonFunc() {
 	InitDownloadDialog().Show()
 }
 
+func downloadProfile(info PullInfo) {
+	defer CardJobs.Hold()()
+	progress := NewStageProgress(DownloadStages)
+	cancelButton := &widget.Button{Text: TR.Trans("dialog.cancel"),
+		OnTapped: func() { go CardJobs.CancelRunning() },
+		Icon:     theme.CancelIcon()}
+	progressDialog := dialog.NewCustomWithoutButtons(TR.Trans("dialog.download_progress"),
+		container.NewBorder(nil, container.NewCenter(cancelButton), nil, nil, progress.Content), WMain)
+	progressDialog.Resize(fyne.Size{Width: 420, Height: 260})
+	progressDialog.Show()
+	ActiveStageProgress.Store(progress)
+	notificationOrigin := Notifications
+	err := LpacProfileDownload(info)
+	ActiveStageProgress.Store(nil)
+	progressDialog.Hide()
+	cancelled := errors.Is(err, ErrLpacCancelled)
+	if err != nil && !cancelled {
+		ShowLpacErrDialogWithRetry(err, func() { downloadProfile(info) })
+		return
+	}
+	// 取消时 Profile 可能已经安装完成，刷新后以卡片上的状态为准
+	Refresh()
+	downloadNotification := findNewNotification(notificationOrigin, Notifications)
+	if cancelled && downloadNotification == nil {
+		dialog.ShowInformation(TR.Trans("dialog.info"), TR.Trans("message.download_cancelled"), WMain)
+		return
+	}
+	if downloadNotification == nil {
+		dialog.ShowError(errors.New("notification not found"), WMain)
+		return
+	}
+	RememberIMEI(info.IMEI)
+	// 按当前 eUICC 的昵称模板设置新 Profile 的昵称
+	if template := CurrentCardMemory().NicknameTemplate; template != "" {
+		if profile, err := findProfileByIccid(downloadNotification.Iccid); err == nil && profile.ProfileNickname == nil {
+			if err = LpacProfileNickname(profile.Iccid, ExpandNicknameTemplate(template, profile)); err != nil {
+				ShowLpacErrDialog(err)
+			} else if err = RefreshProfile(); err != nil {
+				ShowLpacErrDialog(err)
+			}
+		}
+	}
+	outcome := SendNotifications([]*Notification{downloadNotification})[0]
+	if err2 := RefreshNotification(); err2 != nil {
+		ShowLpacErrDialog(err2)
+	}
+	if outcome.Action == ActionAsk {
+		ShowPendingNotificationsDialog(TR.Trans("message.download_successful"), []*Notification{downloadNotification})
+		return
+	}
+	dialogText := TR.Trans("message.download_successful") + "\n" + notificationOutcomeText(outcome)
+	if outcome.Err != nil {
+		dialog.ShowError(errors.New(dialogText), WMain)
+	} else {
+		dialog.ShowInformation(TR.Trans("dialog.info"), dialogText, WMain)
+	}
+}
+
 func setNicknameButtonFunc() {
 	if ConfigInstance.DriverIFID == "" {
 		ShowSelectCardReaderDialog()
@@ -208,7 +447,7 @@ func setNicknameButtonFunc() {
 		ShowRefreshNeededDialog()
 		return
 	}
-	if SelectedProfile == Unselected {
+	if SelectedProfile() == nil {
 		ShowSelectItemDialog()
 		return
 	}
@@ -224,11 +463,12 @@ func deleteProfileButtonFunc() {
 		ShowRefreshNeededDialog()
 		return
 	}
-	if SelectedProfile == Unselected {
+	profile := SelectedProfile()
+	if profile == nil {
 		ShowSelectItemDialog()
 		return
 	}
-	if Profiles[SelectedProfile].ProfileState == "enabled" {
+	if profile.ProfileState == "enabled" {
 		d := dialog.NewInformation(TR.Trans("dialog.hint"), TR.Trans("message.disable_profile_before_delete"), WMain)
 		d.Resize(fyne.Size{
 			Width:  360,
@@ -237,22 +477,14 @@ func deleteProfileButtonFunc() {
 		d.Show()
 		return
 	}
-	profileText := fmt.Sprint(
-		TR.Trans("label.info_iccid")+" ", Profiles[SelectedProfile].Iccid, "\n",
-		TR.Trans("label.info_provider")+" ", Profiles[SelectedProfile].ServiceProviderName, "\n",
-	)
-	if Profiles[SelectedProfile].ProfileNickname != nil {
-		profileText += fmt.Sprint(TR.Trans("label.info_nickname")+" ", *Profiles[SelectedProfile].ProfileNickname, "\n")
-	}
-	dialog.ShowCustomConfirm(TR.Trans("dialog.confirm"),
-		TR.Trans("dialog.confirm"),
-		TR.Trans("dialog.cancel"),
+	showProfileConfirm(GuardProfileOperation("delete", profile, Profiles),
 		container.NewVBox(container.NewCenter(widget.NewLabel(TR.Trans("message.delete_profile_confirm"))),
-			&widget.Label{Text: profileText}),
+			profileSummary(profile)),
 		func(b bool) {
 			if b {
 				go func() {
-					if err := LpacProfileDelete(Profiles[SelectedProfile].Iccid); err != nil {
+					defer CardJobs.Hold()()
+					if err := LpacProfileDelete(profile.Iccid); err != nil {
 						ShowLpacErrDialog(err)
 						Refresh()
 					} else {
@@ -263,73 +495,20 @@ func deleteProfileButtonFunc() {
 							dialog.ShowError(errors.New(TR.Trans("message.notification_not_found")), WMain)
 							return
 						}
-						if ConfigInstance.AutoMode {
-							// 默认保留 delete 通知
-							if err2 := LpacNotificationProcess(deleteNotification.SeqNumber, false); err2 != nil {
-								dialog.ShowError(errors.New(TR.Trans("message.successfully_delete_profile_failed_send_notification")), WMain)
-							} else {
-								// Ask to remove delete notification
-								// fixme 和手动操作通知模式重构
-								var d *dialog.CustomDialog
-								notNowButton := &widget.Button{
-									Text: "Not Now",
-									Icon: theme.CancelIcon(),
-									OnTapped: func() {
-										d.Hide()
-									},
-								}
-								removeButton := &widget.Button{
-									Text: "Remove",
-									Icon: theme.DeleteIcon(),
-									OnTapped: func() {
-										go func() {
-											d.Hide()
-											if err3 := LpacNotificationRemove(deleteNotification.SeqNumber); err3 != nil {
-												ShowLpacErrDialog(err3)
-											}
-											if err3 := RefreshNotification(); err3 != nil {
-												ShowLpacErrDialog(err3)
-												return
-											}
-											if err3 := RefreshChipInfo(); err3 != nil {
-												ShowLpacErrDialog(err3)
-												return
-											}
-										}()
-									},
-								}
-								d = dialog.NewCustomWithoutButtons(TR.Trans("dialog.delete_profile_remove_notification"),
-									container.NewBorder(
-										nil,
-										container.NewCenter(container.NewHBox(notNowButton, spacer, removeButton)),
-										nil,
-										nil,
-										container.NewVBox(
-											&widget.Label{Text: TR.Trans("message.successfully_delete_profile_ask_remove_notification"),
-												Alignment: fyne.TextAlignCenter},
-											&widget.Label{Text: fmt.Sprintf(TR.Trans("label.info_seq")+" %d\n"+
-												TR.Trans("label.info_iccid")+" %s\n"+
-												TR.Trans("label.info_operation")+" %s\n"+
-												TR.Trans("label.info_address")+" %s\n",
-												deleteNotification.SeqNumber, deleteNotification.Iccid,
-												deleteNotification.ProfileManagementOperation, deleteNotification.NotificationAddress)})),
-									WMain)
-								d.Show()
-							}
-						} else {
-							dialog.ShowConfirm(TR.Trans("dialog.delete_profile_successfully"),
-								TR.Trans("dialog.successfully_delete_profile_ask_send_notification"),
-								func(b bool) {
-									if b {
-										go processNotificationManually(deleteNotification.SeqNumber)
-									}
-								},
-								WMain)
+						outcome := SendNotifications([]*Notification{deleteNotification})[0]
+						if err := RefreshNotification(); err != nil {
+							ShowLpacErrDialog(err)
+						}
+						switch {
+						case outcome.Action == ActionAsk:
+							ShowPendingNotificationsDialog(TR.Trans("dialog.delete_profile_successfully"), []*Notification{deleteNotification})
+						case outcome.Err != nil:
+							dialog.ShowError(errors.New(TR.Trans("dialog.delete_profile_successfully")+"\n"+notificationOutcomeText(outcome)), WMain)
 						}
 					}
 				}()
 			}
-		}, WMain)
+		})
 }
 
 func switchStateButtonFunc() {
@@ -341,53 +520,462 @@ func switchStateButtonFunc() {
 		ShowRefreshNeededDialog()
 		return
 	}
-	if SelectedProfile == Unselected {
+	profile := SelectedProfile()
+	if profile == nil {
 		ShowSelectItemDialog()
 		return
 	}
 	if ProfileStateAllowDisable {
-		if err := LpacProfileDisable(Profiles[SelectedProfile].Iccid); err != nil {
-			ShowLpacErrDialog(err)
+		switchProfileState(profile, true)
+		return
+	}
+	// 启用前显示将要启用和被自动禁用的 Profile
+	content := container.NewVBox(container.NewCenter(widget.NewLabel(TR.Trans("message.enable_profile_confirm"))),
+		profileSummary(profile))
+	for _, p := range Profiles {
+		if p.ProfileState == "enabled" {
+			content.Add(widget.NewSeparator())
+			content.Add(container.NewCenter(widget.NewLabel(TR.Trans("message.enable_profile_disables"))))
+			content.Add(profileSummary(p))
+		}
+	}
+	showProfileConfirm(GuardProfileOperation("enable", profile, Profiles), content,
+		func(b bool) {
+			if b {
+				go switchProfileState(profile, false)
+			}
+		})
+}
+
+// showProfileConfirm 显示启用或删除 Profile 的确认对话框
+// 按 guard 附加警告，需要时要求输入 guard.ConfirmCode 才能确认
+func showProfileConfirm(guard ProfileGuard, content *fyne.Container, callback func(bool)) {
+	if len(guard.Warnings) > 0 {
+		content.Add(widget.NewSeparator())
+		for _, warning := range guard.Warnings {
+			content.Add(&widget.Label{Text: TR.Trans(warning), Importance: widget.DangerImportance,
+				TextStyle: fyne.TextStyle{Bold: true}, Wrapping: fyne.TextWrapWord})
+		}
+	}
+	var d *dialog.CustomDialog
+	confirmButton := &widget.Button{
+		Text:       TR.Trans("dialog.confirm"),
+		Icon:       theme.ConfirmIcon(),
+		Importance: widget.HighImportance,
+		OnTapped: func() {
+			d.Hide()
+			callback(true)
+		},
+	}
+	cancelButton := &widget.Button{
+		Text: TR.Trans("dialog.cancel"),
+		Icon: theme.CancelIcon(),
+		OnTapped: func() {
+			d.Hide()
+			callback(false)
+		},
+	}
+	if guard.Strong {
+		confirmButton.Importance = widget.DangerImportance
+		confirmButton.Disable()
+		entry := &widget.Entry{PlaceHolder: guard.ConfirmCode}
+		entry.OnChanged = func(s string) {
+			if strings.TrimSpace(s) == guard.ConfirmCode {
+				confirmButton.Enable()
+			} else {
+				confirmButton.Disable()
+			}
 		}
+		content.Add(widget.NewLabel(TR.Trans(guard.ConfirmPrompt, mf.Arg("code", guard.ConfirmCode))))
+		content.Add(entry)
+	}
+	d = dialog.NewCustomWithoutButtons(TR.Trans("dialog.confirm"), container.NewBorder(nil,
+		container.NewCenter(container.NewHBox(cancelButton, spacer, confirmButton)), nil, nil, content), WMain)
+	d.Resize(fyne.Size{Width: 440, Height: content.MinSize().Height + 120})
+	d.Show()
+}
+
+func switchProfileState(profile *Profile, disable bool) {
+	if !disable {
+		safeSwitchProfile(profile)
+		return
+	}
+	defer CardJobs.Hold()()
+	if err := LpacProfileDisable(profile.Iccid); err != nil {
+		ShowLpacErrDialog(err)
+	}
+	notificationsOrigin := Notifications
+	Refresh()
+	switchNotifications := findNewNotifications(notificationsOrigin, Notifications)
+	// 禁用 Profile，产生一个 disable 通知
+	if switchNotifications == nil || len(switchNotifications) > 2 {
+		dialog.ShowError(errors.New(TR.Trans("message.notification_not_found")), WMain)
 	} else {
-		if err := LpacProfileEnable(Profiles[SelectedProfile].Iccid); err != nil {
-			ShowLpacErrDialog(err)
+		showSwitchNotificationErrors(SendNotifications(switchNotifications))
+	}
+	Refresh()
+}
+
+// showSwitchNotificationErrors 显示切换 Profile 后处理失败的通知，并询问策略为询问的通知
+func showSwitchNotificationErrors(outcomes []NotificationOutcome) {
+	ShowPendingNotificationsDialog(TR.Trans("message.successfully_enable_profile"), PendingNotifications(outcomes))
+	dialogText := TR.Trans("message.successfully_enable_profile") + "\n"
+	var hasError bool
+	for _, outcome := range outcomes {
+		if outcome.Err != nil {
+			hasError = true
+			switch outcome.Notification.ProfileManagementOperation {
+			case "enable":
+				dialogText += TR.Trans("message.failed_process_enable_notification") + "\n"
+			case "disable":
+				dialogText += TR.Trans("message.failed_process_disable_notification") + "\n"
+			}
 		}
 	}
-	if ConfigInstance.AutoMode {
-		notificationsOrigin := Notifications
-		Refresh()
-		switchNotifications := findNewNotifications(notificationsOrigin, Notifications)
-		// 考虑两种情况
-		// 所有 Profile 禁用的情况下，启用 Profile 产生一个 enable 通知
-		// 有一个 Profile 已启用，启用另外一个，产生一个 disable 和一个 enable 通知
-		// 禁用 Profile，产生一个 disable 通知
-		if switchNotifications == nil || len(switchNotifications) > 2 {
-			dialog.ShowError(errors.New(TR.Trans("message.notification_not_found")), WMain)
-		} else {
-			dialogText := TR.Trans("message.successfully_enable_profile") + "\n"
-			var hasError bool
-			for _, notification := range switchNotifications {
-				if err2 := LpacNotificationProcess(notification.SeqNumber, true); err2 != nil {
-					hasError = true
-					switch notification.ProfileManagementOperation {
-					case "enable":
-						dialogText += TR.Trans("message.failed_process_enable_notification") + "\n"
-					case "disable":
-						dialogText += TR.Trans("message.failed_process_disable_notification") + "\n"
-					}
+	if hasError {
+		dialogText += TR.Trans("message.notification_retry_scheduled")
+		dialog.ShowError(errors.New(dialogText), WMain)
+	}
+}
+
+// notificationActionName 是处理方式在界面中的名称
+func notificationActionName(action NotificationAction) string {
+	return TR.Trans("label.notification_action_" + string(action))
+}
+
+// notificationOutcomeText 描述按策略处理一个通知的结果
+func notificationOutcomeText(outcome NotificationOutcome) string {
+	n := outcome.Notification
+	if outcome.Err == nil {
+		return TR.Trans("message.notification_action_done", mf.Arg("seq", n.SeqNumber),
+			mf.Arg("operation", n.ProfileManagementOperation), mf.Arg("action", notificationActionName(outcome.Action)))
+	}
+	text := TR.Trans("message.notification_action_failed", mf.Arg("seq", n.SeqNumber),
+		mf.Arg("operation", n.ProfileManagementOperation), mf.Arg("action", notificationActionName(outcome.Action)))
+	if outcome.Action.Sends() {
+		text += "\n" + TR.Trans("message.notification_retry_scheduled")
+	}
+	return text
+}
+
+// notificationOriginName 返回通知来源的显示名称
+func notificationOriginName(origin NotificationOrigin) string {
+	return TR.Trans("label.notification_origin_" + string(origin))
+}
+
+// notificationAgeText 按分钟、小时或天显示通知存在的时间
+func notificationAgeText(age time.Duration) string {
+	switch {
+	case age < time.Hour:
+		return TR.Trans("label.notification_age_minutes", mf.Arg("count", int(age.Minutes())))
+	case age < 48*time.Hour:
+		return TR.Trans("label.notification_age_hours", mf.Arg("count", int(age.Hours())))
+	default:
+		return TR.Trans("label.notification_age_days", mf.Arg("count", int(age.Hours()/24)))
+	}
+}
+
+// overdueNotificationsText 列出长时间未发送的通知，最多列出 10 个
+func overdueNotificationsText(overdue []SeenNotification) string {
+	var lines []string
+	now := time.Now()
+	for i, seen := range overdue {
+		if i == 10 {
+			lines = append(lines, TR.Trans("message.notification_overdue_more", mf.Arg("count", len(overdue)-i)))
+			break
+		}
+		lines = append(lines, TR.Trans("message.notification_overdue_item", mf.Arg("seq", seen.SeqNumber),
+			mf.Arg("operation", seen.Operation), mf.Arg("address", seen.Address),
+			mf.Arg("origin", notificationOriginName(seen.Origin)), mf.Arg("age", notificationAgeText(seen.Age(now)))))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// ShowPendingNotificationsDialog 询问策略为询问的通知如何处理，选择的方式用于所有通知
+func ShowPendingNotificationsDialog(message string, pending []*Notification) {
+	// 只询问仍在卡片上的通知
+	pending = slices.DeleteFunc(slices.Clone(pending), func(n *Notification) bool {
+		return findNotificationBySeq(Notifications, n.SeqNumber) == nil
+	})
+	if len(pending) == 0 {
+		return
+	}
+	var d *dialog.CustomDialog
+	apply := func(action NotificationAction) {
+		d.Hide()
+		go func() {
+			outcomes := make([]NotificationOutcome, 0, len(pending))
+			var failures []string
+			for _, n := range pending {
+				outcome := ApplyNotificationAction(n, action)
+				if outcome.Err != nil {
+					failures = append(failures, notificationOutcomeText(outcome))
 				}
+				outcomes = append(outcomes, outcome)
+			}
+			recordNotificationFailures(outcomes)
+			if err := RefreshNotification(); err != nil {
+				ShowLpacErrDialog(err)
+			}
+			if err := RefreshChipInfo(); err != nil {
+				ShowLpacErrDialog(err)
+			}
+			if len(failures) > 0 {
+				dialog.ShowError(errors.New(strings.Join(failures, "\n")), WMain)
 			}
-			if hasError {
-				dialog.ShowError(errors.New(dialogText), WMain)
+		}()
+	}
+	notNowButton := &widget.Button{
+		Text:     TR.Trans("dialog.not_now"),
+		Icon:     theme.CancelIcon(),
+		OnTapped: func() { d.Hide() },
+	}
+	removeButton := &widget.Button{
+		Text:     notificationActionName(ActionRemove),
+		Icon:     theme.DeleteIcon(),
+		OnTapped: func() { apply(ActionRemove) },
+	}
+	sendButton := &widget.Button{
+		Text:     notificationActionName(ActionSendKeep),
+		Icon:     theme.MailSendIcon(),
+		OnTapped: func() { apply(ActionSendKeep) },
+	}
+	sendRemoveButton := &widget.Button{
+		Text:       notificationActionName(ActionSendRemove),
+		Icon:       theme.MailSendIcon(),
+		Importance: widget.HighImportance,
+		OnTapped:   func() { apply(ActionSendRemove) },
+	}
+	content := container.NewVBox(
+		&widget.Label{Text: message, Alignment: fyne.TextAlignCenter, TextStyle: fyne.TextStyle{Bold: true}},
+		&widget.Label{Text: TR.Trans("message.pending_notifications_ask"), Alignment: fyne.TextAlignCenter})
+	for _, n := range pending {
+		content.Add(&widget.Label{Text: fmt.Sprintf(TR.Trans("label.info_seq")+" %d\n"+
+			TR.Trans("label.info_iccid")+" %s\n"+
+			TR.Trans("label.info_operation")+" %s\n"+
+			TR.Trans("label.info_address")+" %s",
+			n.SeqNumber, n.Iccid, n.ProfileManagementOperation, n.NotificationAddress)})
+	}
+	d = dialog.NewCustomWithoutButtons(TR.Trans("dialog.pending_notifications"), container.NewBorder(
+		nil,
+		container.NewCenter(container.NewHBox(notNowButton, removeButton, sendButton, sendRemoveButton)),
+		nil, nil,
+		container.NewVScroll(content)), WMain)
+	d.Resize(fyne.Size{Width: 520, Height: min(content.MinSize().Height+120, 480)})
+	d.Show()
+}
+
+// safeSwitchProfile 启用 Profile 并通过 profile list 验证结果
+// 验证失败、切换失败或在设置的等待时间内没有确认时，切换回原来启用的 Profile
+func safeSwitchProfile(profile *Profile) {
+	s := NewSafeSwitch(profile.Iccid, Profiles)
+	s.GracePeriod = ConfigInstance.SwitchGracePeriod
+	s.Notify = true
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var confirmDialog *dialog.CustomDialog
+	s.OnStage = func(stage SwitchStage) {
+		switch stage {
+		case SwitchWaitingConfirm:
+			confirmDialog = showSwitchConfirmDialog(profile, s, cancel)
+		case SwitchRollingBack, SwitchFinished:
+			if confirmDialog != nil {
+				confirmDialog.Hide()
 			}
 		}
 	}
+	result := s.Run(ctx)
 	Refresh()
-	if ProfileStateAllowDisable {
-		SwitchStateButton.SetText(TR.Trans("label.switch_state_button_enable"))
-		SwitchStateButton.SetIcon(theme.ConfirmIcon())
+	if result.Err == nil {
+		showSwitchNotificationErrors(result.Notifications)
+		return
+	}
+
+	var content *fyne.Container
+	if _, ok := AsLpacError(result.Err); ok {
+		content = lpacErrorContent(result.Err)
+	} else {
+		content = container.NewVBox(container.NewCenter(widget.NewLabel(switchFailureText(result.Err))))
+	}
+	content.Add(widget.NewSeparator())
+	if result.RolledBack {
+		content.Add(container.NewCenter(&widget.Label{Text: TR.Trans("message.switch_restored"),
+			TextStyle: fyne.TextStyle{Bold: true}}))
+	} else {
+		content.Add(container.NewCenter(&widget.Label{Text: TR.Trans("message.switch_rollback_failed"),
+			TextStyle: fyne.TextStyle{Bold: true}, Importance: widget.DangerImportance}))
+		content.Add(container.NewCenter(widget.NewLabel(fmt.Sprint(result.RollbackErr))))
+	}
+	showSwitchNotificationErrors(result.Notifications)
+	dialog.ShowCustom(TR.Trans("dialog.error"), TR.Trans("dialog.ok"), content, WMain)
+}
+
+// showSwitchConfirmDialog 倒计时询问新 Profile 是否可用，revert 立即切换回原来的 Profile
+func showSwitchConfirmDialog(profile *Profile, s *SafeSwitch, revert func()) *dialog.CustomDialog {
+	deadline := time.Now().Add(s.GracePeriod)
+	countdownLabel := &widget.Label{Alignment: fyne.TextAlignCenter}
+	keepButton := &widget.Button{
+		Text:       TR.Trans("label.switch_keep_button"),
+		Icon:       theme.ConfirmIcon(),
+		Importance: widget.HighImportance,
+		OnTapped:   func() { s.Confirm() },
+	}
+	revertButton := &widget.Button{
+		Text:     TR.Trans("label.switch_revert_button"),
+		Icon:     theme.ContentUndoIcon(),
+		OnTapped: func() { revert() },
+	}
+	d := dialog.NewCustomWithoutButtons(TR.Trans("dialog.switch_confirm"), container.NewBorder(
+		nil,
+		container.NewCenter(container.NewHBox(revertButton, spacer, keepButton)),
+		nil,
+		nil,
+		container.NewVBox(
+			&widget.Label{Text: TR.Trans("message.switch_confirm"), Alignment: fyne.TextAlignCenter, Wrapping: fyne.TextWrapWord},
+			profileSummary(profile),
+			countdownLabel)), WMain)
+	done := make(chan struct{})
+	d.SetOnClosed(func() { close(done) })
+	go func() {
+		ticker := time.NewTicker(time.Second)
+		defer ticker.Stop()
+		for {
+			remaining := max(time.Until(deadline).Round(time.Second), 0)
+			countdownLabel.SetText(TR.Trans("label.switch_countdown", mf.Arg("seconds", int(remaining.Seconds()))))
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	d.Resize(fyne.Size{Width: 420, Height: 260})
+	d.Show()
+	return d
+}
+
+// switchFailureText 说明安全切换失败的原因
+func switchFailureText(err error) string {
+	switch {
+	case errors.Is(err, ErrSwitchNotConfirmed):
+		return TR.Trans("message.switch_not_confirmed")
+	case errors.Is(err, context.Canceled):
+		return TR.Trans("message.switch_reverted")
+	case errors.Is(err, ErrSwitchVerification):
+		return TR.Trans("message.switch_verification_failed")
+	}
+	return strings.TrimSpace(err.Error())
+}
+
+// checkAllProfiles 勾选列表中所有 Profile，已全部勾选时取消勾选
+func checkAllProfiles() {
+	all := len(VisibleProfiles) > 0 && len(CheckedProfiles()) == len(VisibleProfiles)
+	clear(CheckedProfileIccids)
+	if !all {
+		for _, p := range VisibleProfiles {
+			CheckedProfileIccids[p.Iccid] = true
+		}
+	}
+	updateBulkActions()
+	ProfileList.Refresh()
+}
+
+func bulkDeleteButtonFunc() {
+	if ConfigInstance.DriverIFID == "" {
+		ShowSelectCardReaderDialog()
+		return
+	}
+	if RefreshNeeded {
+		ShowRefreshNeededDialog()
+		return
+	}
+	selected := CheckedProfiles()
+	if len(selected) == 0 {
+		ShowSelectItemDialog()
+		return
+	}
+	confirmDelete := func(disableEnabled bool) {
+		steps, err := PlanBulkDelete(selected, disableEnabled)
+		if err != nil {
+			dialog.ShowError(err, WMain)
+			return
+		}
+		content := container.NewVBox(container.NewCenter(widget.NewLabel(
+			TR.Trans("message.bulk_delete_confirm", mf.Arg("count", len(selected))))))
+		for _, p := range selected {
+			content.Add(widget.NewLabel(bulkProfileName(p)))
+		}
+		showProfileConfirm(GuardBulkDelete(selected, Profiles), content, func(b bool) {
+			if b {
+				operation := NewBulkOperation(steps)
+				operation.Notify = true
+				ShowBulkProgressDialog(TR.Trans("label.bulk_delete_button"), operation)
+			}
+		})
+	}
+	for _, p := range selected {
+		if p.ProfileState == "enabled" {
+			// 已启用的 Profile 需要用户同意后先禁用
+			dialog.ShowConfirm(TR.Trans("dialog.hint"),
+				TR.Trans("message.bulk_disable_enabled_confirm", mf.Arg("profile", bulkProfileName(p))),
+				func(b bool) {
+					if b {
+						confirmDelete(true)
+					}
+				}, WMain)
+			return
+		}
+	}
+	confirmDelete(false)
+}
+
+func bulkNicknameButtonFunc() {
+	if ConfigInstance.DriverIFID == "" {
+		ShowSelectCardReaderDialog()
+		return
+	}
+	if RefreshNeeded {
+		ShowRefreshNeededDialog()
+		return
+	}
+	if len(CheckedProfiles()) == 0 {
+		ShowSelectItemDialog()
+		return
+	}
+	InitBulkNicknameDialog().Show()
+}
+
+func bulkExportButtonFunc() {
+	if ConfigInstance.DriverIFID == "" {
+		ShowSelectCardReaderDialog()
+		return
+	}
+	if RefreshNeeded {
+		ShowRefreshNeededDialog()
+		return
+	}
+	var iccids []string
+	for _, p := range CheckedProfiles() {
+		iccids = append(iccids, p.Iccid)
 	}
+	if len(iccids) == 0 {
+		ShowSelectItemDialog()
+		return
+	}
+	InitExportReportDialog(iccids).Show()
+}
+
+// bulkProfileName 是批量操作中显示的 Profile 名称，遵循 ICCID 遮盖设置
+func bulkProfileName(p *Profile) string {
+	iccid := p.Iccid
+	if ProfileMaskNeeded {
+		iccid = p.MaskedICCID()
+	}
+	name := p.ProfileName
+	if p.ProfileNickname != nil && *p.ProfileNickname != "" {
+		name = *p.ProfileNickname
+	}
+	return iccid + "  " + name
 }
 
 func processNotificationButtonFunc() {
@@ -416,86 +1004,59 @@ func processAllNotificationButtonFunc() {
 		ShowRefreshNeededDialog()
 		return
 	}
-	config := map[string]bool{
-		"enable":  true,
-		"disable": true,
-		"install": true,
-		"delete":  false,
-	}
-	enableCheck := &widget.Check{
-		Text:    TR.Trans("label.notification_operation_enable"),
-		Checked: true,
-		OnChanged: func(b bool) {
-			config["enable"] = b
-		},
-	}
-	disableCheck := &widget.Check{
-		Text:    TR.Trans("label.notification_operation_disable"),
-		Checked: true,
-		OnChanged: func(b bool) {
-			config["disable"] = b
-		},
-	}
-	installCheck := &widget.Check{
-		Text:    TR.Trans("label.notification_operation_install"),
-		Checked: true,
-		OnChanged: func(b bool) {
-			config["install"] = b
-		},
+	showProcessNotificationsDialog(TR.Trans("dialog.process_all_notification"), Notifications)
+}
+
+// showProcessNotificationsDialog 选择每个通知的处理方式后批量处理
+// 每个通知默认使用策略中的处理方式，可以只修改这一次
+func showProcessNotificationsDialog(title string, notifications []*Notification) {
+	policy := currentNotificationPolicy()
+	notifications = slices.Clone(notifications)
+	actions := make([]NotificationAction, len(notifications))
+	options := make([]string, len(NotificationActions))
+	for i, action := range NotificationActions {
+		options[i] = notificationActionName(action)
 	}
-	deleteCheck := &widget.Check{
-		Text:    TR.Trans("label.notification_operation_delete"),
-		Checked: false,
-		OnChanged: func(b bool) {
-			config["delete"] = b
-		},
+	rows := container.NewVBox()
+	for i, n := range notifications {
+		actions[i] = policy.Action(n)
+		actionSelect := widget.NewSelect(options, func(s string) {
+			actions[i] = NotificationActions[slices.Index(options, s)]
+		})
+		actionSelect.SetSelected(notificationActionName(actions[i]))
+		rows.Add(container.NewBorder(nil, nil, nil, actionSelect, &widget.Label{
+			Text:       fmt.Sprintf("%d  %s  %s", n.SeqNumber, n.ProfileManagementOperation, n.NotificationAddress),
+			Truncation: fyne.TextTruncateEllipsis,
+		}))
 	}
-	dialog.ShowCustomConfirm(TR.Trans("dialog.process_all_notification"),
+	d := dialog.NewCustomConfirm(title,
 		TR.Trans("dialog.ok"),
 		TR.Trans("dialog.cancel"),
-		container.NewVBox(
-			&widget.Label{Text: TR.Trans("message.select_remove_notification_type")},
-			enableCheck,
-			disableCheck,
-			installCheck,
-			deleteCheck,
-		),
+		container.NewBorder(
+			&widget.Label{Text: TR.Trans("message.process_all_notification_policy"), Wrapping: fyne.TextWrapWord},
+			nil, nil, nil,
+			container.NewVScroll(rows)),
 		func(b bool) {
-			if b {
-				total := len(Notifications)
-				var count int
-				for _, notification := range Notifications {
-					switch notification.ProfileManagementOperation {
-					case "enable":
-						if err := LpacNotificationProcess(notification.SeqNumber, config["enable"]); err != nil {
-							count++
-						}
-					case "disable":
-						if err := LpacNotificationProcess(notification.SeqNumber, config["disable"]); err != nil {
-							count++
-						}
-					case "install":
-						if err := LpacNotificationProcess(notification.SeqNumber, config["install"]); err != nil {
-							count++
-						}
-					case "delete":
-						if err := LpacNotificationProcess(notification.SeqNumber, config["delete"]); err != nil {
-							count++
-						}
-					}
-				}
-				if err := RefreshNotification(); err != nil {
-					ShowLpacErrDialog(err)
+			if !b {
+				return
+			}
+			// 询问的通知保留在卡片上
+			var selected []*Notification
+			selectedActions := make(map[int]NotificationAction)
+			for i, n := range notifications {
+				if actions[i] != ActionAsk {
+					selected = append(selected, n)
+					selectedActions[n.SeqNumber] = actions[i]
 				}
-				dialog.ShowCustom(TR.Trans("dialog.process_all_notification_finished"),
-					"OK",
-					&widget.Label{Text: TR.Trans("message.process_all_notification_result",
-						mf.Arg("total", total),
-						mf.Arg("success", total-count),
-						mf.Arg("fail", count))},
-					WMain)
 			}
+			if len(selected) == 0 {
+				return
+			}
+			ShowNotificationBatchDialog(title,
+				NewNotificationBatch(selected, func(n *Notification) NotificationAction { return selectedActions[n.SeqNumber] }))
 		}, WMain)
+	d.Resize(fyne.Size{Width: 600, Height: 420})
+	d.Show()
 }
 
 func removeNotificationButtonFunc() {
@@ -544,91 +1105,191 @@ func batchRemoveNotificationButtonFunc() {
 		ShowRefreshNeededDialog()
 		return
 	}
-	config := map[string]bool{
-		"enable":  true,
-		"disable": true,
-		"install": true,
-		"delete":  false,
-	}
-	enableCheck := &widget.Check{
-		Text:    TR.Trans("label.notification_operation_enable"),
-		Checked: true,
-		OnChanged: func(b bool) {
-			config["enable"] = b
-		},
-	}
-	disableCheck := &widget.Check{
-		Text:    TR.Trans("label.notification_operation_disable"),
-		Checked: true,
-		OnChanged: func(b bool) {
-			config["disable"] = b
-		},
-	}
-	installCheck := &widget.Check{
-		Text:    TR.Trans("label.notification_operation_install"),
-		Checked: true,
-		OnChanged: func(b bool) {
-			config["install"] = b
-		},
-	}
-	deleteCheck := &widget.Check{
-		Text:    TR.Trans("label.notification_operation_delete"),
-		Checked: false,
-		OnChanged: func(b bool) {
-			config["delete"] = b
-		},
+	// 默认保留 delete 通知
+	remove := map[string]bool{"enable": true, "disable": true, "install": true}
+	checks := container.NewVBox(&widget.Label{Text: TR.Trans("message.select_batch_remove_notification_type")})
+	for _, operation := range NotificationOperations {
+		checks.Add(&widget.Check{
+			Text:      TR.Trans("label.notification_operation_" + operation),
+			Checked:   remove[operation],
+			OnChanged: func(b bool) { remove[operation] = b },
+		})
 	}
 	dialog.ShowCustomConfirm(TR.Trans("dialog.batch_remove_notification"),
 		TR.Trans("dialog.confirm"),
 		TR.Trans("dialog.cancel"),
-		container.NewVBox(
-			&widget.Label{Text: TR.Trans("message.select_batch_remove_notification_type")},
-			enableCheck,
-			disableCheck,
-			installCheck,
-			deleteCheck),
+		checks,
 		func(b bool) {
-			if b {
-				var failedCount int
-				var total int
-				for _, notification := range Notifications {
-					switch notification.ProfileManagementOperation {
-					case "enable":
-						if err := LpacNotificationRemove(notification.SeqNumber); err != nil {
-							failedCount++
-						}
-						total++
-					case "disable":
-						if err := LpacNotificationProcess(notification.SeqNumber, config["disable"]); err != nil {
-							failedCount++
-						}
-						total++
-					case "install":
-						if err := LpacNotificationProcess(notification.SeqNumber, config["install"]); err != nil {
-							failedCount++
-						}
-						total++
-					case "delete":
-						if err := LpacNotificationProcess(notification.SeqNumber, config["delete"]); err == nil {
-							failedCount++
-						}
-						total++
-					}
-				}
-				if err := RefreshNotification(); err != nil {
-					ShowLpacErrDialog(err)
+			if !b {
+				return
+			}
+			var selected []*Notification
+			for _, n := range Notifications {
+				if remove[n.ProfileManagementOperation] {
+					selected = append(selected, n)
 				}
-				dialog.ShowCustom(TR.Trans("dialog.batch_remove_notification_finished"),
-					TR.Trans("dialog.ok"),
-					&widget.Label{Text: TR.Trans("message.batch_remove_notification_result",
-						mf.Arg("total", total),
-						mf.Arg("success", total-failedCount),
-						mf.Arg("fail", failedCount))},
-					WMain)
 			}
+			if len(selected) == 0 {
+				return
+			}
+			ShowNotificationBatchDialog(TR.Trans("dialog.batch_remove_notification"),
+				NewNotificationBatch(selected, func(*Notification) NotificationAction { return ActionRemove }))
 		}, WMain)
 }
 
+// ShowNotificationBatchDialog 逐个处理通知并显示每个通知的结果
+// 点击失败的行可以选中，重试时只处理选中的通知，没有选中时重试所有失败和未处理的通知
+func ShowNotificationBatchDialog(title string, batch *NotificationBatch) {
+	items := batch.Items()
+	marked := make(map[int]bool)
+	progressBar := widget.NewProgressBar()
+	summaryLabel := &widget.Label{}
+
+	headers := []string{"", TR.Trans("label.info_seq"), TR.Trans("label.info_iccid"), TR.Trans("label.info_operation"),
+		TR.Trans("label.info_address"), TR.Trans("label.bulk_action"), TR.Trans("label.batch_status"), TR.Trans("label.batch_result")}
+	table := widget.NewTable(
+		func() (int, int) { return len(items) + 1, len(headers) },
+		func() fyne.CanvasObject { return &widget.Label{Truncation: fyne.TextTruncateEllipsis} },
+		func(id widget.TableCellID, o fyne.CanvasObject) {
+			label := o.(*widget.Label)
+			if id.Row == 0 {
+				label.TextStyle = fyne.TextStyle{Bold: true}
+				label.SetText(headers[id.Col])
+				return
+			}
+			label.TextStyle = fyne.TextStyle{}
+			item := items[id.Row-1]
+			n := item.Notification
+			switch id.Col {
+			case 0:
+				label.SetText("")
+				if marked[n.SeqNumber] {
+					label.SetText("✓")
+				}
+			case 1:
+				label.SetText(strconv.Itoa(n.SeqNumber))
+			case 2:
+				label.SetText(n.Iccid)
+			case 3:
+				label.SetText(n.ProfileManagementOperation)
+			case 4:
+				label.SetText(n.NotificationAddress)
+			case 5:
+				label.SetText(notificationActionName(item.Action))
+			case 6:
+				label.SetText(TR.Trans("label.bulk_status_" + item.Status.String()))
+			case 7:
+				label.SetText(notificationBatchResult(item))
+			}
+		})
+	for col, width := range []float32{30, 50, 180, 80, 180, 130, 90, 260} {
+		table.SetColumnWidth(col, width)
+	}
+
+	var d dialog.Dialog
+	var cancel context.CancelFunc
+	var stopButton, retryButton, copyButton, closeButton *widget.Button
+	update := func() {
+		items = batch.Items()
+		counts := batch.Counts()
+		progressBar.SetValue(float64(counts[BatchSucceeded]+counts[BatchFailed]) / float64(max(len(items), 1)))
+		summaryLabel.SetText(TR.Trans("label.notification_batch_summary",
+			mf.Arg("succeeded", counts[BatchSucceeded]), mf.Arg("failed", counts[BatchFailed]),
+			mf.Arg("pending", counts[BatchPending]+counts[BatchRunning]), mf.Arg("total", len(items))))
+		table.Refresh()
+	}
+	run := func() {
+		var ctx context.Context
+		ctx, cancel = context.WithCancel(context.Background())
+		stopButton.Enable()
+		retryButton.Disable()
+		copyButton.Disable()
+		closeButton.Disable()
+		go func() {
+			batch.Run(ctx, update)
+			cancel()
+			update()
+			stopButton.Disable()
+			copyButton.Enable()
+			closeButton.Enable()
+			if counts := batch.Counts(); counts[BatchFailed] > 0 || counts[BatchPending] > 0 {
+				retryButton.Enable()
+			}
+			if err := RefreshNotification(); err != nil {
+				ShowLpacErrDialog(err)
+				return
+			}
+			if err := RefreshChipInfo(); err != nil {
+				ShowLpacErrDialog(err)
+			}
+		}()
+	}
+	table.OnSelected = func(id widget.TableCellID) {
+		table.UnselectAll()
+		// 处理中不能选择
+		if id.Row == 0 || !stopButton.Disabled() || items[id.Row-1].Status != BatchFailed {
+			return
+		}
+		seq := items[id.Row-1].Notification.SeqNumber
+		marked[seq] = !marked[seq]
+		table.Refresh()
+	}
+	stopButton = &widget.Button{
+		Text: TR.Trans("dialog.cancel"),
+		Icon: theme.MediaStopIcon(),
+		OnTapped: func() {
+			cancel()
+			go CardJobs.CancelRunning()
+		},
+	}
+	retryButton = &widget.Button{
+		Text:       TR.Trans("label.batch_retry_button"),
+		Icon:       theme.MediaReplayIcon(),
+		Importance: widget.HighImportance,
+		OnTapped: func() {
+			for _, item := range items {
+				if seq := item.Notification.SeqNumber; marked[seq] || len(marked) == 0 {
+					batch.Requeue(seq)
+				}
+			}
+			clear(marked)
+			run()
+		},
+	}
+	copyButton = &widget.Button{
+		Text: TR.Trans("label.notification_batch_copy_button"),
+		Icon: theme.ContentCopyIcon(),
+		OnTapped: func() {
+			WMain.Clipboard().SetContent(batch.Report())
+		},
+	}
+	closeButton = &widget.Button{
+		Text:     TR.Trans("dialog.close"),
+		OnTapped: func() { d.Hide() },
+	}
+	d = dialog.NewCustomWithoutButtons(title, container.NewBorder(
+		container.NewVBox(progressBar, &widget.Label{Text: TR.Trans("label.notification_batch_hint"), Wrapping: fyne.TextWrapWord}),
+		container.NewVBox(summaryLabel, container.NewCenter(container.NewHBox(closeButton, copyButton, stopButton, retryButton))),
+		nil, nil, table), WMain)
+	d.Resize(fyne.Size{Width: 900, Height: 520})
+	d.Show()
+	update()
+	run()
+}
+
+// notificationBatchResult 是失败通知的原因
+func notificationBatchResult(item NotificationBatchItem) string {
+	if item.Err == nil {
+		return ""
+	}
+	if lpacErr, ok := AsLpacError(item.Err); ok {
+		if explanation, _ := lpacErr.Explanation(); explanation != "" {
+			return explanation
+		}
+	}
+	return strings.TrimSpace(item.Err.Error())
+}
+
 func copyEidButtonFunc() {
 	WMain.Clipboard().SetContent(ChipInfo.EidValue)
 	CopyEidButton.SetText(TR.Trans("label.copy_eid_button_copied"))
@@ -643,6 +1304,18 @@ func copyEuiccInfo2ButtonFunc() {
 	CopyEuiccInfo2Button.SetText(TR.Trans("label.copy_euicc_info2_button"))
 }
 
+func exportReportButtonFunc() {
+	if ConfigInstance.DriverIFID == "" {
+		ShowSelectCardReaderDialog()
+		return
+	}
+	if RefreshNeeded {
+		ShowRefreshNeededDialog()
+		return
+	}
+	InitExportReportDialog(nil).Show()
+}
+
 func setDefaultSmdpButtonFunc() {
 	if ConfigInstance.DriverIFID == "" {
 		ShowSelectCardReaderDialog()
@@ -730,10 +1403,42 @@ func viewCertInfoButtonFunc() {
 	d.Show()
 }
 
+// 筛选栏选项对应的值，顺序与 RefreshProfileFilterOptions 中的选项一致
+var profileStateFilters = []string{"", "enabled", "disabled"}
+var profileClassFilters = []string{"", "operational", "test", "provisioning"}
+var profileSortKeys = []ProfileSortKey{ProfileSortCard, ProfileSortProvider, ProfileSortNickname, ProfileSortIccid}
+
+// RefreshProfileFilterOptions 按当前语言设置筛选栏的选项，保留已选择的项
+func RefreshProfileFilterOptions() {
+	setOptions := func(s *widget.Select, options []string) {
+		index := max(s.SelectedIndex(), 0)
+		s.SetOptions(options)
+		s.SetSelectedIndex(index)
+	}
+	setOptions(ProfileStateSelect, []string{
+		TR.Trans("label.profile_filter_all_states"),
+		TR.Trans("label.profile_status_enabled"),
+		TR.Trans("label.profile_status_disabled"),
+	})
+	setOptions(ProfileClassSelect, []string{
+		TR.Trans("label.profile_filter_all_classes"),
+		TR.Trans("label.profile_class_operational"),
+		TR.Trans("label.profile_class_test"),
+		TR.Trans("label.profile_class_provisioning"),
+	})
+	setOptions(ProfileSortSelect, []string{
+		TR.Trans("label.profile_sort_card"),
+		TR.Trans("label.profile_sort_provider"),
+		TR.Trans("label.profile_sort_nickname"),
+		TR.Trans("label.profile_sort_iccid"),
+	})
+	ProfileSearchEntry.SetPlaceHolder(TR.Trans("label.profile_search_placeholder"))
+}
+
 func initProfileList() *widget.List {
 	return &widget.List{
 		Length: func() int {
-			return len(Profiles)
+			return len(VisibleProfiles)
 		},
 		CreateItem: func() fyne.CanvasObject {
 			iccidLabel := &widget.Label{}
@@ -742,85 +1447,181 @@ func initProfileList() *widget.List {
 			enabledIcon := widget.NewIcon(theme.ConfirmIcon())
 			profileIcon := widget.NewIcon(theme.FileImageIcon())
 			providerLabel := &widget.Label{}
-			return container.NewVBox(
+			classBadge := &widget.Label{TextStyle: fyne.TextStyle{Bold: true}}
+			// 左侧的勾选框用于批量操作，与列表的单选互不影响
+			return container.NewBorder(nil, nil, &widget.Check{}, nil, container.NewVBox(
 				container.NewHBox(iccidLabel, layout.NewSpacer(), nameLabel),
 				container.NewHBox(container.NewVBox(layout.NewSpacer(), stateLabel),
-					enabledIcon, providerLabel, profileIcon, layout.NewSpacer()))
+					enabledIcon, providerLabel, profileIcon, classBadge, layout.NewSpacer())))
 		},
 		UpdateItem: func(i widget.ListItemID, o fyne.CanvasObject) {
-			r1 := o.(*fyne.Container).Objects[0].(*fyne.Container)
-			r2 := o.(*fyne.Container).Objects[1].(*fyne.Container)
+			content := o.(*fyne.Container).Objects[0].(*fyne.Container)
+			checkBox := o.(*fyne.Container).Objects[1].(*widget.Check)
+			r1 := content.Objects[0].(*fyne.Container)
+			r2 := content.Objects[1].(*fyne.Container)
 			iccidLabel := r1.Objects[0].(*widget.Label)
 			nameLabel := r1.Objects[2].(*widget.Label)
 			stateLabel := r2.Objects[0].(*fyne.Container).Objects[1].(*widget.Label)
 			enabledIcon := r2.Objects[1].(*widget.Icon)
 			providerLabel := r2.Objects[2].(*widget.Label)
 			profileIcon := r2.Objects[3].(*widget.Icon)
+			classBadge := r2.Objects[4].(*widget.Label)
 
-			iccid := Profiles[i].Iccid
+			iccid := VisibleProfiles[i].Iccid
 			if ProfileMaskNeeded {
-				iccid = Profiles[i].MaskedICCID()
+				iccid = VisibleProfiles[i].MaskedICCID()
 			}
 			iccidLabel.SetText(fmt.Sprintf(TR.Trans("label.info_iccid")+" %s", iccid))
-			if Profiles[i].ProfileNickname != nil {
-				nameLabel.SetText(*Profiles[i].ProfileNickname)
+			if ValidateICCID(VisibleProfiles[i].Iccid) != nil {
+				iccidLabel.SetText(iccidLabel.Text + " " + TR.Trans("label.iccid_check_failed"))
+			}
+			if VisibleProfiles[i].ProfileNickname != nil {
+				nameLabel.SetText(*VisibleProfiles[i].ProfileNickname)
 			} else {
-				nameLabel.SetText(Profiles[i].ProfileName)
+				nameLabel.SetText(VisibleProfiles[i].ProfileName)
 			}
-			switch Profiles[i].ProfileState {
+			switch VisibleProfiles[i].ProfileState {
 			case "enabled":
 				stateLabel.SetText(TR.Trans("label.profile_status_enabled"))
 			case "disabled":
 				stateLabel.SetText(TR.Trans("label.profile_status_disabled"))
 			}
-			if Profiles[i].ProfileState == "enabled" {
+			if VisibleProfiles[i].ProfileState == "enabled" {
 				enabledIcon.Show()
 			} else {
 				enabledIcon.Hide()
 			}
 
-			if Profiles[i].Icon != nil {
-				profileIcon.SetResource(fyne.NewStaticResource(Profiles[i].Iccid, Profiles[i].Icon))
-				profileIcon.Show()
-			} else {
-				profileIcon.Hide()
+			setProfileIcon(profileIcon, VisibleProfiles[i])
+			// 测试和配置 Profile 显示类型标记
+			switch VisibleProfiles[i].ProfileClass {
+			case ProfileClassTest:
+				classBadge.Importance = widget.WarningImportance
+				classBadge.SetText(TR.Trans("label.profile_class_badge_test"))
+				classBadge.Show()
+			case ProfileClassProvisioning:
+				classBadge.Importance = widget.DangerImportance
+				classBadge.SetText(TR.Trans("label.profile_class_badge_provisioning"))
+				classBadge.Show()
+			default:
+				classBadge.Hide()
+			}
+
+			// 运营商名称为空时按 IIN 显示
+			flag, operator := iccidIssuer(VisibleProfiles[i].Iccid)
+			provider := VisibleProfiles[i].ServiceProviderName
+			if provider == "" {
+				provider = operator
 			}
+			providerLabel.SetText(strings.TrimSpace(flag + " " + TR.Trans("label.info_provider") + " " + provider))
 
-			providerLabel.SetText(TR.Trans("label.info_provider") + " " + Profiles[i].ServiceProviderName)
+			// 复用的行先清除回调，避免 SetChecked 修改其他 Profile 的勾选
+			profileIccid := VisibleProfiles[i].Iccid
+			checkBox.OnChanged = nil
+			checkBox.SetChecked(CheckedProfileIccids[profileIccid])
+			checkBox.OnChanged = func(b bool) {
+				if b {
+					CheckedProfileIccids[profileIccid] = true
+				} else {
+					delete(CheckedProfileIccids, profileIccid)
+				}
+				updateBulkActions()
+			}
 		},
 		OnSelected: func(id widget.ListItemID) {
-			SelectedProfile = id
-			if Profiles[SelectedProfile].ProfileState == "enabled" {
-				ProfileStateAllowDisable = true
-				SwitchStateButton.SetText(TR.Trans("label.switch_state_button_disable"))
-				SwitchStateButton.SetIcon(theme.CancelIcon())
-			} else {
-				ProfileStateAllowDisable = false
-				SwitchStateButton.SetText(TR.Trans("label.switch_state_button_enable"))
-				SwitchStateButton.SetIcon(theme.ConfirmIcon())
-			}
+			SelectedProfileIccid = VisibleProfiles[id].Iccid
+			updateSwitchStateButton(VisibleProfiles[id])
 		},
 		OnUnselected: func(id widget.ListItemID) {
-			SelectedProfile = Unselected
+			SelectedProfileIccid = ""
 		}}
 }
 
-func initNotificationList() *widget.List {
-	maskFQDNExceptPublicSuffix := func(fqdn string) string {
-		suffix, _ := publicsuffix.PublicSuffix(fqdn)
-		parts := strings.Split(fqdn, ".")
-		suffixParts := strings.Split(suffix, ".")
-		// 如果域名部分少于后缀部分，说明域名不合法或者是一个裸域名，直接返回掩码后的顶级域名
-		if len(parts) <= len(suffixParts) {
-			return strings.Repeat("x", len(parts[0])) + "." + suffix
+func initActivityList() *widget.List {
+	readerName := func(env string) string {
+		for _, d := range ApduDrivers {
+			if d.Env == env {
+				return d.Name
+			}
 		}
-		// 掩盖除了后缀之外的所有部分
-		for x := 0; x < len(parts)-len(suffixParts); x++ {
-			parts[x] = strings.Repeat("x", len(parts[x]))
+		return env
+	}
+	return &widget.List{
+		Length: func() int {
+			return len(activityJobs)
+		},
+		CreateItem: func() fyne.CanvasObject {
+			stateLabel := &widget.Label{TextStyle: fyne.TextStyle{Bold: true}}
+			commandLabel := &widget.Label{}
+			readerLabel := &widget.Label{}
+			durationLabel := &widget.Label{TextStyle: fyne.TextStyle{Monospace: true}}
+			return container.NewHBox(stateLabel, commandLabel, layout.NewSpacer(), readerLabel, durationLabel)
+		},
+		UpdateItem: func(i widget.ListItemID, o fyne.CanvasObject) {
+			stateLabel := o.(*fyne.Container).Objects[0].(*widget.Label)
+			commandLabel := o.(*fyne.Container).Objects[1].(*widget.Label)
+			readerLabel := o.(*fyne.Container).Objects[3].(*widget.Label)
+			durationLabel := o.(*fyne.Container).Objects[4].(*widget.Label)
+			job := activityJobs[i]
+			stateLabel.SetText(fmt.Sprintf("#%d %s", job.ID, job.State.Name()))
+			commandLabel.SetText(job.Command())
+			if job.needsCard() {
+				readerLabel.SetText(readerName(job.Reader))
+			} else {
+				readerLabel.SetText("")
+			}
+			durationLabel.SetText(formatElapsed(job.Duration()))
+		},
+		OnSelected: func(id widget.ListItemID) {
+			job := activityJobs[id]
+			selectedJobID = job.ID
+			if job.State == JobQueued || job.State == JobRunning {
+				CancelJobButton.Enable()
+			} else {
+				CancelJobButton.Disable()
+			}
+			if job.State == JobFailed {
+				ShowLpacErrDialog(job.Err)
+			}
+		},
+		OnUnselected: func(id widget.ListItemID) {
+			selectedJobID = 0
+			CancelJobButton.Disable()
+		},
+	}
+}
+
+// RefreshActivity 从 CardJobs 重新读取任务列表
+func RefreshActivity() {
+	activityJobs = CardJobs.Snapshot()
+	selectedActive := false
+	for _, job := range activityJobs {
+		if job.ID == selectedJobID && (job.State == JobQueued || job.State == JobRunning) {
+			selectedActive = true
 		}
-		return strings.Join(parts, ".")
 	}
+	if !selectedActive {
+		CancelJobButton.Disable()
+	}
+	ActivityList.Refresh()
+}
+
+func maskFQDNExceptPublicSuffix(fqdn string) string {
+	suffix, _ := publicsuffix.PublicSuffix(fqdn)
+	parts := strings.Split(fqdn, ".")
+	suffixParts := strings.Split(suffix, ".")
+	// 如果域名部分少于后缀部分，说明域名不合法或者是一个裸域名，直接返回掩码后的顶级域名
+	if len(parts) <= len(suffixParts) {
+		return strings.Repeat("x", len(parts[0])) + "." + suffix
+	}
+	// 掩盖除了后缀之外的所有部分
+	for x := 0; x < len(parts)-len(suffixParts); x++ {
+		parts[x] = strings.Repeat("x", len(parts[x]))
+	}
+	return strings.Join(parts, ".")
+}
 
+func initNotificationList() *widget.List {
 	return &widget.List{
 		Length: func() int {
 			return len(Notifications)
@@ -828,22 +1629,27 @@ func initNotificationList() *widget.List {
 		CreateItem: func() fyne.CanvasObject {
 			notificationAddressLabel := &widget.Label{}
 			seqLabel := &widget.Label{}
+			retryLabel := &widget.Label{}
 			operationLabel := &widget.Label{TextStyle: fyne.TextStyle{Bold: true}}
 			providerLabel := &widget.Label{}
 			iccidLabel := &widget.Label{}
 			providerIcon := widget.NewIcon(theme.FileImageIcon())
+			seenLabel := &widget.Label{}
 			return container.NewVBox(
-				container.NewHBox(notificationAddressLabel, layout.NewSpacer(), seqLabel),
-				container.NewHBox(container.NewVBox(layout.NewSpacer(), operationLabel), providerLabel, providerIcon, iccidLabel),
+				container.NewHBox(notificationAddressLabel, layout.NewSpacer(), retryLabel, seqLabel),
+				container.NewHBox(container.NewVBox(layout.NewSpacer(), operationLabel), providerLabel, providerIcon, iccidLabel,
+					layout.NewSpacer(), seenLabel),
 			)
 		},
 		UpdateItem: func(i widget.ListItemID, o fyne.CanvasObject) {
 			notificationAddressLabel := o.(*fyne.Container).Objects[0].(*fyne.Container).Objects[0].(*widget.Label)
-			seqLabel := o.(*fyne.Container).Objects[0].(*fyne.Container).Objects[2].(*widget.Label)
+			retryLabel := o.(*fyne.Container).Objects[0].(*fyne.Container).Objects[2].(*widget.Label)
+			seqLabel := o.(*fyne.Container).Objects[0].(*fyne.Container).Objects[3].(*widget.Label)
 			iccidLabel := o.(*fyne.Container).Objects[1].(*fyne.Container).Objects[3].(*widget.Label)
 			operationLabel := o.(*fyne.Container).Objects[1].(*fyne.Container).Objects[0].(*fyne.Container).Objects[1].(*widget.Label)
 			providerLabel := o.(*fyne.Container).Objects[1].(*fyne.Container).Objects[1].(*widget.Label)
 			providerIcon := o.(*fyne.Container).Objects[1].(*fyne.Container).Objects[2].(*widget.Icon)
+			seenLabel := o.(*fyne.Container).Objects[1].(*fyne.Container).Objects[5].(*widget.Label)
 
 			iccid := Notifications[i].Iccid
 			notificationAddress := Notifications[i].NotificationAddress
@@ -857,11 +1663,37 @@ func initNotificationList() *widget.List {
 			if iccid == "" {
 				iccid = TR.Trans("label.no_iccid")
 			}
-			iccidLabel.SetText(fmt.Sprint("(", iccid, ")"))
+			flag, operator := iccidIssuer(Notifications[i].Iccid)
+			iccidLabel.SetText(strings.TrimSpace(fmt.Sprint(flag, " (", iccid, ")")))
 			// Notification Address
 			notificationAddressLabel.SetText(notificationAddress)
 			// Seq number
 			seqLabel.SetText(fmt.Sprint(TR.Trans("label.info_seq")+" ", Notifications[i].SeqNumber))
+			// 等待重试
+			if item, ok := NotificationRetries.Find(currentEid(), Notifications[i].SeqNumber); ok {
+				retryLabel.Importance = widget.WarningImportance
+				if item.Stale(time.Now()) {
+					retryLabel.Importance = widget.DangerImportance
+				}
+				retryLabel.SetText(TR.Trans("label.notification_retry_badge", mf.Arg("attempts", item.Attempts),
+					mf.Arg("class", item.ErrorClass), mf.Arg("time", item.NextAttempt.Format("01-02 15:04"))))
+				retryLabel.Show()
+			} else {
+				retryLabel.Hide()
+			}
+			// 第一次看到的时间和来源
+			if seen, ok := NotificationsSeen.Find(currentEid(), Notifications[i].SeqNumber); ok {
+				age := seen.Age(time.Now())
+				seenLabel.Importance = widget.MediumImportance
+				if after := ConfigInstance.NotificationWarnAfter; after > 0 && age >= after {
+					seenLabel.Importance = widget.WarningImportance
+				}
+				seenLabel.SetText(TR.Trans("label.notification_seen", mf.Arg("origin", notificationOriginName(seen.Origin)),
+					mf.Arg("age", notificationAgeText(age))))
+				seenLabel.Show()
+			} else {
+				seenLabel.Hide()
+			}
 			// Operation
 			switch Notifications[i].ProfileManagementOperation {
 			case "enable":
@@ -876,7 +1708,11 @@ func initNotificationList() *widget.List {
 			// Provider
 			profile, err := findProfileByIccid(Notifications[i].Iccid)
 			if err != nil {
-				providerLabel.SetText(TR.Trans("label.deleted_profile"))
+				if operator != "" {
+					providerLabel.SetText(TR.Trans("label.deleted_profile") + " " + operator)
+				} else {
+					providerLabel.SetText(TR.Trans("label.deleted_profile"))
+				}
 				providerIcon.Hide()
 			} else {
 				name := profile.ServiceProviderName
@@ -884,12 +1720,7 @@ func initNotificationList() *widget.List {
 					name = *profile.ProfileNickname
 				}
 				providerLabel.SetText(name)
-				if profile.Icon != nil {
-					providerIcon.SetResource(fyne.NewStaticResource(profile.Iccid, profile.Icon))
-					providerIcon.Show()
-				} else {
-					providerIcon.Hide()
-				}
+				setProfileIcon(providerIcon, profile)
 			}
 		},
 		OnSelected: func(id widget.ListItemID) {
@@ -900,8 +1731,107 @@ func initNotificationList() *widget.List {
 		}}
 }
 
+// RefreshNotificationGroupOptions 按当前语言设置分组方式的选项
+func RefreshNotificationGroupOptions() {
+	index := max(NotificationGroupSelect.SelectedIndex(), 0)
+	NotificationGroupSelect.SetOptions([]string{
+		TR.Trans("label.notification_group_none"),
+		TR.Trans("label.notification_group_address"),
+		TR.Trans("label.notification_group_iccid"),
+	})
+	NotificationGroupSelect.SetSelectedIndex(index)
+}
+
+func initNotificationGroupList() *widget.List {
+	list := &widget.List{
+		Length: func() int {
+			return len(NotificationGroups)
+		},
+		CreateItem: func() fyne.CanvasObject {
+			return container.NewVBox(
+				container.NewHBox(&widget.Label{TextStyle: fyne.TextStyle{Bold: true}}, layout.NewSpacer(), &widget.Label{}, &widget.Label{}),
+				container.NewBorder(nil, nil, nil,
+					container.NewHBox(
+						&widget.Button{Icon: theme.MediaPlayIcon()},
+						&widget.Button{Icon: theme.DeleteIcon()}),
+					&widget.Label{Truncation: fyne.TextTruncateEllipsis}),
+			)
+		},
+		UpdateItem: func(i widget.ListItemID, o fyne.CanvasObject) {
+			group := NotificationGroups[i]
+			header := o.(*fyne.Container).Objects[0].(*fyne.Container)
+			keyLabel := header.Objects[0].(*widget.Label)
+			healthLabel := header.Objects[2].(*widget.Label)
+			countLabel := header.Objects[3].(*widget.Label)
+			row := o.(*fyne.Container).Objects[1].(*fyne.Container)
+			detailLabel := row.Objects[0].(*widget.Label)
+			buttons := row.Objects[1].(*fyne.Container)
+			processButton := buttons.Objects[0].(*widget.Button)
+			removeButton := buttons.Objects[1].(*widget.Button)
+
+			key := group.Key
+			switch {
+			case NotificationGroupBy == GroupIccid && key == "":
+				key = TR.Trans("label.no_iccid")
+			case NotificationMaskNeeded && NotificationGroupBy == GroupIccid:
+				key = group.Notifications[0].MaskedICCID()
+			case NotificationMaskNeeded:
+				key = maskFQDNExceptPublicSuffix(key)
+			}
+			keyLabel.SetText(key)
+			countLabel.SetText(TR.Trans("label.notification_group_count", mf.Arg("count", len(group.Notifications))))
+			// 根据最近的发送结果显示 SM-DP+ 的状态
+			health := ServerHealths.GroupHealth(group)
+			switch health.State {
+			case ServerHealthy:
+				healthLabel.Importance = widget.SuccessImportance
+			case ServerFailing:
+				healthLabel.Importance = widget.WarningImportance
+			case ServerDown:
+				healthLabel.Importance = widget.DangerImportance
+			}
+			if health.State == ServerUnknown {
+				healthLabel.Hide()
+			} else {
+				healthLabel.SetText(TR.Trans("label.notification_health_"+health.State.String(),
+					mf.Arg("failures", health.Failures), mf.Arg("time", health.LastAttempt.Format("15:04"))))
+				healthLabel.Show()
+			}
+			var details []string
+			for _, n := range group.Notifications {
+				details = append(details, fmt.Sprintf("%d %s", n.SeqNumber, n.ProfileManagementOperation))
+			}
+			detailLabel.SetText(strings.Join(details, ", "))
+
+			processButton.SetText(TR.Trans("label.process_notification_button"))
+			processButton.OnTapped = func() {
+				showProcessNotificationsDialog(key, group.Notifications)
+			}
+			removeButton.SetText(TR.Trans("label.remove_notification_button"))
+			removeButton.OnTapped = func() {
+				dialog.ShowConfirm(TR.Trans("dialog.confirm"),
+					TR.Trans("message.notification_group_remove_confirm", mf.Arg("count", len(group.Notifications)), mf.Arg("group", key)),
+					func(b bool) {
+						if b {
+							ShowNotificationBatchDialog(key, NewNotificationBatch(group.Notifications,
+								func(*Notification) NotificationAction { return ActionRemove }))
+						}
+					}, WMain)
+			}
+		},
+	}
+	list.OnSelected = func(widget.ListItemID) { list.UnselectAll() }
+	return list
+}
+
 func processNotificationManually(seq int) {
-	if err := LpacNotificationProcess(seq, false); err != nil {
+	var err error
+	if n := findNotificationBySeq(Notifications, seq); n != nil {
+		err = sendNotification(n, false)
+	} else {
+		err = LpacNotificationProcess(seq, false)
+	}
+	if err != nil {
 		ShowLpacErrDialog(err)
 		err2 := RefreshNotification()
 		if err2 != nil {
@@ -968,31 +1898,66 @@ func processNotificationManually(seq int) {
 	}
 }
 
-func findNewNotification(origin, new []*Notification) *Notification {
-	exists := make(map[int]bool)
-	for _, notification := range origin {
-		exists[notification.SeqNumber] = true
+// profileIconCache 以图标内容为键缓存重新编码的图标，无法解码的图标缓存为 nil
+var profileIconCache = make(map[string]fyne.Resource)
+var profileIconCacheLock sync.Mutex
+
+// ProfileIconResource 返回 Profile 图标重新编码为 PNG 后的资源，没有图标或图标不合法时返回 nil
+func ProfileIconResource(p *Profile) fyne.Resource {
+	if len(p.Icon) == 0 {
+		return nil
+	}
+	key := p.IconType + ":" + string(p.Icon)
+	profileIconCacheLock.Lock()
+	defer profileIconCacheLock.Unlock()
+	if res, ok := profileIconCache[key]; ok {
+		return res
 	}
-	for _, notification := range new {
-		if !exists[notification.SeqNumber] {
-			return notification
+	var res fyne.Resource
+	if img, err := DecodeProfileIcon(p.IconType, p.Icon); err == nil {
+		var buf bytes.Buffer
+		if err = png.Encode(&buf, img); err == nil {
+			res = fyne.NewStaticResource(p.Iccid+".png", buf.Bytes())
 		}
 	}
-	return nil
+	profileIconCache[key] = res
+	return res
 }
 
-func findNewNotifications(origin, new []*Notification) []*Notification {
-	exists := make(map[int]bool)
-	var foundNotifications []*Notification
-	for _, notification := range origin {
-		exists[notification.SeqNumber] = true
+// setProfileIcon 显示 Profile 的图标，图标不合法时显示默认图标，没有图标时隐藏
+func setProfileIcon(icon *widget.Icon, p *Profile) {
+	switch res := ProfileIconResource(p); {
+	case res != nil:
+		icon.SetResource(res)
+		icon.Show()
+	case len(p.Icon) > 0:
+		icon.SetResource(theme.FileImageIcon())
+		icon.Show()
+	default:
+		icon.Hide()
 	}
-	for _, notification := range new {
-		if !exists[notification.SeqNumber] {
-			foundNotifications = append(foundNotifications, notification)
-		}
+}
+
+// profileSummary 用于确认对话框，显示 Profile 的图标、ICCID、运营商和昵称
+func profileSummary(p *Profile) fyne.CanvasObject {
+	text := fmt.Sprint(
+		TR.Trans("label.info_iccid")+" ", p.Iccid, "\n",
+		TR.Trans("label.info_provider")+" ", p.ServiceProviderName,
+	)
+	if p.ProfileNickname != nil {
+		text += fmt.Sprint("\n", TR.Trans("label.info_nickname")+" ", *p.ProfileNickname)
+	}
+	icon := widget.NewIcon(nil)
+	setProfileIcon(icon, p)
+	return container.NewHBox(icon, &widget.Label{Text: text})
+}
+
+// iccidIssuer 按 IIN 返回 ICCID 所属国家的旗帜和运营商，未知时返回空字符串
+func iccidIssuer(iccid string) (flag, operator string) {
+	if iin := GetIIN(iccid); iin != nil {
+		return CountryCodeToEmoji(iin.Country), iin.Operator
 	}
-	return foundNotifications
+	return "", ""
 }
 
 func findProfileByIccid(iccid string) (*Profile, error) {
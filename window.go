@@ -1,8 +1,15 @@
 package main
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"image/color"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
 	"strings"
 	"time"
 
@@ -14,6 +21,7 @@ import (
 	"fyne.io/fyne/v2/layout"
 	"fyne.io/fyne/v2/theme"
 	"fyne.io/fyne/v2/widget"
+	"github.com/fullpipe/icu-mf/mf"
 	"github.com/makiuchi-d/gozxing"
 	nativeDialog "github.com/sqweek/dialog"
 	"golang.design/x/clipboard"
@@ -22,6 +30,29 @@ import (
 var WMain fyne.Window
 var spacer *canvas.Rectangle
 
+// confirmClose 在当前卡片上还有长时间未发送的通知时确认是否退出
+func confirmClose(w fyne.Window) {
+	eid := currentEid()
+	var overdue []SeenNotification
+	if eid != "" {
+		overdue = NotificationsSeen.Overdue(eid, ConfigInstance.NotificationWarnAfter)
+	}
+	if len(overdue) == 0 {
+		w.Close()
+		return
+	}
+	d := dialog.NewConfirm(TR.Trans("dialog.notification_overdue"),
+		TR.Trans("message.notification_overdue_exit", mf.Arg("count", len(overdue)))+"\n\n"+overdueNotificationsText(overdue),
+		func(quit bool) {
+			if quit {
+				w.Close()
+			}
+		}, w)
+	d.SetConfirmText(TR.Trans("dialog.quit"))
+	d.SetDismissText(TR.Trans("dialog.cancel"))
+	d.Show()
+}
+
 func InitMainWindow() fyne.Window {
 	w := App.NewWindow("EasyLPAC")
 	w.Resize(fyne.Size{
@@ -29,11 +60,12 @@ func InitMainWindow() fyne.Window {
 		Height: 545,
 	})
 	w.SetMaster()
+	w.SetCloseIntercept(func() { confirmClose(w) })
 
-	statusBar := container.NewGridWrap(fyne.Size{
-		Width:  100,
+	statusBar := container.NewHBox(container.NewGridWrap(fyne.Size{
+		Width:  200,
 		Height: DownloadButton.MinSize().Height,
-	}, StatusLabel, StatusProcessBar)
+	}, StatusLabel, StatusProcessBar), CancelButton)
 
 	spacer = canvas.NewRectangle(color.Transparent)
 	spacer.SetMinSize(fyne.NewSize(1, 1))
@@ -68,7 +100,12 @@ func InitMainWindow() fyne.Window {
 			statusBar),
 		nil,
 		nil,
-		ProfileList)
+		container.NewBorder(
+			container.NewBorder(nil, nil, ProfileCheckAllButton,
+				container.NewHBox(ProfileFilterLabel, ProfileStateSelect, ProfileClassSelect, ProfileSortSelect),
+				ProfileSearchEntry),
+			BulkActionBar, nil, nil,
+			ProfileList))
 	ProfileTab = container.NewTabItem(TR.Trans("tab_bar.profile"), profileTabContent)
 
 	notificationTabContent := container.NewBorder(
@@ -77,7 +114,7 @@ func InitMainWindow() fyne.Window {
 			nil,
 			nil,
 			nil,
-			container.NewHBox(NotificationMaskCheck,
+			container.NewHBox(NotificationMaskCheck, NotificationGroupSelect,
 				spacer, ProcessNotificationButton,
 				spacer, ProcessAllNotificationButton,
 				spacer, BatchRemoveNotificationButton,
@@ -85,7 +122,7 @@ func InitMainWindow() fyne.Window {
 			statusBar),
 		nil,
 		nil,
-		NotificationList)
+		container.NewBorder(NotificationRetryLabel, nil, nil, nil, container.NewStack(NotificationList, NotificationGroupList)))
 	NotificationTab = container.NewTabItem(TR.Trans("tab_bar.notification"), notificationTabContent)
 
 	chipInfoTabContent := container.NewBorder(
@@ -105,7 +142,7 @@ func InitMainWindow() fyne.Window {
 				container.NewHBox(
 					DefaultDpAddressLabel, SetDefaultSmdpButton, layout.NewSpacer(), ViewCertInfoButton),
 				container.NewHBox(
-					RootDsAddressLabel, layout.NewSpacer(), CopyEuiccInfo2Button)),
+					RootDsAddressLabel, layout.NewSpacer(), ExportReportButton, CopyEuiccInfo2Button)),
 			nil,
 			nil,
 			nil,
@@ -113,6 +150,19 @@ func InitMainWindow() fyne.Window {
 		))
 	ChipInfoTab = container.NewTabItem(TR.Trans("tab_bar.chip_info"), chipInfoTabContent)
 
+	activityTabContent := container.NewBorder(
+		nil,
+		container.NewBorder(
+			nil,
+			nil,
+			nil,
+			container.NewHBox(ClearActivityButton, spacer, CancelJobButton),
+			statusBar),
+		nil,
+		nil,
+		ActivityList)
+	ActivityTab = container.NewTabItem(TR.Trans("tab_bar.activity"), activityTabContent)
+
 	aidEntryHint := &widget.Label{Text: TR.Trans("label.aid_valid")}
 	aidEntry := &widget.Entry{
 		Text: ConfigInstance.LpacAID,
@@ -121,6 +171,7 @@ func InitMainWindow() fyne.Window {
 			validation.NewRegexp(`[[:xdigit:]]{32}`, TR.Trans("message.aid_not_hex")),
 		),
 	}
+	AidEntry = aidEntry
 	aidEntry.OnChanged = func(s string) {
 		val := aidEntry.Validate()
 		if val != nil {
@@ -128,6 +179,7 @@ func InitMainWindow() fyne.Window {
 		} else {
 			// Use last known good value only
 			ConfigInstance.LpacAID = s
+			ConfigChanged()
 			aidEntryHint.SetText(TR.Trans("label.aid_valid"))
 		}
 	}
@@ -152,6 +204,168 @@ func InitMainWindow() fyne.Window {
 			aidEntry.SetText(AID_XESIM)
 		})
 	
+	timeoutEntry := func(target *time.Duration) fyne.CanvasObject {
+		entry := &widget.Entry{
+			Text:      strconv.Itoa(int(target.Seconds())),
+			Validator: validation.NewRegexp(`^[1-9][0-9]*$`, TR.Trans("message.timeout_illegal")),
+		}
+		entry.OnChanged = func(s string) {
+			if entry.Validate() == nil {
+				seconds, _ := strconv.Atoi(s)
+				*target = time.Duration(seconds) * time.Second
+				ConfigChanged()
+			}
+		}
+		return container.NewGridWrap(fyne.Size{Width: 80, Height: entry.MinSize().Height}, entry)
+	}
+
+	// 0 表示切换后不等待确认
+	switchGraceEntry := &widget.Entry{
+		Text:      strconv.Itoa(int(ConfigInstance.SwitchGracePeriod.Seconds())),
+		Validator: validation.NewRegexp(`^[0-9]+$`, TR.Trans("message.switch_grace_period_illegal")),
+	}
+	switchGraceEntry.OnChanged = func(s string) {
+		if switchGraceEntry.Validate() == nil {
+			seconds, _ := strconv.Atoi(s)
+			ConfigInstance.SwitchGracePeriod = time.Duration(seconds) * time.Second
+			ConfigChanged()
+		}
+	}
+
+	// 以小时为单位，0 表示不提醒
+	notificationWarnEntry := &widget.Entry{
+		Text:      strconv.Itoa(int(ConfigInstance.NotificationWarnAfter.Hours())),
+		Validator: validation.NewRegexp(`^[0-9]+$`, TR.Trans("message.notification_warn_after_illegal")),
+	}
+	notificationWarnEntry.OnChanged = func(s string) {
+		if notificationWarnEntry.Validate() == nil {
+			hours, _ := strconv.Atoi(s)
+			ConfigInstance.NotificationWarnAfter = time.Duration(hours) * time.Hour
+			ConfigChanged()
+		}
+	}
+
+	apduDeviceEntry := &widget.Entry{Text: ConfigInstance.ApduDevice, PlaceHolder: "/dev/ttyUSB2"}
+	apduDeviceEntry.OnChanged = func(s string) {
+		ConfigInstance.ApduDevice = strings.TrimSpace(s)
+		ConfigChanged()
+	}
+	uimSlotEntry := &widget.Entry{
+		Text:      strconv.Itoa(ConfigInstance.UimSlot),
+		Validator: validation.NewRegexp(`^[1-9][0-9]*$`, TR.Trans("message.uim_slot_illegal")),
+	}
+	uimSlotEntry.OnChanged = func(s string) {
+		if uimSlotEntry.Validate() == nil {
+			ConfigInstance.UimSlot, _ = strconv.Atoi(s)
+			ConfigChanged()
+		}
+	}
+	mbimProxyCheck := &widget.Check{
+		Text:    TR.Trans("label.mbim_proxy_check"),
+		Checked: ConfigInstance.MbimProxy,
+		OnChanged: func(b bool) {
+			ConfigInstance.MbimProxy = b
+			ConfigChanged()
+		},
+	}
+	apduDeviceRow := container.NewHBox(
+		widget.NewLabel(TR.Trans("label.apdu_device")),
+		container.NewGridWrap(fyne.Size{Width: 240, Height: apduDeviceEntry.MinSize().Height}, apduDeviceEntry))
+	uimSlotRow := container.NewHBox(
+		widget.NewLabel(TR.Trans("label.uim_slot")),
+		container.NewGridWrap(fyne.Size{Width: 80, Height: uimSlotEntry.MinSize().Height}, uimSlotEntry),
+		mbimProxyCheck)
+	// 只显示当前 APDU 驱动需要的参数
+	updateBackendRows := func() {
+		backend := CurrentApduBackend()
+		apduDeviceRow.Hidden = !backend.Device
+		uimSlotRow.Hidden = !backend.Slot
+		mbimProxyCheck.Hidden = backend.Name != "mbim"
+		apduDeviceRow.Refresh()
+		uimSlotRow.Refresh()
+	}
+	updateBackendRows()
+	var apduBackendOptions []string
+	for _, b := range AvailableApduBackends() {
+		apduBackendOptions = append(apduBackendOptions, b.Label)
+	}
+	apduBackendSelect := &widget.Select{
+		Options:  apduBackendOptions,
+		Selected: CurrentApduBackend().Label,
+		OnChanged: func(label string) {
+			for _, b := range ApduBackends {
+				if b.Label == label && b.Name != ConfigInstance.ApduBackend {
+					ConfigInstance.ApduBackend = b.Name
+					ConfigChanged()
+					updateBackendRows()
+					// 不同驱动的读卡器列表不同，需要重新选择
+					RefreshNeeded = true
+					go RefreshApduDriver()
+				}
+			}
+		},
+	}
+	httpBackendSelect := &widget.Select{
+		Options:  HttpBackends,
+		Selected: ConfigInstance.HttpBackend,
+		OnChanged: func(s string) {
+			ConfigInstance.HttpBackend = s
+			ConfigChanged()
+		},
+	}
+
+	APIStatusLabel = &widget.Label{Wrapping: fyne.TextWrapWord}
+	apiListenEntry := &widget.Entry{Text: ConfigInstance.APIListen, PlaceHolder: DefaultAPIListen}
+	apiListenEntry.Validator = func(s string) error {
+		if validateAPIListen(s) != nil {
+			return errors.New(TR.Trans("message.api_listen_illegal"))
+		}
+		return nil
+	}
+	apiListenEntry.OnChanged = func(s string) {
+		if apiListenEntry.Validate() == nil {
+			ConfigInstance.APIListen = s
+			ConfigChanged()
+		}
+	}
+	apiTokenEntry := NewReadOnlyEntry()
+	apiTokenEntry.SetText(ConfigInstance.APIToken)
+	// 修改地址或 token 后重新启动正在运行的服务
+	restartAPIServer := func() {
+		if ConfigInstance.APIEnabled {
+			UpdateAPIStatus(StartAPIServer())
+		}
+	}
+	apiListenEntry.OnSubmitted = func(string) {
+		if apiListenEntry.Validate() == nil {
+			restartAPIServer()
+		}
+	}
+	apiServerCheck := &widget.Check{
+		Text:    TR.Trans("label.api_server_check"),
+		Checked: ConfigInstance.APIEnabled,
+		OnChanged: func(b bool) {
+			ConfigInstance.APIEnabled = b
+			ConfigChanged()
+			if b {
+				UpdateAPIStatus(StartAPIServer())
+				apiTokenEntry.SetText(ConfigInstance.APIToken)
+			} else {
+				StopAPIServer()
+				UpdateAPIStatus(nil)
+			}
+		},
+	}
+	copyAPITokenButton := widget.NewButtonWithIcon(TR.Trans("label.api_token_copy"), theme.ContentCopyIcon(), func() {
+		WMain.Clipboard().SetContent(ConfigInstance.APIToken)
+	})
+	regenerateAPITokenButton := widget.NewButtonWithIcon(TR.Trans("label.api_token_regenerate"), theme.ViewRefreshIcon(), func() {
+		ConfigInstance.APIToken = NewAPIToken()
+		ConfigChanged()
+		apiTokenEntry.SetText(ConfigInstance.APIToken)
+		restartAPIServer()
+	})
+
 	// AID列表选择按钮
 	selectFromAidListButton := widget.NewButton(
 		TR.Trans("label.aid_select_from_list_button"),
@@ -173,30 +387,76 @@ func InitMainWindow() fyne.Window {
 			selectFromAidListButton),
 		aidEntryHint,
 
+		&widget.Label{Text: TR.Trans("label.lpac_backend"), TextStyle: fyne.TextStyle{Bold: true}},
+		container.NewHBox(
+			widget.NewLabel(TR.Trans("label.apdu_backend")), apduBackendSelect,
+			widget.NewLabel(TR.Trans("label.http_backend")), httpBackendSelect),
+		apduDeviceRow,
+		uimSlotRow,
+
 		&widget.Label{Text: TR.Trans("label.lpac_debug_output"), TextStyle: fyne.TextStyle{Bold: true}},
 		&widget.Check{
 			Text:    TR.Trans("label.enable_env_LIBEUICC_DEBUG_HTTP_check"),
-			Checked: false,
+			Checked: ConfigInstance.DebugHTTP,
 			OnChanged: func(b bool) {
 				ConfigInstance.DebugHTTP = b
+				ConfigChanged()
 			},
 		},
 		&widget.Check{
 			Text:    TR.Trans("label.enable_env_LIBEUICC_DEBUG_APDU_check"),
-			Checked: false,
+			Checked: ConfigInstance.DebugAPDU,
 			OnChanged: func(b bool) {
 				ConfigInstance.DebugAPDU = b
+				ConfigChanged()
 			},
 		},
 
+		&widget.Label{Text: TR.Trans("label.lpac_timeouts"), TextStyle: fyne.TextStyle{Bold: true}},
+		container.NewHBox(
+			widget.NewLabel(TR.Trans("label.timeout_query")), timeoutEntry(&ConfigInstance.Timeouts.Query),
+			widget.NewLabel(TR.Trans("label.timeout_card")), timeoutEntry(&ConfigInstance.Timeouts.Card),
+			widget.NewLabel(TR.Trans("label.timeout_network")), timeoutEntry(&ConfigInstance.Timeouts.Network)),
+
 		&widget.Label{Text: TR.Trans("label.easylpac_settings"), TextStyle: fyne.TextStyle{Bold: true}},
+		container.NewHBox(
+			&widget.Button{Text: TR.Trans("label.notification_policy_button"), Icon: theme.MailComposeIcon(), OnTapped: ShowNotificationPolicyDialog},
+			widget.NewLabel(TR.Trans("label.notification_policy_hint"))),
+		container.NewHBox(
+			widget.NewLabel(TR.Trans("label.notification_warn_after")),
+			container.NewGridWrap(fyne.Size{Width: 80, Height: notificationWarnEntry.MinSize().Height}, notificationWarnEntry),
+			widget.NewLabel(TR.Trans("label.notification_warn_after_hint"))),
 		&widget.Check{
-			Text:    TR.Trans("label.auto_process_notification_check"),
-			Checked: true,
+			Text:    TR.Trans("label.hide_test_profiles_check"),
+			Checked: ConfigInstance.HideTestProfiles,
 			OnChanged: func(b bool) {
-				ConfigInstance.AutoMode = b
+				ConfigInstance.HideTestProfiles = b
+				ConfigChanged()
+				ApplyProfileFilter()
 			},
 		},
+		container.NewHBox(
+			widget.NewLabel(TR.Trans("label.switch_grace_period")),
+			container.NewGridWrap(fyne.Size{Width: 80, Height: switchGraceEntry.MinSize().Height}, switchGraceEntry),
+			widget.NewLabel(TR.Trans("label.switch_grace_period_hint"))),
+		&widget.Label{Text: TR.Trans("label.config_file") + " " + ConfigInstance.ConfigPath, Truncation: fyne.TextTruncateEllipsis},
+
+		&widget.Label{Text: TR.Trans("label.api_server"), TextStyle: fyne.TextStyle{Bold: true}},
+		apiServerCheck,
+		container.NewHBox(
+			widget.NewLabel(TR.Trans("label.api_listen")),
+			container.NewGridWrap(fyne.Size{Width: 240, Height: apiListenEntry.MinSize().Height}, apiListenEntry)),
+		container.NewHBox(
+			widget.NewLabel(TR.Trans("label.api_token")),
+			container.NewGridWrap(fyne.Size{Width: 420, Height: apiTokenEntry.MinSize().Height}, apiTokenEntry),
+			copyAPITokenButton,
+			regenerateAPITokenButton),
+		APIStatusLabel,
+
+		&widget.Label{Text: TR.Trans("label.schedule_settings"), TextStyle: fyne.TextStyle{Bold: true}},
+		container.NewHBox(
+			&widget.Button{Text: TR.Trans("label.schedule_manage_button"), Icon: theme.HistoryIcon(), OnTapped: ShowScheduleDialog},
+			widget.NewLabel(TR.Trans("label.schedule_hint"))),
 		
 		&widget.Label{Text: TR.Trans("label.language_settings"), TextStyle: fyne.TextStyle{Bold: true}},
 		container.NewHBox(
@@ -247,6 +507,7 @@ func InitMainWindow() fyne.Window {
 						// 根据语言代码切换语言
 						if langCode == "auto" {
 							ConfigInstance.Language = ""
+							ConfigChanged()
 							LanguageTag = detectSystemLanguate()
 							TR = i18nBundle.Translator(LanguageTag)
 							RefreshAllUI()
@@ -277,7 +538,7 @@ func InitMainWindow() fyne.Window {
 				return languageSelect
 			}(),
 		))
-	SettingsTab = container.NewTabItem(TR.Trans("tab_bar.settings"), settingsTabContent)
+	SettingsTab = container.NewTabItem(TR.Trans("tab_bar.settings"), container.NewVScroll(settingsTabContent))
 
 	thankstoText := widget.NewRichTextFromMarkdown(TR.Trans("thanks_to"))
 
@@ -295,7 +556,7 @@ func InitMainWindow() fyne.Window {
 		container.NewCenter(container.NewVBox(thankstoText, aboutText)))
 	AboutTab = container.NewTabItem(TR.Trans("tab_bar.about"), aboutTabContent)
 
-	Tabs = container.NewAppTabs(ProfileTab, NotificationTab, ChipInfoTab, SettingsTab, AboutTab)
+	Tabs = container.NewAppTabs(ProfileTab, NotificationTab, ChipInfoTab, ActivityTab, SettingsTab, AboutTab)
 
 	w.SetContent(Tabs)
 
@@ -306,7 +567,7 @@ func InitDownloadDialog() dialog.Dialog {
 	smdpEntry := &widget.Entry{PlaceHolder: TR.Trans("label.smdp_entry_placeholder")}
 	matchIDEntry := &widget.Entry{PlaceHolder: TR.Trans("label.match_id_entry_placeholder")}
 	confirmCodeEntry := &widget.Entry{PlaceHolder: TR.Trans("label.confirm_code_entry_placeholder")}
-	imeiEntry := &widget.Entry{PlaceHolder: TR.Trans("label.imei_entry_placeholder")}
+	imeiEntry := &widget.Entry{PlaceHolder: TR.Trans("label.imei_entry_placeholder"), Text: CurrentCardMemory().IMEI}
 
 	formItems := []*widget.FormItem{
 		{Text: TR.Trans("label.smdp"), Widget: smdpEntry},
@@ -346,7 +607,7 @@ func InitDownloadDialog() dialog.Dialog {
 					ShowLpacErrDialog(err)
 					return
 				}
-				LpacProfileDownload(pullConfig)
+				downloadProfile(pullConfig)
 			}()
 		},
 	}
@@ -453,29 +714,366 @@ func InitDownloadDialog() dialog.Dialog {
 			}()
 		},
 	}
+	batchDownloadButton := &widget.Button{
+		Text: TR.Trans("label.batch_download_button"),
+		Icon: theme.FileTextIcon(),
+		OnTapped: func() {
+			d.Hide()
+			go selectBatchFile()
+		},
+	}
 	d = dialog.NewCustomWithoutButtons(TR.Trans("label.download_profile_button"), container.NewBorder(
 		nil,
 		container.NewVBox(spacer, container.NewCenter(selectQRCodeButton), spacer,
 			container.NewCenter(pasteFromClipboardButton), spacer,
+			container.NewCenter(batchDownloadButton), spacer,
 			container.NewCenter(container.NewHBox(cancelButton, spacer, downloadButton))),
 		nil,
 		nil,
 		form), WMain)
 	d.Resize(fyne.Size{
 		Width:  520,
-		Height: 380,
+		Height: 420,
 	})
 	return d
 }
 
+// selectBatchFile 选择激活码列表文件并打开批量下载窗口
+func selectBatchFile() {
+	fileBuilder := nativeDialog.File().Title(TR.Trans("dialog.select_batch_file"))
+	fileBuilder.Filters = []nativeDialog.FileFilter{
+		{
+			Desc:       TR.Trans("dialog.batch_file_desc") + " (*.csv, *.json, *.txt)",
+			Extensions: []string{"csv", "CSV", "json", "JSON", "txt", "TXT"},
+		},
+		{
+			Desc:       TR.Trans("dialog.all_files_desc") + " (*.*)",
+			Extensions: []string{"*"},
+		},
+	}
+	filename, err := fileBuilder.Load()
+	if err != nil {
+		if err.Error() != "Cancelled" {
+			dialog.ShowError(err, WMain)
+		}
+		return
+	}
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		dialog.ShowError(err, WMain)
+		return
+	}
+	rows, err := ParseBatchFile(filename, data)
+	if err != nil {
+		dialog.ShowError(fmt.Errorf(TR.Trans("message.batch_file_invalid")+"\n%s", err), WMain)
+		return
+	}
+	ShowBatchDownloadDialog(filepath.Base(filename), NewBatch(rows))
+}
+
+// ShowBatchDownloadDialog 显示批量下载窗口，可以重试失败的行并导出结果
+func ShowBatchDownloadDialog(name string, batch *Batch) {
+	rows := batch.Rows()
+	templateEntry := &widget.Entry{PlaceHolder: TR.Trans("label.set_nickname_entry_placeholder"), Text: CurrentCardMemory().NicknameTemplate}
+	notifyCheck := &widget.Check{Text: TR.Trans("label.batch_send_notifications"), Checked: true}
+	summaryLabel := &widget.Label{}
+
+	headers := []string{TR.Trans("label.batch_line"), TR.Trans("label.batch_code"), TR.Trans("label.batch_status"), TR.Trans("label.batch_result")}
+	table := widget.NewTable(
+		func() (int, int) { return len(rows) + 1, len(headers) },
+		func() fyne.CanvasObject { return &widget.Label{Truncation: fyne.TextTruncateEllipsis} },
+		func(id widget.TableCellID, o fyne.CanvasObject) {
+			label := o.(*widget.Label)
+			if id.Row == 0 {
+				label.TextStyle = fyne.TextStyle{Bold: true}
+				label.SetText(headers[id.Col])
+				return
+			}
+			label.TextStyle = fyne.TextStyle{}
+			row := rows[id.Row-1]
+			switch id.Col {
+			case 0:
+				label.SetText(strconv.Itoa(row.Line))
+			case 1:
+				label.SetText(row.Info.SMDP + " / " + row.Info.MatchID)
+			case 2:
+				label.SetText(TR.Trans("label.batch_status_" + row.Status.String()))
+			case 3:
+				label.SetText(batchRowResult(row))
+			}
+		})
+	for col, width := range []float32{50, 260, 90, 300} {
+		table.SetColumnWidth(col, width)
+	}
+
+	var ctx context.Context
+	var cancel context.CancelFunc
+	var startButton, exportButton, productionButton, closeButton, stopButton *widget.Button
+	update := func() {
+		rows = batch.Rows()
+		counts := batch.Counts()
+		summaryLabel.SetText(TR.Trans("label.batch_summary",
+			mf.Arg("succeeded", counts[BatchSucceeded]), mf.Arg("failed", counts[BatchFailed]),
+			mf.Arg("invalid", counts[BatchInvalid]), mf.Arg("total", len(rows))))
+		if counts[BatchSucceeded] > 0 || counts[BatchFailed] > 0 {
+			startButton.SetText(TR.Trans("label.batch_retry_button"))
+		}
+		if counts[BatchPending] == 0 && counts[BatchFailed] == 0 {
+			startButton.Disable()
+		}
+		table.Refresh()
+	}
+	startButton = &widget.Button{
+		Text:       TR.Trans("label.batch_start_button"),
+		Icon:       theme.DownloadIcon(),
+		Importance: widget.HighImportance,
+		OnTapped: func() {
+			if ConfigInstance.DriverIFID == "" {
+				ShowSelectCardReaderDialog()
+				return
+			}
+			batch.NicknameTemplate = strings.TrimSpace(templateEntry.Text)
+			batch.Notify = notifyCheck.Checked
+			ctx, cancel = context.WithCancel(context.Background())
+			startButton.Disable()
+			exportButton.Disable()
+			productionButton.Disable()
+			closeButton.Disable()
+			stopButton.Enable()
+			go func() {
+				batch.Run(ctx, update)
+				cancel()
+				stopButton.Disable()
+				startButton.Enable()
+				exportButton.Enable()
+				productionButton.Enable()
+				closeButton.Enable()
+				update()
+				Refresh()
+				var pending []*Notification
+				for _, row := range batch.Rows() {
+					pending = append(pending, PendingNotifications(row.Notifications)...)
+				}
+				ShowPendingNotificationsDialog(TR.Trans("label.batch_download_button"), pending)
+			}()
+		},
+	}
+	stopButton = &widget.Button{
+		Text: TR.Trans("dialog.cancel"),
+		Icon: theme.MediaStopIcon(),
+		OnTapped: func() {
+			cancel()
+			go CardJobs.CancelRunning()
+		},
+	}
+	stopButton.Disable()
+	exportButton = &widget.Button{
+		Text: TR.Trans("label.batch_export_button"),
+		Icon: theme.DocumentSaveIcon(),
+		OnTapped: func() {
+			go exportBatchReport(batch)
+		},
+	}
+
+	var d dialog.Dialog
+	productionButton = &widget.Button{
+		Text: TR.Trans("label.production_button"),
+		Icon: theme.MediaReplayIcon(),
+		OnTapped: func() {
+			if ConfigInstance.DriverIFID == "" {
+				ShowSelectCardReaderDialog()
+				return
+			}
+			batch.NicknameTemplate = strings.TrimSpace(templateEntry.Text)
+			batch.Notify = notifyCheck.Checked
+			d.Hide()
+			go func() {
+				ShowProductionDialog(batch, selectProductionResultsFile(), func() {
+					update()
+					d.Show()
+				})
+			}()
+		},
+	}
+	closeButton = &widget.Button{
+		Text:     TR.Trans("dialog.close"),
+		OnTapped: func() { d.Hide() },
+	}
+	form := widget.NewForm(
+		&widget.FormItem{Text: TR.Trans("label.batch_file"), Widget: widget.NewLabel(name)},
+		&widget.FormItem{Text: TR.Trans("label.set_nickname_button"), Widget: templateEntry,
+			HintText: TR.Trans("label.nickname_template_hint")},
+		&widget.FormItem{Widget: notifyCheck},
+	)
+	d = dialog.NewCustomWithoutButtons(TR.Trans("label.batch_download_button"), container.NewBorder(
+		form,
+		container.NewVBox(summaryLabel,
+			container.NewCenter(container.NewHBox(closeButton, exportButton, productionButton, stopButton, startButton))),
+		nil, nil, table), WMain)
+	d.Resize(fyne.Size{Width: 760, Height: 560})
+	update()
+	d.Show()
+}
+
+// selectProductionResultsFile 选择保存 EID 和 ICCID 对应关系的 CSV 文件，取消时不保存
+func selectProductionResultsFile() string {
+	fileBuilder := nativeDialog.File().Title(TR.Trans("dialog.select_production_results"))
+	fileBuilder.Filters = []nativeDialog.FileFilter{{Desc: "CSV (*.csv)", Extensions: []string{"csv"}}}
+	filename, err := fileBuilder.Save()
+	if err != nil {
+		return ""
+	}
+	if filepath.Ext(filename) == "" {
+		filename += ".csv"
+	}
+	return filename
+}
+
+// ShowProductionDialog 显示产线模式窗口，每插入一张卡片下载一个激活码，关闭后调用 onClose
+func ShowProductionDialog(batch *Batch, resultsFile string, onClose func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+	promptLabel := &widget.Label{Alignment: fyne.TextAlignCenter, TextStyle: fyne.TextStyle{Bold: true}, Wrapping: fyne.TextWrapWord}
+	remainingLabel := &widget.Label{Alignment: fyne.TextAlignCenter}
+	resultsLabel := &widget.Label{Text: TR.Trans("label.production_results_file") + " " + resultsFile}
+	if resultsFile == "" {
+		resultsLabel.SetText(TR.Trans("label.production_results_not_saved"))
+	}
+	var log []string
+	logList := widget.NewList(
+		func() int { return len(log) },
+		func() fyne.CanvasObject { return &widget.Label{Truncation: fyne.TextTruncateEllipsis} },
+		func(i widget.ListItemID, o fyne.CanvasObject) { o.(*widget.Label).SetText(log[len(log)-1-i]) })
+
+	var d dialog.Dialog
+	stopButton := &widget.Button{
+		Text: TR.Trans("label.production_stop_button"),
+		Icon: theme.MediaStopIcon(),
+		OnTapped: func() {
+			cancel()
+			go CardJobs.CancelRunning()
+		},
+	}
+	line := &ProductionLine{
+		Batch:       batch,
+		ResultsFile: resultsFile,
+		OnEvent: func(e ProductionEvent) {
+			remainingLabel.SetText(TR.Trans("label.production_remaining", mf.Arg("count", e.Remaining)))
+			switch {
+			case e.State == ProductionWaitingCard:
+				promptLabel.SetText(TR.Trans("message.production_insert_card"))
+			case e.State == ProductionProvisioning:
+				promptLabel.SetText(TR.Trans("message.production_provisioning", mf.Arg("eid", e.EID)))
+			case e.State == ProductionFinished:
+				promptLabel.SetText(TR.Trans("message.production_finished"))
+			case e.Duplicate:
+				promptLabel.SetText(TR.Trans("message.production_duplicate", mf.Arg("eid", e.EID)))
+			case e.Result != nil && e.Result.Row.Status == BatchSucceeded:
+				promptLabel.SetText(TR.Trans("message.production_succeeded", mf.Arg("iccid", e.Result.Row.Iccid)))
+			case e.Result != nil:
+				promptLabel.SetText(TR.Trans("message.production_failed", mf.Arg("reason", batchRowResult(e.Result.Row))))
+			default:
+				promptLabel.SetText(TR.Trans("message.production_card_error"))
+			}
+			if e.Result != nil {
+				log = append(log, fmt.Sprintf("%s  %s  %s  %s", e.Result.Time.Format(time.TimeOnly), e.EID,
+					TR.Trans("label.batch_status_"+e.Result.Row.Status.String()), batchRowResult(e.Result.Row)))
+				logList.Refresh()
+			}
+			if e.Err != nil {
+				log = append(log, e.Err.Error())
+				logList.Refresh()
+			}
+		},
+	}
+	d = dialog.NewCustomWithoutButtons(TR.Trans("label.production_button"), container.NewBorder(
+		container.NewVBox(promptLabel, remainingLabel, widget.NewSeparator()),
+		container.NewVBox(resultsLabel, container.NewCenter(stopButton)),
+		nil, nil, logList), WMain)
+	d.Resize(fyne.Size{Width: 640, Height: 480})
+	d.Show()
+
+	_ = line.Run(ctx)
+	cancel()
+	d.Hide()
+	Refresh()
+	onClose()
+}
+
+// batchRowResult 返回成功行的 ICCID 或失败的原因
+func batchRowResult(row BatchRow) string {
+	if row.Err != nil {
+		if lpacErr, ok := AsLpacError(row.Err); ok {
+			if explanation, _ := lpacErr.Explanation(); explanation != "" {
+				return explanation
+			}
+		}
+		return strings.TrimSpace(row.Err.Error())
+	}
+	result := row.Iccid
+	for _, outcome := range row.Notifications {
+		if outcome.Err != nil {
+			result += " " + TR.Trans("label.batch_notification_failed")
+			break
+		}
+		if outcome.Action == ActionAsk {
+			result += " " + TR.Trans("label.batch_notification_pending")
+			break
+		}
+	}
+	if row.NicknameErr != nil {
+		result += " " + TR.Trans("label.batch_nickname_failed")
+	}
+	return result
+}
+
+// exportBatchReport 把批量下载的结果保存为 CSV 或 JSON 文件
+func exportBatchReport(batch *Batch) {
+	fileBuilder := nativeDialog.File().Title(TR.Trans("dialog.export_batch_report"))
+	fileBuilder.Filters = []nativeDialog.FileFilter{
+		{Desc: "CSV (*.csv)", Extensions: []string{"csv"}},
+		{Desc: "JSON (*.json)", Extensions: []string{"json"}},
+	}
+	filename, err := fileBuilder.Save()
+	if err != nil {
+		if err.Error() != "Cancelled" {
+			dialog.ShowError(err, WMain)
+		}
+		return
+	}
+	format := "csv"
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".json":
+		format = "json"
+	case ".csv":
+	default:
+		filename += ".csv"
+	}
+	var buf bytes.Buffer
+	if err = batch.WriteReport(&buf, format); err == nil {
+		err = os.WriteFile(filename, buf.Bytes(), 0644)
+	}
+	if err != nil {
+		dialog.ShowError(err, WMain)
+	}
+}
+
 func InitSetNicknameDialog() dialog.Dialog {
-	entry := &widget.Entry{PlaceHolder: TR.Trans("label.set_nickname_entry_placeholder")}
+	profile := SelectedProfile()
+	entry := &widget.Entry{PlaceHolder: TR.Trans("label.set_nickname_entry_placeholder"), Text: CurrentCardMemory().NicknameTemplate}
+	templateCheck := &widget.Check{Text: TR.Trans("label.nickname_template_check"), Checked: entry.Text != ""}
 	form := []*widget.FormItem{
-		{Text: TR.Trans("label.set_nickname_button"), Widget: entry},
+		{Text: TR.Trans("label.set_nickname_button"), Widget: entry,
+			HintText: TR.Trans("label.nickname_template_hint")},
+		{Widget: templateCheck},
 	}
 	d := dialog.NewForm(TR.Trans("label.set_nickname_form"), TR.Trans("dialog.submit"), TR.Trans("dialog.cancel"), form, func(b bool) {
 		if b {
-			if err := LpacProfileNickname(Profiles[SelectedProfile].Iccid, entry.Text); err != nil {
+			if templateCheck.Checked {
+				RememberNicknameTemplate(entry.Text)
+			} else if CurrentCardMemory().NicknameTemplate == entry.Text {
+				RememberNicknameTemplate("")
+			}
+			if err := LpacProfileNickname(profile.Iccid, ExpandNicknameTemplate(entry.Text, profile)); err != nil {
 				ShowLpacErrDialog(err)
 			}
 			err := RefreshProfile()
@@ -486,11 +1084,504 @@ func InitSetNicknameDialog() dialog.Dialog {
 	}, WMain)
 	d.Resize(fyne.Size{
 		Width:  400,
-		Height: 180,
+		Height: 240,
+	})
+	return d
+}
+
+// InitBulkNicknameDialog 按模板为勾选的 Profile 设置昵称
+func InitBulkNicknameDialog() dialog.Dialog {
+	selected := CheckedProfiles()
+	entry := &widget.Entry{PlaceHolder: TR.Trans("label.set_nickname_entry_placeholder"), Text: CurrentCardMemory().NicknameTemplate}
+	form := []*widget.FormItem{
+		{Text: TR.Trans("label.set_nickname_button"), Widget: entry,
+			HintText: TR.Trans("label.nickname_template_hint")},
+	}
+	d := dialog.NewForm(TR.Trans("label.bulk_nickname_button"), TR.Trans("dialog.submit"), TR.Trans("dialog.cancel"), form, func(b bool) {
+		if b {
+			ShowBulkProgressDialog(TR.Trans("label.bulk_nickname_button"),
+				NewBulkOperation(PlanBulkNickname(selected, entry.Text)))
+		}
+	}, WMain)
+	d.Resize(fyne.Size{
+		Width:  400,
+		Height: 200,
+	})
+	return d
+}
+
+// ShowBulkProgressDialog 执行批量操作，在一个对话框中显示所有步骤的进度，结束后刷新一次
+func ShowBulkProgressDialog(title string, operation *BulkOperation) {
+	steps := operation.Steps()
+	progressBar := widget.NewProgressBar()
+	summaryLabel := &widget.Label{Wrapping: fyne.TextWrapWord}
+
+	headers := []string{TR.Trans("label.bulk_profile"), TR.Trans("label.bulk_action"), TR.Trans("label.batch_status"), TR.Trans("label.batch_result")}
+	table := widget.NewTable(
+		func() (int, int) { return len(steps) + 1, len(headers) },
+		func() fyne.CanvasObject { return &widget.Label{Truncation: fyne.TextTruncateEllipsis} },
+		func(id widget.TableCellID, o fyne.CanvasObject) {
+			label := o.(*widget.Label)
+			if id.Row == 0 {
+				label.TextStyle = fyne.TextStyle{Bold: true}
+				label.SetText(headers[id.Col])
+				return
+			}
+			label.TextStyle = fyne.TextStyle{}
+			step := steps[id.Row-1]
+			switch id.Col {
+			case 0:
+				label.SetText(bulkProfileName(step.Profile))
+			case 1:
+				label.SetText(TR.Trans("label.bulk_action_" + step.Action))
+			case 2:
+				label.SetText(TR.Trans("label.bulk_status_" + step.Status.String()))
+			case 3:
+				label.SetText(bulkStepResult(step))
+			}
+		})
+	for col, width := range []float32{260, 90, 90, 260} {
+		table.SetColumnWidth(col, width)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var d dialog.Dialog
+	closeButton := &widget.Button{
+		Text:     TR.Trans("dialog.close"),
+		OnTapped: func() { d.Hide() },
+	}
+	closeButton.Disable()
+	stopButton := &widget.Button{
+		Text: TR.Trans("dialog.cancel"),
+		Icon: theme.MediaStopIcon(),
+		OnTapped: func() {
+			cancel()
+		},
+	}
+	update := func() {
+		steps = operation.Steps()
+		done, total := operation.Progress()
+		progressBar.SetValue(float64(done) / float64(max(total, 1)))
+		table.Refresh()
+	}
+	d = dialog.NewCustomWithoutButtons(title, container.NewBorder(
+		progressBar,
+		container.NewVBox(summaryLabel, container.NewCenter(container.NewHBox(stopButton, closeButton))),
+		nil, nil, table), WMain)
+	d.Resize(fyne.Size{Width: 760, Height: 480})
+	d.Show()
+
+	go func() {
+		operation.Run(ctx, update)
+		cancel()
+		update()
+		summaryLabel.SetText(bulkSummary(operation))
+		stopButton.Disable()
+		closeButton.Enable()
+		Refresh()
+		if outcomes, err := operation.Notifications(); err == nil {
+			ShowPendingNotificationsDialog(title, PendingNotifications(outcomes))
+		}
+	}()
+}
+
+// bulkStepResult 是进度表中一步的结果
+func bulkStepResult(step BulkStep) string {
+	if errors.Is(step.Err, ErrBulkSkipped) {
+		return TR.Trans("label.bulk_skipped")
+	}
+	if step.Err != nil {
+		if lpacErr, ok := AsLpacError(step.Err); ok {
+			if explanation, _ := lpacErr.Explanation(); explanation != "" {
+				return explanation
+			}
+		}
+		return strings.TrimSpace(step.Err.Error())
+	}
+	return ""
+}
+
+// bulkSummary 汇总步骤和结束后统一发送通知的结果
+func bulkSummary(operation *BulkOperation) string {
+	var succeeded, failed int
+	for _, step := range operation.Steps() {
+		switch step.Status {
+		case BatchSucceeded:
+			succeeded++
+		case BatchFailed:
+			failed++
+		}
+	}
+	_, total := operation.Progress()
+	summary := TR.Trans("label.bulk_summary", mf.Arg("succeeded", succeeded), mf.Arg("failed", failed),
+		mf.Arg("total", total))
+	outcomes, err := operation.Notifications()
+	switch {
+	case err != nil:
+		summary += "\n" + TR.Trans("message.bulk_notification_list_failed")
+	case operation.Notify:
+		var handled, notificationFailed, pending int
+		for _, outcome := range outcomes {
+			switch {
+			case outcome.Err != nil:
+				notificationFailed++
+			case outcome.Action == ActionAsk:
+				pending++
+			default:
+				handled++
+			}
+		}
+		summary += "\n" + TR.Trans("label.bulk_notification_summary",
+			mf.Arg("sent", handled), mf.Arg("failed", notificationFailed), mf.Arg("pending", pending))
+	}
+	return summary
+}
+
+func InitExportReportDialog(iccids []string) dialog.Dialog {
+	formatNames := []string{"JSON", "CSV", "Markdown", "HTML"}
+	formatSelect := widget.NewSelect(formatNames, nil)
+	formatSelect.SetSelectedIndex(2)
+	maskCheck := &widget.Check{Text: TR.Trans("label.export_report_mask"), Checked: ProfileMaskNeeded || NotificationMaskNeeded}
+	form := []*widget.FormItem{
+		{Text: TR.Trans("label.export_report_format"), Widget: formatSelect},
+		{Widget: maskCheck, HintText: TR.Trans("label.export_report_mask_hint")},
+	}
+	d := dialog.NewForm(TR.Trans("label.export_report_button"), TR.Trans("label.export_report_save"), TR.Trans("dialog.cancel"), form, func(b bool) {
+		if b {
+			go exportReport(ReportFormats[formatSelect.SelectedIndex()], maskCheck.Checked, iccids)
+		}
+	}, WMain)
+	d.Resize(fyne.Size{
+		Width:  400,
+		Height: 220,
 	})
 	return d
 }
 
+// exportReport 读取当前卡片生成报告并保存到用户选择的文件，iccids 不为空时只包含这些 Profile
+func exportReport(format string, mask bool, iccids []string) {
+	report, err := CollectReport()
+	if err != nil {
+		ShowLpacErrDialog(err)
+		return
+	}
+	if iccids != nil {
+		report.SelectProfiles(iccids)
+	}
+	if mask {
+		report.Mask()
+	}
+	fileBuilder := nativeDialog.File().Title(TR.Trans("label.export_report_button"))
+	fileBuilder.Filters = []nativeDialog.FileFilter{{Desc: strings.ToUpper(format), Extensions: []string{format}}}
+	filename, err := fileBuilder.SetStartFile("euicc-report-" + report.Generated.Format("20060102-150405") + "." + format).Save()
+	if err != nil {
+		if err.Error() != "Cancelled" {
+			dialog.ShowError(err, WMain)
+		}
+		return
+	}
+	if filepath.Ext(filename) == "" {
+		filename += "." + format
+	}
+	var buf bytes.Buffer
+	if err = report.Write(&buf, format); err == nil {
+		err = os.WriteFile(filename, buf.Bytes(), 0644)
+	}
+	if err != nil {
+		dialog.ShowError(err, WMain)
+		return
+	}
+	dialog.ShowInformation(TR.Trans("dialog.info"), TR.Trans("message.export_report_saved", mf.Arg("file", filename)), WMain)
+}
+
+// ShowNotificationPolicyDialog 编辑通知策略，保存前修改的是副本
+func ShowNotificationPolicyDialog() {
+	policy := currentNotificationPolicy().Clone()
+	defaults := container.NewGridWithColumns(2)
+	for _, operation := range NotificationOperations {
+		defaults.Add(widget.NewLabel(TR.Trans("label.notification_operation_" + operation)))
+		defaults.Add(notificationActionSelect(policy.Operations, operation, false))
+	}
+
+	overrides := container.NewVBox()
+	var refreshOverrides func()
+	refreshOverrides = func() {
+		overrides.RemoveAll()
+		for i, o := range policy.Overrides {
+			addressEntry := &widget.Entry{Text: o.Address, PlaceHolder: TR.Trans("label.notification_override_address_placeholder")}
+			addressEntry.OnChanged = func(s string) {
+				o.Address = strings.TrimSpace(s)
+			}
+			deleteButton := &widget.Button{Icon: theme.DeleteIcon(), Importance: widget.DangerImportance, OnTapped: func() {
+				policy.Overrides = slices.Delete(policy.Overrides, i, i+1)
+				refreshOverrides()
+			}}
+			operations := container.NewGridWithColumns(4)
+			for _, operation := range NotificationOperations {
+				operations.Add(widget.NewLabel(TR.Trans("label.notification_operation_" + operation)))
+				operations.Add(notificationActionSelect(o.Operations, operation, true))
+			}
+			overrides.Add(container.NewBorder(nil, nil, nil, deleteButton, addressEntry))
+			overrides.Add(operations)
+			overrides.Add(widget.NewSeparator())
+		}
+	}
+	refreshOverrides()
+	addButton := &widget.Button{
+		Text: TR.Trans("label.notification_override_add_button"),
+		Icon: theme.ContentAddIcon(),
+		OnTapped: func() {
+			policy.Overrides = append(policy.Overrides, &NotificationOverride{Operations: make(map[string]NotificationAction)})
+			refreshOverrides()
+		},
+	}
+
+	var d dialog.Dialog
+	cancelButton := &widget.Button{
+		Text:     TR.Trans("dialog.cancel"),
+		Icon:     theme.CancelIcon(),
+		OnTapped: func() { d.Hide() },
+	}
+	saveButton := &widget.Button{
+		Text:       TR.Trans("dialog.submit"),
+		Icon:       theme.ConfirmIcon(),
+		Importance: widget.HighImportance,
+		OnTapped: func() {
+			if slices.ContainsFunc(policy.Overrides, func(o *NotificationOverride) bool { return o.Address == "" }) {
+				dialog.ShowError(errors.New(TR.Trans("message.notification_override_address_required")), WMain)
+				return
+			}
+			policy.validate()
+			ConfigInstance.NotificationPolicy = policy
+			ConfigChanged()
+			d.Hide()
+		},
+	}
+	d = dialog.NewCustomWithoutButtons(TR.Trans("label.notification_policy_button"), container.NewBorder(
+		container.NewVBox(
+			&widget.Label{Text: TR.Trans("label.notification_policy_description"), Wrapping: fyne.TextWrapWord},
+			&widget.Label{Text: TR.Trans("label.notification_policy_defaults"), TextStyle: fyne.TextStyle{Bold: true}},
+			defaults,
+			container.NewBorder(nil, nil,
+				&widget.Label{Text: TR.Trans("label.notification_overrides"), TextStyle: fyne.TextStyle{Bold: true}},
+				addButton),
+		),
+		container.NewCenter(container.NewHBox(cancelButton, saveButton)),
+		nil, nil,
+		container.NewVScroll(overrides)), WMain)
+	d.Resize(fyne.Size{Width: 720, Height: 560})
+	d.Show()
+}
+
+// notificationActionSelect 修改 actions 中 operation 的处理方式
+// inherit 为 true 时多出使用默认策略的选项，选择后删除这一项
+func notificationActionSelect(actions map[string]NotificationAction, operation string, inherit bool) *widget.Select {
+	var options []string
+	var values []NotificationAction
+	if inherit {
+		options = append(options, TR.Trans("label.notification_action_inherit"))
+		values = append(values, "")
+	}
+	for _, action := range NotificationActions {
+		options = append(options, notificationActionName(action))
+		values = append(values, action)
+	}
+	s := widget.NewSelect(options, nil)
+	s.SetSelectedIndex(slices.Index(values, actions[operation]))
+	s.OnChanged = func(string) {
+		if action := values[s.SelectedIndex()]; action == "" {
+			delete(actions, operation)
+		} else {
+			actions[operation] = action
+		}
+	}
+	return s
+}
+
+// ShowScheduleDialog 管理定时切换的计划并显示最近的执行记录
+func ShowScheduleDialog() {
+	var entryList *widget.List
+	entryList = widget.NewList(
+		func() int { return len(ConfigInstance.Schedules) },
+		func() fyne.CanvasObject {
+			return container.NewBorder(nil, nil, &widget.Check{},
+				container.NewHBox(
+					&widget.Button{Text: TR.Trans("label.schedule_run_button"), Icon: theme.MediaPlayIcon()},
+					&widget.Button{Icon: theme.DeleteIcon(), Importance: widget.DangerImportance}),
+				container.NewVBox(&widget.Label{TextStyle: fyne.TextStyle{Bold: true}, Truncation: fyne.TextTruncateEllipsis},
+					&widget.Label{Truncation: fyne.TextTruncateEllipsis}))
+		},
+		func(i widget.ListItemID, o fyne.CanvasObject) {
+			e := ConfigInstance.Schedules[i]
+			row := o.(*fyne.Container)
+			texts := row.Objects[0].(*fyne.Container)
+			check := row.Objects[1].(*widget.Check)
+			buttons := row.Objects[2].(*fyne.Container)
+			texts.Objects[0].(*widget.Label).SetText(fmt.Sprintf("%s  %s  %s", e.Name, e.Iccid, scheduleProfileName(e.Iccid)))
+			details := []string{e.When()}
+			if e.EID != "" {
+				details = append(details, e.EID)
+			}
+			if e.Reader != "" {
+				details = append(details, e.Reader)
+			}
+			if next := e.Next(time.Now()); e.Disabled {
+				details = append(details, TR.Trans("label.schedule_paused"))
+			} else if !next.IsZero() {
+				details = append(details, TR.Trans("label.schedule_next", mf.Arg("time", next.Format(ScheduleTimeLayout))))
+			}
+			texts.Objects[1].(*widget.Label).SetText(strings.Join(details, "  ·  "))
+			check.OnChanged = nil
+			check.SetChecked(!e.Disabled)
+			check.OnChanged = func(b bool) {
+				e.Disabled = !b
+				SetSchedules(ConfigInstance.Schedules)
+				entryList.RefreshItem(i)
+			}
+			buttons.Objects[0].(*widget.Button).OnTapped = func() {
+				if SchedulerInstance == nil {
+					return
+				}
+				if entry := SchedulerInstance.Find(e.Name); entry != nil {
+					go SchedulerInstance.Run(context.Background(), entry)
+				}
+			}
+			buttons.Objects[1].(*widget.Button).OnTapped = func() {
+				SetSchedules(slices.Delete(slices.Clone(ConfigInstance.Schedules), i, i+1))
+				entryList.Refresh()
+			}
+		})
+
+	var history []*ScheduleRun
+	ScheduleHistoryList = widget.NewList(
+		func() int {
+			if SchedulerInstance != nil {
+				history = SchedulerInstance.History()
+			}
+			return len(history)
+		},
+		func() fyne.CanvasObject { return &widget.Label{Truncation: fyne.TextTruncateEllipsis} },
+		func(i widget.ListItemID, o fyne.CanvasObject) {
+			if i >= len(history) {
+				return
+			}
+			o.(*widget.Label).SetText(scheduleRunText(history[i]))
+		})
+
+	addButton := &widget.Button{
+		Text:       TR.Trans("label.schedule_add_button"),
+		Icon:       theme.ContentAddIcon(),
+		Importance: widget.HighImportance,
+		OnTapped: func() {
+			InitScheduleEntryDialog(entryList.Refresh).Show()
+		},
+	}
+	var d dialog.Dialog
+	closeButton := &widget.Button{
+		Text:     TR.Trans("dialog.close"),
+		OnTapped: func() { d.Hide() },
+	}
+	d = dialog.NewCustomWithoutButtons(TR.Trans("label.schedule_settings"), container.NewBorder(
+		&widget.Label{Text: TR.Trans("label.schedule_hint"), Wrapping: fyne.TextWrapWord},
+		container.NewCenter(container.NewHBox(addButton, closeButton)),
+		nil, nil,
+		container.NewVSplit(entryList, container.NewBorder(
+			&widget.Label{Text: TR.Trans("label.schedule_history"), TextStyle: fyne.TextStyle{Bold: true}},
+			nil, nil, nil, ScheduleHistoryList))), WMain)
+	d.SetOnClosed(func() { ScheduleHistoryList = nil })
+	d.Resize(fyne.Size{Width: 720, Height: 520})
+	d.Show()
+}
+
+// InitScheduleEntryDialog 新建一个计划，默认使用当前的卡片和读卡器
+func InitScheduleEntryDialog(onAdded func()) dialog.Dialog {
+	nameEntry := &widget.Entry{}
+	var iccids []string
+	for _, p := range Profiles {
+		iccids = append(iccids, p.Iccid)
+	}
+	iccidEntry := widget.NewSelectEntry(iccids)
+	eidEntry := &widget.Entry{}
+	if ChipInfo != nil {
+		eidEntry.SetText(ChipInfo.EidValue)
+	}
+	var readers []string
+	for _, d := range ApduDrivers {
+		readers = append(readers, d.Name)
+	}
+	readerEntry := widget.NewSelectEntry(readers)
+	readerEntry.SetText(ConfigInstance.ReaderName)
+	cronEntry := &widget.Entry{PlaceHolder: "0 8 * * 1-5"}
+	fromEntry := &widget.Entry{PlaceHolder: ScheduleTimeLayout}
+	untilEntry := &widget.Entry{PlaceHolder: ScheduleTimeLayout}
+	form := []*widget.FormItem{
+		{Text: TR.Trans("label.schedule_name"), Widget: nameEntry},
+		{Text: TR.Trans("label.schedule_iccid"), Widget: iccidEntry},
+		{Text: TR.Trans("label.schedule_eid"), Widget: eidEntry, HintText: TR.Trans("label.schedule_eid_hint")},
+		{Text: TR.Trans("label.schedule_reader"), Widget: readerEntry, HintText: TR.Trans("label.schedule_reader_hint")},
+		{Text: TR.Trans("label.schedule_cron"), Widget: cronEntry, HintText: TR.Trans("label.schedule_cron_hint")},
+		{Text: TR.Trans("label.schedule_from"), Widget: fromEntry, HintText: TR.Trans("label.schedule_from_hint")},
+		{Text: TR.Trans("label.schedule_until"), Widget: untilEntry, HintText: TR.Trans("label.schedule_until_hint")},
+	}
+	d := dialog.NewForm(TR.Trans("label.schedule_add_button"), TR.Trans("dialog.submit"), TR.Trans("dialog.cancel"), form, func(b bool) {
+		if !b {
+			return
+		}
+		e := &ScheduleEntry{
+			Name:   strings.TrimSpace(nameEntry.Text),
+			Iccid:  strings.TrimSpace(iccidEntry.Text),
+			EID:    strings.TrimSpace(eidEntry.Text),
+			Reader: strings.TrimSpace(readerEntry.Text),
+			Cron:   strings.TrimSpace(cronEntry.Text),
+			From:   strings.TrimSpace(fromEntry.Text),
+			Until:  strings.TrimSpace(untilEntry.Text),
+		}
+		if err := e.Validate(); err != nil {
+			dialog.ShowError(err, WMain)
+			return
+		}
+		if slices.ContainsFunc(ConfigInstance.Schedules, func(existing *ScheduleEntry) bool { return existing.Name == e.Name }) {
+			dialog.ShowError(errors.New(TR.Trans("message.schedule_duplicate_name", mf.Arg("name", e.Name))), WMain)
+			return
+		}
+		SetSchedules(append(slices.Clone(ConfigInstance.Schedules), e))
+		onAdded()
+	}, WMain)
+	d.Resize(fyne.Size{Width: 520, Height: 560})
+	return d
+}
+
+// scheduleProfileName 返回当前卡片上对应 Profile 的名称，不在当前卡片上时返回空
+func scheduleProfileName(iccid string) string {
+	for _, p := range Profiles {
+		if p.Iccid != iccid {
+			continue
+		}
+		if p.ProfileNickname != nil && *p.ProfileNickname != "" {
+			return *p.ProfileNickname
+		}
+		return p.ServiceProviderName
+	}
+	return ""
+}
+
+// scheduleRunText 是执行记录中的一行
+func scheduleRunText(run *ScheduleRun) string {
+	text := fmt.Sprintf("%s  %s  %s  %s", run.Time.Format("01-02 15:04"), run.Entry,
+		run.Iccid, TR.Trans("label.schedule_status_"+string(run.Status)))
+	if run.Err != nil {
+		text += "  " + strings.ReplaceAll(run.Err.Error(), "\n", " ")
+	}
+	if run.Outcome.Err != nil {
+		if run.Outcome.RolledBack {
+			text += "  " + TR.Trans("message.switch_restored")
+		} else {
+			text += "  " + TR.Trans("message.switch_rollback_failed")
+		}
+	}
+	return text
+}
+
 func InitSetDefaultSmdpDialog() dialog.Dialog {
 	entry := &widget.Entry{PlaceHolder: TR.Trans("label.set_default_smdp_entry_placeholder")}
 	form := []*widget.FormItem{
@@ -515,18 +1606,45 @@ func InitSetDefaultSmdpDialog() dialog.Dialog {
 }
 
 func ShowLpacErrDialog(err error) {
+	ShowLpacErrDialogWithRetry(err, nil)
+}
+
+// ShowLpacErrDialogWithRetry 在错误可以重试且 retry 不为 nil 时提供重试按钮
+func ShowLpacErrDialogWithRetry(err error, retry func()) {
 	go func() {
-		l := &widget.Label{Text: fmt.Sprintf("%v", err)}
-		content := container.NewVBox(
-			container.NewCenter(container.NewHBox(
-				widget.NewIcon(theme.ErrorIcon()),
-				widget.NewLabel(TR.Trans("dialog.lpac_error")))),
-			container.NewCenter(l),
-			container.NewCenter(widget.NewLabel(TR.Trans("message.lpac_error"))))
-		dialog.ShowCustom(TR.Trans("dialog.error"), TR.Trans("dialog.ok"), content, WMain)
+		content := lpacErrorContent(err)
+		if retry == nil || !IsRetryable(err) {
+			dialog.ShowCustom(TR.Trans("dialog.error"), TR.Trans("dialog.ok"), content, WMain)
+			return
+		}
+		dialog.ShowCustomConfirm(TR.Trans("dialog.error"), TR.Trans("dialog.retry"), TR.Trans("dialog.cancel"), content,
+			func(b bool) {
+				if b {
+					go retry()
+				}
+			}, WMain)
 	}()
 }
 
+func lpacErrorContent(err error) *fyne.Container {
+	l := &widget.Label{Text: fmt.Sprintf("%v", err)}
+	content := container.NewVBox(
+		container.NewCenter(container.NewHBox(
+			widget.NewIcon(theme.ErrorIcon()),
+			widget.NewLabel(TR.Trans("dialog.lpac_error")))))
+	// 已知错误先显示说明和建议，原始输出留作参考
+	if lpacErr, ok := AsLpacError(err); ok {
+		if explanation, remedy := lpacErr.Explanation(); explanation != "" {
+			content.Add(container.NewCenter(&widget.Label{Text: explanation, TextStyle: fyne.TextStyle{Bold: true}}))
+			content.Add(container.NewCenter(widget.NewLabel(remedy)))
+			content.Add(widget.NewSeparator())
+		}
+	}
+	content.Add(container.NewCenter(l))
+	content.Add(container.NewCenter(widget.NewLabel(TR.Trans("message.lpac_error"))))
+	return content
+}
+
 func ShowSelectItemDialog() {
 	go func() {
 		d := dialog.NewInformation(TR.Trans("dialog.info"), TR.Trans("message.select_item"), WMain)